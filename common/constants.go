@@ -139,6 +139,18 @@ func IsValidateRole(role int) bool {
 	return role == RoleGuestUser || role == RoleCommonUser || role == RoleAdminUser || role == RoleRootUser
 }
 
+// 组织成员角色，数值越大权限越高
+const (
+	OrganizationRoleViewer = 1
+	OrganizationRoleMember = 2
+	OrganizationRoleAdmin  = 3
+	OrganizationRoleOwner  = 4
+)
+
+func IsValidOrganizationRole(role int) bool {
+	return role >= OrganizationRoleViewer && role <= OrganizationRoleOwner
+}
+
 var (
 	FileUploadPermission    = RoleGuestUser
 	FileDownloadPermission  = RoleGuestUser
@@ -192,6 +204,7 @@ const (
 	ChannelStatusEnabled          = 1 // don't use 0, 0 is the default value!
 	ChannelStatusManuallyDisabled = 2 // also don't use 0
 	ChannelStatusAutoDisabled     = 3
+	ChannelStatusCoolingDown      = 4 // 多 Key 渠道中因限流暂时冷却的 Key，冷却结束后自动恢复
 )
 
 const (
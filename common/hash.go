@@ -5,6 +5,7 @@ import (
 	"crypto/sha1"
 	"crypto/sha256"
 	"encoding/hex"
+	"strings"
 )
 
 func Sha256Raw(data []byte) []byte {
@@ -32,3 +33,13 @@ func HmacSha256Raw(message, key []byte) []byte {
 func HmacSha256(message, key string) string {
 	return hex.EncodeToString(HmacSha256Raw([]byte(message), []byte(key)))
 }
+
+// KeyFingerprint 密钥的脱敏指纹，用于在日志和管理接口中区分不同的 Key
+func KeyFingerprint(key string) string {
+	key = strings.TrimSpace(key)
+	sum := hex.EncodeToString(Sha256Raw([]byte(key)))[:8]
+	if len(key) >= 16 && !strings.HasPrefix(key, "{") {
+		return key[:4] + "..." + key[len(key)-4:] + "#" + sum
+	}
+	return "#" + sum
+}
@@ -5,6 +5,15 @@ type ContextKey string
 const (
 	ContextKeyOriginalModel    ContextKey = "original_model"
 	ContextKeyRequestStartTime ContextKey = "request_start_time"
+	ContextKeyBatchRequest     ContextKey = "batch_request"
+	ContextKeyFineTuneBase     ContextKey = "fine_tune_base_model"
+	ContextKeyChannelFilter    ContextKey = "channel_filter"
+	ContextKeyHedgeRace        ContextKey = "hedge_race"
+	ContextKeyStreamResume     ContextKey = "stream_resume"
+	ContextKeyModelFallback    ContextKey = "model_fallback_from"
+	ContextKeyRoutingRule      ContextKey = "routing_rule"
+	ContextKeySessionAffinity  ContextKey = "session_affinity"
+	ContextKeySessionBinding   ContextKey = "session_affinity_binding"
 
 	/* token related keys */
 	ContextKeyTokenUnlimited         ContextKey = "token_unlimited_quota"
@@ -15,6 +24,7 @@ const (
 	ContextKeyTokenSpecificChannelId ContextKey = "specific_channel_id"
 	ContextKeyTokenModelLimitEnabled ContextKey = "token_model_limit_enabled"
 	ContextKeyTokenModelLimit        ContextKey = "token_model_limit"
+	ContextKeyTokenResponseCache     ContextKey = "token_response_cache"
 
 	/* channel related keys */
 	ContextKeyChannelId                ContextKey = "channel_id"
@@ -31,6 +41,7 @@ const (
 	ContextKeyChannelIsMultiKey        ContextKey = "channel_is_multi_key"
 	ContextKeyChannelMultiKeyIndex     ContextKey = "channel_multi_key_index"
 	ContextKeyChannelKey               ContextKey = "channel_key"
+	ContextKeyChannelContextWindow     ContextKey = "channel_context_window"
 
 	/* user related keys */
 	ContextKeyUserId      ContextKey = "id"
@@ -41,4 +52,8 @@ const (
 	ContextKeyUserGroup   ContextKey = "user_group"
 	ContextKeyUsingGroup  ContextKey = "group"
 	ContextKeyUserName    ContextKey = "username"
+
+	/* organization related keys */
+	ContextKeyOrganizationId   ContextKey = "organization_id"
+	ContextKeyOrganizationRole ContextKey = "organization_role"
 )
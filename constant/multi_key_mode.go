@@ -3,6 +3,9 @@ package constant
 type MultiKeyMode string
 
 const (
-	MultiKeyModeRandom  MultiKeyMode = "random"  // 随机
-	MultiKeyModePolling MultiKeyMode = "polling" // 轮询
+	MultiKeyModeRandom            MultiKeyMode = "random"              // 随机
+	MultiKeyModePolling           MultiKeyMode = "polling"             // 轮询
+	MultiKeyModeLeastRecentlyUsed MultiKeyMode = "least_recently_used" // 最久未使用
+	MultiKeyModeLeastInflight     MultiKeyMode = "least_inflight"      // 进行中请求最少
+	MultiKeyModeTokenBucket       MultiKeyMode = "token_bucket"        // 按每个 Key 的 RPM/TPM 限流
 )
@@ -0,0 +1,321 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/middleware"
+	"one-api/model"
+	"one-api/relay"
+	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"one-api/types"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var batchSupportedEndpoints = map[string]bool{
+	"/v1/chat/completions": true,
+	"/v1/completions":      true,
+	"/v1/embeddings":       true,
+	"/v1/responses":        true,
+}
+
+func batchError(c *gin.Context, statusCode int, message string, param string) {
+	c.JSON(statusCode, gin.H{
+		"error": dto.OpenAIError{
+			Message: message,
+			Type:    "invalid_request_error",
+			Param:   param,
+		},
+	})
+}
+
+func buildOpenAIBatch(batch *model.Batch) dto.OpenAIBatch {
+	optional := func(v int64) *int64 {
+		if v == 0 {
+			return nil
+		}
+		return &v
+	}
+	optionalStr := func(v string) *string {
+		if v == "" {
+			return nil
+		}
+		return &v
+	}
+	openAIBatch := dto.OpenAIBatch{
+		Id:               batch.BatchId,
+		Object:           "batch",
+		Endpoint:         batch.Endpoint,
+		InputFileId:      batch.InputFileId,
+		CompletionWindow: batch.CompletionWindow,
+		Status:           batch.OpenAIStatus(),
+		OutputFileId:     optionalStr(batch.OutputFileId),
+		ErrorFileId:      optionalStr(batch.ErrorFileId),
+		CreatedAt:        batch.CreatedAt,
+		InProgressAt:     optional(batch.InProgressAt),
+		ExpiresAt:        optional(batch.ExpiresAt),
+		FinalizingAt:     optional(batch.FinalizingAt),
+		CompletedAt:      optional(batch.CompletedAt),
+		FailedAt:         optional(batch.FailedAt),
+		ExpiredAt:        optional(batch.ExpiredAt),
+		CancellingAt:     optional(batch.CancellingAt),
+		CancelledAt:      optional(batch.CancelledAt),
+		RequestCounts: dto.BatchRequestCounts{
+			Total:     batch.RequestTotal,
+			Completed: batch.RequestCompleted,
+			Failed:    batch.RequestFailed,
+		},
+	}
+	if batch.FailReason != "" {
+		openAIBatch.Errors = &dto.BatchErrors{
+			Object: "list",
+			Data: []dto.BatchLineError{
+				{Code: "batch_failed", Message: batch.FailReason},
+			},
+		}
+	}
+	if batch.Metadata != "" {
+		_ = common.UnmarshalJsonStr(batch.Metadata, &openAIBatch.Metadata)
+	}
+	return openAIBatch
+}
+
+func CreateBatch(c *gin.Context) {
+	batchSetting := operation_setting.GetBatchSetting()
+	if !batchSetting.Enabled {
+		RelayNotImplemented(c)
+		return
+	}
+	userId := c.GetInt64("id")
+	var request dto.BatchRequest
+	if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+		batchError(c, http.StatusBadRequest, "Invalid request body: "+err.Error(), "")
+		return
+	}
+	if !batchSupportedEndpoints[request.Endpoint] {
+		batchError(c, http.StatusBadRequest, fmt.Sprintf("Unsupported endpoint: %s", request.Endpoint), "endpoint")
+		return
+	}
+	if request.CompletionWindow != "24h" {
+		batchError(c, http.StatusBadRequest, "Invalid completion_window, only '24h' is supported.", "completion_window")
+		return
+	}
+	inputFile, err := model.GetUserFileByFileId(userId, request.InputFileId)
+	if err != nil {
+		batchError(c, http.StatusBadRequest, fmt.Sprintf("No such File object: %s", request.InputFileId), "input_file_id")
+		return
+	}
+	if inputFile.Purpose != "batch" {
+		batchError(c, http.StatusBadRequest, "The input file must have purpose 'batch'.", "input_file_id")
+		return
+	}
+
+	batch := &model.Batch{
+		UserId:           userId,
+		TokenId:          c.GetInt("token_id"),
+		Endpoint:         request.Endpoint,
+		InputFileId:      request.InputFileId,
+		CompletionWindow: request.CompletionWindow,
+		Status:           model.TaskStatusSubmitted,
+		ExpiresAt:        time.Now().Add(24 * time.Hour).Unix(),
+	}
+	batch.OrganizationId = common.GetContextKeyInt(c, constant.ContextKeyOrganizationId)
+	if len(request.Metadata) > 0 {
+		metadata, _ := common.Marshal(request.Metadata)
+		batch.Metadata = string(metadata)
+	}
+
+	if inputFile.IsUpstream() && batchSetting.UpstreamEnabled {
+		channel, err := model.CacheGetChannel(inputFile.ChannelId)
+		if err != nil {
+			batchError(c, http.StatusBadRequest, "the channel bound to the input file is unavailable", "input_file_id")
+			return
+		}
+		batch.ChannelId = channel.Id
+		batch.KeyIndex = inputFile.KeyIndex
+		if newAPIError := preConsumeUpstreamBatchQuota(c, batch); newAPIError != nil {
+			batchError(c, newAPIError.StatusCode, newAPIError.Error(), "input_file_id")
+			return
+		}
+		upstreamBatch, err := doUpstreamBatchRequest(channel, batch.KeyIndex, http.MethodPost, "/v1/batches", request)
+		if err != nil {
+			returnUpstreamBatchQuota(batch)
+			common.LogError(c, fmt.Sprintf("create upstream batch on channel #%d failed: %s", channel.Id, err.Error()))
+			batchError(c, http.StatusBadGateway, "create batch on upstream failed", "")
+			return
+		}
+		batch.BatchId = upstreamBatch.Id
+		applyUpstreamBatch(batch, upstreamBatch)
+	} else {
+		batch.BatchId = "batch_" + common.GetRandomString(24)
+	}
+	if err = batch.Insert(); err != nil {
+		returnUpstreamBatchQuota(batch)
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, buildOpenAIBatch(batch))
+}
+
+// preConsumeUpstreamBatchQuota 转发到上游前按输入文件中的每个请求检查令牌模型限制和定价，
+// 按批处理折扣后走与在线请求相同的额度、令牌额度和周期预算检查并预扣，结算时按实际用量多退少补
+func preConsumeUpstreamBatchQuota(c *gin.Context, batch *model.Batch) *types.NewAPIError {
+	models, err := readBatchInputModels(batch)
+	if err != nil {
+		return types.NewErrorWithStatusCode(fmt.Errorf("read input file failed: %s", err.Error()), types.ErrorCodeInvalidRequest, http.StatusBadRequest)
+	}
+	if len(models) == 0 {
+		return types.NewErrorWithStatusCode(errors.New("input file is empty"), types.ErrorCodeInvalidRequest, http.StatusBadRequest)
+	}
+	token, err := model.GetTokenById(batch.TokenId)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeQueryDataError)
+	}
+	userCache, err := model.GetUserCache(batch.UserId)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeQueryDataError)
+	}
+	var relayInfo *relaycommon.RelayInfo
+	modelQuota := make(map[string]int)
+	quota := 0
+	for customId, modelName := range models {
+		if !middleware.IsTokenModelAllowed(c, modelName) {
+			return types.NewErrorWithStatusCode(fmt.Errorf("request %s: 该令牌无权访问模型 %s", customId, modelName), types.ErrorCodeAccessDenied, http.StatusForbidden)
+		}
+		relayInfo = newUpstreamBatchRelayInfo(batch, token, userCache, modelName, modelName)
+		lineQuota, ok := modelQuota[modelName]
+		if !ok {
+			priceData, err := helper.ModelPriceHelper(c, relayInfo, 0, 0)
+			if err != nil {
+				return types.NewErrorWithStatusCode(fmt.Errorf("request %s: %s", customId, err.Error()), types.ErrorCodeModelPriceError, http.StatusBadRequest)
+			}
+			lineQuota = priceData.ShouldPreConsumedQuota
+			modelQuota[modelName] = lineQuota
+		}
+		quota += lineQuota
+	}
+	preConsumedQuota, newAPIError := relay.PreConsumeQuota(c, service.BatchDiscountQuota(relayInfo, quota), relayInfo)
+	if newAPIError != nil {
+		return newAPIError
+	}
+	batch.PreConsumedQuota = preConsumedQuota
+	return nil
+}
+
+func RetrieveBatch(c *gin.Context) {
+	batch, err := model.GetUserBatchByBatchId(c.GetInt64("id"), c.Param("id"))
+	if err != nil {
+		batchError(c, http.StatusNotFound, fmt.Sprintf("No such Batch object: %s", c.Param("id")), "id")
+		return
+	}
+	c.JSON(http.StatusOK, buildOpenAIBatch(batch))
+}
+
+func CancelBatch(c *gin.Context) {
+	batch, err := model.GetUserBatchByBatchId(c.GetInt64("id"), c.Param("id"))
+	if err != nil {
+		batchError(c, http.StatusNotFound, fmt.Sprintf("No such Batch object: %s", c.Param("id")), "id")
+		return
+	}
+	if batch.IsFinished() {
+		batchError(c, http.StatusConflict, fmt.Sprintf("Cannot cancel a batch with status '%s'.", batch.OpenAIStatus()), "")
+		return
+	}
+	if batch.IsUpstream() {
+		channel, err := model.CacheGetChannel(batch.ChannelId)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		upstreamBatch, err := doUpstreamBatchRequest(channel, batch.KeyIndex, http.MethodPost, "/v1/batches/"+batch.BatchId+"/cancel", nil)
+		if err != nil {
+			common.LogError(c, fmt.Sprintf("cancel upstream batch %s failed: %s", batch.BatchId, err.Error()))
+			batchError(c, http.StatusBadGateway, "cancel batch on upstream failed", "")
+			return
+		}
+		applyUpstreamBatch(batch, upstreamBatch)
+		_ = batch.SaveUpstreamState()
+		c.JSON(http.StatusOK, buildOpenAIBatch(batch))
+		return
+	}
+	if err = model.MarkBatchCancelling(batch.Id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	// 尚未开始执行的任务直接取消，执行中的任务由 worker 感知后结束
+	if _, err = model.CancelSubmittedBatch(batch.Id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	batch, _ = model.GetBatchById(batch.Id)
+	c.JSON(http.StatusOK, buildOpenAIBatch(batch))
+}
+
+func ListBatches(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	batches, err := model.GetUserBatches(c.GetInt64("id"), c.Query("after"), limit+1)
+	if err != nil {
+		batchError(c, http.StatusBadRequest, err.Error(), "after")
+		return
+	}
+	list := dto.OpenAIBatchList{
+		Object: "list",
+		Data:   make([]dto.OpenAIBatch, 0, len(batches)),
+	}
+	if len(batches) > limit {
+		list.HasMore = true
+		batches = batches[:limit]
+	}
+	for _, batch := range batches {
+		list.Data = append(list.Data, buildOpenAIBatch(batch))
+	}
+	if len(list.Data) > 0 {
+		list.FirstId = list.Data[0].Id
+		list.LastId = list.Data[len(list.Data)-1].Id
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func doUpstreamBatchRequest(channel *model.Channel, keyIndex int, method string, path string, payload any) (*dto.OpenAIBatch, error) {
+	var body io.Reader
+	contentType := ""
+	if payload != nil {
+		data, err := common.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+	resp, err := service.DoChannelFileRequest(channel, keyIndex, method, path, body, contentType)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code: %d, body: %s", resp.StatusCode, string(respBody))
+	}
+	var upstreamBatch dto.OpenAIBatch
+	if err = common.Unmarshal(respBody, &upstreamBatch); err != nil {
+		return nil, err
+	}
+	return &upstreamBatch, nil
+}
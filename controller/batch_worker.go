@@ -0,0 +1,606 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/middleware"
+	"one-api/model"
+	"one-api/relay"
+	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+// 正在本进程内执行的批处理任务
+var runningBatches sync.Map
+
+var batchSemLock sync.Mutex
+var batchWorkerSem chan struct{}
+var batchChannelSems = make(map[int]chan struct{})
+
+func getBatchWorkerSem() chan struct{} {
+	batchSemLock.Lock()
+	defer batchSemLock.Unlock()
+	size := operation_setting.GetBatchSetting().WorkerCount
+	if size <= 0 {
+		size = 1
+	}
+	// 配置变更后重建，已占用的名额随旧信号量释放
+	if batchWorkerSem == nil || cap(batchWorkerSem) != size {
+		batchWorkerSem = make(chan struct{}, size)
+	}
+	return batchWorkerSem
+}
+
+// acquireBatchChannelSlot 占用渠道的批处理并发名额，返回释放函数
+func acquireBatchChannelSlot(channelId int) func() {
+	size := operation_setting.GetBatchSetting().ChannelConcurrency
+	if size <= 0 || channelId == 0 {
+		return func() {}
+	}
+	batchSemLock.Lock()
+	sem, ok := batchChannelSems[channelId]
+	if !ok || cap(sem) != size {
+		sem = make(chan struct{}, size)
+		batchChannelSems[channelId] = sem
+	}
+	batchSemLock.Unlock()
+	sem <- struct{}{}
+	return func() { <-sem }
+}
+
+func UpdateBatchTasks() {
+	// 本地执行的任务无法在重启后续跑，统一标记为失败
+	for _, batch := range model.GetUnfinishedBatches(1000) {
+		if !batch.IsUpstream() && batch.Status == model.TaskStatusInProgress {
+			failBatch(batch, "batch interrupted by server restart")
+		}
+	}
+	for {
+		interval := operation_setting.GetBatchSetting().PollIntervalSeconds
+		if interval <= 0 {
+			interval = 10
+		}
+		time.Sleep(time.Duration(interval) * time.Second)
+		if !operation_setting.GetBatchSetting().Enabled {
+			continue
+		}
+		for _, batch := range model.GetUnfinishedBatches(100) {
+			if batch.IsUpstream() {
+				syncUpstreamBatch(batch)
+				continue
+			}
+			if _, loaded := runningBatches.LoadOrStore(batch.Id, true); loaded {
+				continue
+			}
+			b := batch
+			gopool.Go(func() {
+				defer runningBatches.Delete(b.Id)
+				runBatch(b)
+			})
+		}
+	}
+}
+
+func failBatch(batch *model.Batch, reason string) {
+	if err := model.FailBatch(batch.Id, reason); err != nil {
+		common.SysError(fmt.Sprintf("update batch %s failed: %s", batch.BatchId, err.Error()))
+	}
+}
+
+func readBatchInput(batch *model.Batch) ([]dto.BatchInputLine, error) {
+	inputFile, err := model.GetUserFileByFileId(batch.UserId, batch.InputFileId)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := service.OpenFileContent(inputFile)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	maxRequests := operation_setting.GetBatchSetting().MaxRequests
+	lines := make([]dto.BatchInputLine, 0)
+	customIds := make(map[string]bool)
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 1024*1024), 100*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		var line dto.BatchInputLine
+		if err = common.Unmarshal(raw, &line); err != nil {
+			return nil, fmt.Errorf("line %d: invalid json: %s", lineNo, err.Error())
+		}
+		if line.CustomId == "" {
+			return nil, fmt.Errorf("line %d: custom_id is required", lineNo)
+		}
+		if customIds[line.CustomId] {
+			return nil, fmt.Errorf("line %d: duplicate custom_id %s", lineNo, line.CustomId)
+		}
+		customIds[line.CustomId] = true
+		if line.Method != http.MethodPost {
+			return nil, fmt.Errorf("line %d: method must be POST", lineNo)
+		}
+		if line.Url != batch.Endpoint {
+			return nil, fmt.Errorf("line %d: url %s does not match batch endpoint %s", lineNo, line.Url, batch.Endpoint)
+		}
+		lines = append(lines, line)
+		if maxRequests > 0 && len(lines) > maxRequests {
+			return nil, fmt.Errorf("batch exceeds the maximum of %d requests", maxRequests)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, errors.New("input file is empty")
+	}
+	return lines, nil
+}
+
+// runBatch 在本地通过正常的 relay 链路逐条执行批处理请求
+func runBatch(batch *model.Batch) {
+	if batch.CancellingAt != 0 {
+		failBatch(batch, "")
+		return
+	}
+	token, err := model.GetTokenById(batch.TokenId)
+	if err != nil {
+		failBatch(batch, "token not found")
+		return
+	}
+	lines, err := readBatchInput(batch)
+	if err != nil {
+		failBatch(batch, err.Error())
+		return
+	}
+	batch.RequestTotal = len(lines)
+	started, err := model.StartBatch(batch)
+	if err != nil {
+		common.SysError(fmt.Sprintf("update batch %s failed: %s", batch.BatchId, err.Error()))
+		return
+	}
+	if !started {
+		// 领取前已被标记取消，补完取消流程
+		if latest, err := model.GetBatchById(batch.Id); err == nil && latest.CancellingAt != 0 {
+			_, _ = model.CancelSubmittedBatch(batch.Id)
+		}
+		return
+	}
+
+	outputTmp, err := os.CreateTemp("", "batch_output_*.jsonl")
+	if err != nil {
+		failBatch(batch, err.Error())
+		return
+	}
+	defer os.Remove(outputTmp.Name())
+	defer outputTmp.Close()
+	errorTmp, err := os.CreateTemp("", "batch_error_*.jsonl")
+	if err != nil {
+		failBatch(batch, err.Error())
+		return
+	}
+	defer os.Remove(errorTmp.Name())
+	defer errorTmp.Close()
+
+	var writeLock sync.Mutex
+	var wg sync.WaitGroup
+	sem := getBatchWorkerSem()
+	stopped := false
+	for i, line := range lines {
+		if i%20 == 0 {
+			if latest, err := model.GetBatchById(batch.Id); err == nil && latest.CancellingAt != 0 {
+				batch.CancellingAt = latest.CancellingAt
+				stopped = true
+				break
+			}
+			if batch.ExpiresAt != 0 && common.GetTimestamp() > batch.ExpiresAt {
+				stopped = true
+				break
+			}
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		inputLine := line
+		gopool.Go(func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			output, success := executeBatchLine(batch, token, inputLine)
+			data, _ := common.Marshal(output)
+			writeLock.Lock()
+			defer writeLock.Unlock()
+			if success {
+				batch.RequestCompleted++
+				_, _ = outputTmp.Write(append(data, '\n'))
+			} else {
+				batch.RequestFailed++
+				_, _ = errorTmp.Write(append(data, '\n'))
+			}
+			if (batch.RequestCompleted+batch.RequestFailed)%50 == 0 {
+				_ = batch.UpdateProgress()
+			}
+		})
+	}
+	wg.Wait()
+
+	now := common.GetTimestamp()
+	batch.FinalizingAt = now
+	_ = batch.UpdateProgress()
+	if batch.RequestCompleted > 0 {
+		batch.OutputFileId = saveBatchResultFile(batch, outputTmp, "output")
+	}
+	if batch.RequestFailed > 0 {
+		batch.ErrorFileId = saveBatchResultFile(batch, errorTmp, "error")
+	}
+	if stopped {
+		batch.Status = model.TaskStatusFailure
+		if batch.CancellingAt != 0 {
+			batch.CancelledAt = now
+		} else {
+			batch.ExpiredAt = now
+		}
+	} else {
+		batch.Status = model.TaskStatusSuccess
+		batch.CompletedAt = now
+	}
+	if err = batch.FinishLocalBatch(); err != nil {
+		common.SysError(fmt.Sprintf("update batch %s failed: %s", batch.BatchId, err.Error()))
+	}
+}
+
+func saveBatchResultFile(batch *model.Batch, tmp *os.File, kind string) string {
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		common.SysError(fmt.Sprintf("batch %s seek %s file failed: %s", batch.BatchId, kind, err.Error()))
+		return ""
+	}
+	file := &model.File{
+		UserId:   batch.UserId,
+		TokenId:  batch.TokenId,
+		Filename: fmt.Sprintf("%s_%s.jsonl", batch.BatchId, kind),
+		Purpose:  "batch_output",
+		MimeType: "application/jsonl",
+	}
+	if err := service.SaveLocalFile(file, tmp); err != nil {
+		common.SysError(fmt.Sprintf("batch %s save %s file failed: %s", batch.BatchId, kind, err.Error()))
+		return ""
+	}
+	return file.FileId
+}
+
+// executeBatchLine 构造请求上下文并走 Distribute + Relay，与在线请求保持同样的选路、重试和计费逻辑
+func executeBatchLine(batch *model.Batch, token *model.Token, line dto.BatchInputLine) (*dto.BatchOutputLine, bool) {
+	output := &dto.BatchOutputLine{
+		Id:       "batch_req_" + common.GetRandomString(24),
+		CustomId: line.CustomId,
+	}
+	lineError := func(code string, message string) (*dto.BatchOutputLine, bool) {
+		output.Error = &dto.BatchLineError{Code: code, Message: message}
+		return output, false
+	}
+	if _, err := model.ValidateUserToken(token.Key); err != nil {
+		return lineError("invalid_token", err.Error())
+	}
+	userCache, err := model.GetUserCache(batch.UserId)
+	if err != nil {
+		return lineError("internal_error", err.Error())
+	}
+	if userCache.Status != common.UserStatusEnabled {
+		return lineError("user_disabled", "用户已被封禁")
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, line.Url, bytes.NewReader(line.Body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	requestId := common.GetTimeString() + common.GetRandomString(8)
+	c.Set(common.RequestIdKey, requestId)
+	userCache.WriteContext(c)
+	if err = middleware.SetupContextForToken(c, token); err != nil {
+		return lineError("invalid_token", err.Error())
+	}
+	common.SetContextKey(c, constant.ContextKeyBatchRequest, true)
+
+	middleware.Distribute()(c)
+	if !c.IsAborted() {
+		release := acquireBatchChannelSlot(common.GetContextKeyInt(c, constant.ContextKeyChannelId))
+		Relay(c)
+		release()
+	}
+
+	body := w.Body.Bytes()
+	if !json.Valid(body) {
+		body, _ = common.Marshal(string(body))
+	}
+	output.Response = &dto.BatchOutputResponse{
+		StatusCode: w.Code,
+		RequestId:  requestId,
+		Body:       body,
+	}
+	return output, w.Code == http.StatusOK
+}
+
+func applyUpstreamBatch(batch *model.Batch, upstreamBatch *dto.OpenAIBatch) {
+	deref := func(v *int64) int64 {
+		if v == nil {
+			return 0
+		}
+		return *v
+	}
+	switch upstreamBatch.Status {
+	case "completed":
+		batch.Status = model.TaskStatusSuccess
+	case "failed", "expired", "cancelled":
+		batch.Status = model.TaskStatusFailure
+	case "in_progress", "finalizing", "cancelling":
+		batch.Status = model.TaskStatusInProgress
+	default:
+		batch.Status = model.TaskStatusSubmitted
+	}
+	if upstreamBatch.OutputFileId != nil {
+		batch.OutputFileId = *upstreamBatch.OutputFileId
+	}
+	if upstreamBatch.ErrorFileId != nil {
+		batch.ErrorFileId = *upstreamBatch.ErrorFileId
+	}
+	if upstreamBatch.Errors != nil && len(upstreamBatch.Errors.Data) > 0 {
+		batch.FailReason = upstreamBatch.Errors.Data[0].Message
+	}
+	if upstreamBatch.CreatedAt != 0 {
+		batch.CreatedAt = upstreamBatch.CreatedAt
+	}
+	batch.InProgressAt = deref(upstreamBatch.InProgressAt)
+	batch.ExpiresAt = deref(upstreamBatch.ExpiresAt)
+	batch.FinalizingAt = deref(upstreamBatch.FinalizingAt)
+	batch.CompletedAt = deref(upstreamBatch.CompletedAt)
+	batch.FailedAt = deref(upstreamBatch.FailedAt)
+	batch.ExpiredAt = deref(upstreamBatch.ExpiredAt)
+	batch.CancellingAt = deref(upstreamBatch.CancellingAt)
+	batch.CancelledAt = deref(upstreamBatch.CancelledAt)
+	batch.RequestTotal = upstreamBatch.RequestCounts.Total
+	batch.RequestCompleted = upstreamBatch.RequestCounts.Completed
+	batch.RequestFailed = upstreamBatch.RequestCounts.Failed
+}
+
+// syncUpstreamBatch 同步上游批处理状态，完成时登记结果文件并结算
+func syncUpstreamBatch(batch *model.Batch) {
+	channel, err := model.CacheGetChannel(batch.ChannelId)
+	if err != nil {
+		return
+	}
+	upstreamBatch, err := doUpstreamBatchRequest(channel, batch.KeyIndex, http.MethodGet, "/v1/batches/"+batch.BatchId, nil)
+	if err != nil {
+		common.SysError(fmt.Sprintf("sync upstream batch %s failed: %s", batch.BatchId, err.Error()))
+		return
+	}
+	wasFinished := batch.IsFinished()
+	applyUpstreamBatch(batch, upstreamBatch)
+	if !wasFinished && batch.IsFinished() {
+		// 只有成功切换状态的一方负责结算
+		finished, err := model.MarkBatchFinished(batch.Id, batch.Status)
+		if err != nil {
+			common.SysError(fmt.Sprintf("update batch %s failed: %s", batch.BatchId, err.Error()))
+			return
+		}
+		if !finished {
+			if latest, err := model.GetBatchById(batch.Id); err == nil {
+				*batch = *latest
+			}
+			return
+		}
+		files := make([]*model.File, 0, 2)
+		for _, fileId := range []string{batch.OutputFileId, batch.ErrorFileId} {
+			if fileId == "" {
+				continue
+			}
+			files = append(files, &model.File{
+				FileId:    fileId,
+				UserId:    batch.UserId,
+				TokenId:   batch.TokenId,
+				ChannelId: batch.ChannelId,
+				KeyIndex:  batch.KeyIndex,
+				Filename:  fileId + ".jsonl",
+				Purpose:   "batch_output",
+				MimeType:  "application/jsonl",
+				Storage:   model.FileStorageUpstream,
+				Status:    model.FileStatusProcessed,
+			})
+		}
+		settled := true
+		if batch.OutputFileId != "" {
+			if err = settleUpstreamBatch(batch, files[0]); err != nil {
+				// 上游已执行完成，不再回退状态；无法结算时保留预扣额度作为扣费
+				common.SysError(fmt.Sprintf("settle upstream batch %s failed, keep pre-consumed quota %d: %s", batch.BatchId, batch.PreConsumedQuota, err.Error()))
+				settled = false
+			}
+		}
+		if settled {
+			returnUpstreamBatchQuota(batch)
+		}
+		for _, file := range files {
+			if err = file.Insert(); err != nil {
+				common.SysError(fmt.Sprintf("record upstream batch file %s failed: %s", file.FileId, err.Error()))
+			}
+		}
+	}
+	if err = batch.SaveUpstreamState(); err != nil {
+		common.SysError(fmt.Sprintf("update batch %s failed: %s", batch.BatchId, err.Error()))
+	}
+}
+
+// readBatchInputModels 读取输入文件中每个请求使用的模型，按 custom_id 索引
+func readBatchInputModels(batch *model.Batch) (map[string]string, error) {
+	inputFile, err := model.GetUserFileByFileId(batch.UserId, batch.InputFileId)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := service.OpenFileContent(inputFile)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	models := make(map[string]string)
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 1024*1024), 100*1024*1024)
+	for scanner.Scan() {
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		var line dto.BatchInputLine
+		if err = common.Unmarshal(raw, &line); err != nil {
+			return nil, err
+		}
+		var body struct {
+			Model string `json:"model"`
+		}
+		if err = common.Unmarshal(line.Body, &body); err != nil {
+			return nil, fmt.Errorf("request %s: invalid body: %s", line.CustomId, err.Error())
+		}
+		models[line.CustomId] = body.Model
+	}
+	return models, scanner.Err()
+}
+
+// newUpstreamBatchRelayInfo 构造上游批处理预扣和结算使用的 RelayInfo
+func newUpstreamBatchRelayInfo(batch *model.Batch, token *model.Token, userCache *model.UserBase, modelName string, upstreamModelName string) *relaycommon.RelayInfo {
+	group := token.Group
+	if group == "" {
+		group = userCache.Group
+	}
+	return &relaycommon.RelayInfo{
+		UserId:            batch.UserId,
+		UserQuota:         userCache.Quota,
+		UserGroup:         userCache.Group,
+		UserSetting:       userCache.GetSetting(),
+		UsingGroup:        group,
+		TokenId:           batch.TokenId,
+		TokenKey:          token.Key,
+		TokenUnlimited:    token.UnlimitedQuota,
+		OrganizationId:    batch.OrganizationId,
+		ChannelId:         batch.ChannelId,
+		OriginModelName:   modelName,
+		UpstreamModelName: upstreamModelName,
+		StartTime:         time.Now(),
+		IsBatch:           true,
+		BatchSettle:       true,
+	}
+}
+
+// returnUpstreamBatchQuota 退回上游批处理创建时预扣的额度，令牌已删除时只退回用户或组织额度
+func returnUpstreamBatchQuota(batch *model.Batch) {
+	if batch.PreConsumedQuota <= 0 {
+		return
+	}
+	token, err := model.GetTokenById(batch.TokenId)
+	if err != nil {
+		err = model.IncreaseBillingQuota(batch.UserId, batch.OrganizationId, batch.PreConsumedQuota)
+	} else {
+		err = service.PostConsumeQuota(&relaycommon.RelayInfo{
+			UserId:         batch.UserId,
+			TokenId:        batch.TokenId,
+			TokenKey:       token.Key,
+			TokenUnlimited: token.UnlimitedQuota,
+			OrganizationId: batch.OrganizationId,
+		}, -batch.PreConsumedQuota, 0, false)
+	}
+	if err != nil {
+		common.SysError(fmt.Sprintf("return pre-consumed quota of batch %s failed: %s", batch.BatchId, err.Error()))
+	}
+}
+
+// settleUpstreamBatch 按输出文件中每个请求的 usage 结算上游执行的批处理任务，与在线请求使用同样的定价和结算流程；
+// 模型取自输入文件中对应的请求，无法定价的请求记录日志后跳过，其余请求照常结算
+func settleUpstreamBatch(batch *model.Batch, outputFile *model.File) error {
+	token, err := model.GetTokenById(batch.TokenId)
+	if err != nil {
+		return err
+	}
+	userCache, err := model.GetUserCache(batch.UserId)
+	if err != nil {
+		return err
+	}
+	models, err := readBatchInputModels(batch)
+	if err != nil {
+		return fmt.Errorf("read input file failed: %s", err.Error())
+	}
+	reader, err := service.OpenFileContent(outputFile)
+	if err != nil {
+		return fmt.Errorf("read output file failed: %s", err.Error())
+	}
+	defer reader.Close()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, batch.Endpoint, nil)
+	userCache.WriteContext(c)
+	if err = middleware.SetupContextForToken(c, token); err != nil {
+		return err
+	}
+	common.SetContextKey(c, constant.ContextKeyOrganizationId, batch.OrganizationId)
+
+	type batchSettlement struct {
+		relayInfo *relaycommon.RelayInfo
+		usage     dto.Usage
+		priceData helper.PriceData
+	}
+	settlements := make([]batchSettlement, 0)
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 1024*1024), 100*1024*1024)
+	for scanner.Scan() {
+		var line dto.BatchOutputLine
+		if err = common.Unmarshal(scanner.Bytes(), &line); err != nil || line.Response == nil || line.Response.StatusCode != http.StatusOK {
+			continue
+		}
+		var body struct {
+			Model string    `json:"model"`
+			Usage dto.Usage `json:"usage"`
+		}
+		if err = common.Unmarshal(line.Response.Body, &body); err != nil {
+			continue
+		}
+		modelName := models[line.CustomId]
+		if modelName == "" {
+			common.SysError(fmt.Sprintf("settle upstream batch %s: request %s not found in input file, skipped", batch.BatchId, line.CustomId))
+			continue
+		}
+		upstreamModelName := body.Model
+		if upstreamModelName == "" {
+			upstreamModelName = modelName
+		}
+		relayInfo := newUpstreamBatchRelayInfo(batch, token, userCache, modelName, upstreamModelName)
+		priceData, err := helper.ModelPriceHelper(c, relayInfo, body.Usage.PromptTokens, 0)
+		if err != nil {
+			common.SysError(fmt.Sprintf("settle upstream batch %s: request %s cannot be priced, skipped: %s", batch.BatchId, line.CustomId, err.Error()))
+			continue
+		}
+		settlements = append(settlements, batchSettlement{relayInfo: relayInfo, usage: body.Usage, priceData: priceData})
+	}
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("read output file failed: %s", err.Error())
+	}
+	for _, settlement := range settlements {
+		batch.Quota += relay.SettleUsage(c, settlement.relayInfo, &settlement.usage, settlement.priceData, "上游批处理 "+batch.BatchId)
+	}
+	return nil
+}
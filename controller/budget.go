@@ -0,0 +1,181 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetAllBudgets(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	budgets, total, err := model.GetAllBudgets(c.Query("scope"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(budgets)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// AddBudget 管理员为用户、令牌或分组设置预算
+func AddBudget(c *gin.Context) {
+	budget := model.Budget{}
+	if err := c.ShouldBindJSON(&budget); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if budget.Scope == model.BudgetScopeToken {
+		token, err := model.GetTokenById(budget.TokenId)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		budget.UserId = token.UserId
+	}
+	if err := budget.Validate(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	budget.Id = 0
+	budget.Locked = true
+	if err := budget.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    budget,
+	})
+}
+
+// UpdateBudget 修改预算额度、提醒比例或周期，范围和对象不能修改
+func UpdateBudget(c *gin.Context) {
+	budget := model.Budget{}
+	if err := c.ShouldBindJSON(&budget); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	old, err := model.GetBudgetById(budget.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	old.Period = budget.Period
+	old.QuotaLimit = budget.QuotaLimit
+	old.SoftLimitPercent = budget.SoftLimitPercent
+	if err = old.Validate(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = old.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func DeleteBudget(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	budget, err := model.GetBudgetById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = budget.Delete(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// AddTokenBudget 用户为自己的令牌设置预算
+func AddTokenBudget(c *gin.Context) {
+	tokenId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	userId := c.GetInt64("id")
+	token, err := model.GetTokenByIds(tokenId, userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	budget := model.Budget{}
+	if err = c.ShouldBindJSON(&budget); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	budget = model.Budget{
+		Scope:            model.BudgetScopeToken,
+		UserId:           userId,
+		TokenId:          token.Id,
+		Period:           budget.Period,
+		QuotaLimit:       budget.QuotaLimit,
+		SoftLimitPercent: budget.SoftLimitPercent,
+	}
+	if err = budget.Validate(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = budget.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    budget,
+	})
+}
+
+// DeleteTokenBudget 用户删除自己令牌的预算，管理员设置的预算不能删除
+func DeleteTokenBudget(c *gin.Context) {
+	tokenId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	budgetId, err := strconv.Atoi(c.Param("budget_id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	budget, err := model.GetBudgetById(budgetId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if budget.Scope != model.BudgetScopeToken || budget.TokenId != tokenId || budget.UserId != c.GetInt64("id") {
+		common.ApiError(c, errors.New("预算不存在"))
+		return
+	}
+	if budget.Locked {
+		common.ApiError(c, errors.New("管理员设置的预算不能删除"))
+		return
+	}
+	if err = budget.Delete(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
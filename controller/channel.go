@@ -393,7 +393,11 @@ func validateChannel(channel *model.Channel, isAdd bool) error {
 type AddChannelRequest struct {
 	Mode         string                `json:"mode"`
 	MultiKeyMode constant.MultiKeyMode `json:"multi_key_mode"`
-	Channel      *model.Channel        `json:"channel"`
+	// token_bucket 模式下每个 Key 的限额，以及 Key 被限流后的冷却时间
+	MultiKeyRPM             int            `json:"multi_key_rpm"`
+	MultiKeyTPM             int            `json:"multi_key_tpm"`
+	MultiKeyCooldownSeconds int            `json:"multi_key_cooldown_seconds"`
+	Channel                 *model.Channel `json:"channel"`
 }
 
 func getVertexArrayKeys(keys string) ([]string, error) {
@@ -451,6 +455,9 @@ func AddChannel(c *gin.Context) {
 	case "multi_to_single":
 		addChannelRequest.Channel.ChannelInfo.IsMultiKey = true
 		addChannelRequest.Channel.ChannelInfo.MultiKeyMode = addChannelRequest.MultiKeyMode
+		addChannelRequest.Channel.ChannelInfo.MultiKeyRPM = addChannelRequest.MultiKeyRPM
+		addChannelRequest.Channel.ChannelInfo.MultiKeyTPM = addChannelRequest.MultiKeyTPM
+		addChannelRequest.Channel.ChannelInfo.MultiKeyCooldownSeconds = addChannelRequest.MultiKeyCooldownSeconds
 		if addChannelRequest.Channel.Type == constant.ChannelTypeVertexAi {
 			array, err := getVertexArrayKeys(addChannelRequest.Channel.Key)
 			if err != nil {
@@ -668,7 +675,10 @@ func DeleteChannelBatch(c *gin.Context) {
 
 type PatchChannel struct {
 	model.Channel
-	MultiKeyMode *string `json:"multi_key_mode"`
+	MultiKeyMode            *string `json:"multi_key_mode"`
+	MultiKeyRPM             *int    `json:"multi_key_rpm"`
+	MultiKeyTPM             *int    `json:"multi_key_tpm"`
+	MultiKeyCooldownSeconds *int    `json:"multi_key_cooldown_seconds"`
 }
 
 func UpdateChannel(c *gin.Context) {
@@ -704,6 +714,15 @@ func UpdateChannel(c *gin.Context) {
 	if channel.MultiKeyMode != nil && *channel.MultiKeyMode != "" {
 		channel.ChannelInfo.MultiKeyMode = constant.MultiKeyMode(*channel.MultiKeyMode)
 	}
+	if channel.MultiKeyRPM != nil {
+		channel.ChannelInfo.MultiKeyRPM = *channel.MultiKeyRPM
+	}
+	if channel.MultiKeyTPM != nil {
+		channel.ChannelInfo.MultiKeyTPM = *channel.MultiKeyTPM
+	}
+	if channel.MultiKeyCooldownSeconds != nil {
+		channel.ChannelInfo.MultiKeyCooldownSeconds = *channel.MultiKeyCooldownSeconds
+	}
 	err = channel.Update()
 	if err != nil {
 		common.ApiError(c, err)
@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type channelKeyStatusRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+type channelKeyAppendRequest struct {
+	Keys []string `json:"keys"`
+}
+
+func parseChannelKeyParams(c *gin.Context, withIndex bool) (int, int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的渠道 ID",
+		})
+		return 0, 0, false
+	}
+	if !withIndex {
+		return id, 0, true
+	}
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的 Key 索引",
+		})
+		return 0, 0, false
+	}
+	return id, index, true
+}
+
+// GetChannelKeys 查看多 Key 渠道各 Key 的状态和统计
+func GetChannelKeys(c *gin.Context) {
+	id, _, ok := parseChannelKeyParams(c, false)
+	if !ok {
+		return
+	}
+	keys, err := model.GetChannelKeyInfos(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    keys,
+	})
+}
+
+// UpdateChannelKeyStatus 启用或禁用多 Key 渠道中的单个 Key
+func UpdateChannelKeyStatus(c *gin.Context) {
+	id, index, ok := parseChannelKeyParams(c, true)
+	if !ok {
+		return
+	}
+	var req channelKeyStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.SetChannelKeyStatus(id, index, req.Enabled); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.InitChannelCache()
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// AppendChannelKeys 向多 Key 渠道追加 Key，无需提交完整的 Key 列表
+func AppendChannelKeys(c *gin.Context) {
+	id, _, ok := parseChannelKeyParams(c, false)
+	if !ok {
+		return
+	}
+	var req channelKeyAppendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.AppendChannelKeys(id, req.Keys); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.InitChannelCache()
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// DeleteChannelKey 删除多 Key 渠道中的单个 Key
+func DeleteChannelKey(c *gin.Context) {
+	id, index, ok := parseChannelKeyParams(c, true)
+	if !ok {
+		return
+	}
+	if err := model.RemoveChannelKey(id, index); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.InitChannelCache()
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
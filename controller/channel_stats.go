@@ -0,0 +1,17 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetChannelStats 获取渠道在线流量的滚动统计，key 为渠道 id 或 渠道 id:模型，只包含当前节点的统计
+func GetChannelStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    model.GetAllChannelStats(),
+	})
+}
@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"one-api/setting/ratio_setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// MigrateExactBillingPrices 将现有的模型倍率换算为每百万 tokens 的美元价格，已配置精确价格的模型保持不变
+func MigrateExactBillingPrices(c *gin.Context) {
+	prices := make(map[string]operation_setting.ExactModelPrice)
+	for name, price := range operation_setting.GetExactBillingSetting().ModelPrices {
+		prices[name] = price
+	}
+	// 倍率 1 对应每 token 消耗 1 额度，即每百万 tokens 1000000 / QuotaPerUnit 美元
+	unitPrice := decimal.NewFromInt(1000000).Div(decimal.NewFromFloat(common.QuotaPerUnit))
+	migrated := 0
+	for name, ratio := range ratio_setting.GetModelRatioCopy() {
+		if _, ok := prices[name]; ok {
+			continue
+		}
+		input := decimal.NewFromFloat(ratio).Mul(unitPrice).Round(10)
+		prices[name] = operation_setting.ExactModelPrice{
+			Input:  input,
+			Output: input.Mul(decimal.NewFromFloat(ratio_setting.GetCompletionRatio(name))).Round(10),
+		}
+		migrated++
+	}
+	bytes, err := json.Marshal(prices)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = model.UpdateOption("exact_billing_setting.model_prices", string(bytes)); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"migrated": migrated,
+			"total":    len(prices),
+		},
+	})
+}
@@ -0,0 +1,172 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func fileError(c *gin.Context, statusCode int, message string, param string) {
+	c.JSON(statusCode, gin.H{
+		"error": dto.OpenAIError{
+			Message: message,
+			Type:    "invalid_request_error",
+			Param:   param,
+		},
+	})
+}
+
+// selectPassThroughChannel 按模型为透传请求选择渠道，渠道不支持透传时返回 nil
+func selectPassThroughChannel(c *gin.Context, modelName string) *model.Channel {
+	group := common.GetContextKeyString(c, constant.ContextKeyUserGroup)
+	if tokenGroup := common.GetContextKeyString(c, constant.ContextKeyTokenGroup); tokenGroup != "" {
+		group = tokenGroup
+	}
+	channel, _, err := model.CacheGetRandomSatisfiedChannel(c, group, modelName, 0)
+	if err != nil || !service.SupportFilePassThrough(channel) {
+		return nil
+	}
+	return channel
+}
+
+func UploadFile(c *gin.Context) {
+	userId := c.GetInt64("id")
+	purpose := c.PostForm("purpose")
+	if purpose == "" {
+		fileError(c, http.StatusBadRequest, "Missing required parameter: 'purpose'.", "purpose")
+		return
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		fileError(c, http.StatusBadRequest, "Missing required parameter: 'file'.", "file")
+		return
+	}
+	if err = service.CheckUserFileQuota(userId, fileHeader.Size); err != nil {
+		fileError(c, http.StatusBadRequest, err.Error(), "file")
+		return
+	}
+	src, err := fileHeader.Open()
+	if err != nil {
+		fileError(c, http.StatusBadRequest, err.Error(), "file")
+		return
+	}
+	defer src.Close()
+
+	file := &model.File{
+		UserId:   userId,
+		TokenId:  c.GetInt("token_id"),
+		Filename: fileHeader.Filename,
+		Purpose:  purpose,
+		MimeType: fileHeader.Header.Get("Content-Type"),
+	}
+	if operation_setting.GetFileSetting().ShouldPassThrough(purpose) {
+		modelName := common.GetStringIfEmpty(c.PostForm("model"), operation_setting.GetFileSetting().PassThroughModel)
+		if channel := selectPassThroughChannel(c, modelName); channel != nil {
+			_, keyIndex, newAPIError := channel.GetNextEnabledKey()
+			if newAPIError != nil {
+				fileError(c, http.StatusServiceUnavailable, newAPIError.Error(), "")
+				return
+			}
+			err = service.UploadFileToChannel(channel, keyIndex, file, src)
+			if err != nil {
+				common.LogError(c, fmt.Sprintf("upload file to channel #%d failed: %s", channel.Id, err.Error()))
+				fileError(c, http.StatusBadGateway, "upload file to upstream failed", "")
+				return
+			}
+			c.JSON(http.StatusOK, service.FileToOpenAIFile(file))
+			return
+		}
+	}
+	if err = service.SaveLocalFile(file, src); err != nil {
+		common.LogError(c, "save file failed: "+err.Error())
+		fileError(c, http.StatusInternalServerError, "save file failed", "")
+		return
+	}
+	c.JSON(http.StatusOK, service.FileToOpenAIFile(file))
+}
+
+func ListFiles(c *gin.Context) {
+	userId := c.GetInt64("id")
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 10000 {
+		limit = 10000
+	}
+	// 多查一条用于判断 has_more
+	files, err := model.GetUserFiles(userId, c.Query("purpose"), c.Query("after"), limit+1, c.Query("order") == "asc")
+	if err != nil {
+		fileError(c, http.StatusBadRequest, err.Error(), "after")
+		return
+	}
+	list := dto.OpenAIFileList{
+		Object: "list",
+		Data:   make([]dto.OpenAIFile, 0, len(files)),
+	}
+	if len(files) > limit {
+		list.HasMore = true
+		files = files[:limit]
+	}
+	for _, file := range files {
+		list.Data = append(list.Data, service.FileToOpenAIFile(file))
+	}
+	if len(list.Data) > 0 {
+		list.FirstId = list.Data[0].Id
+		list.LastId = list.Data[len(list.Data)-1].Id
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func RetrieveFile(c *gin.Context) {
+	file, err := model.GetUserFileByFileId(c.GetInt64("id"), c.Param("id"))
+	if err != nil {
+		fileError(c, http.StatusNotFound, fmt.Sprintf("No such File object: %s", c.Param("id")), "id")
+		return
+	}
+	c.JSON(http.StatusOK, service.FileToOpenAIFile(file))
+}
+
+func RetrieveFileContent(c *gin.Context) {
+	file, err := model.GetUserFileByFileId(c.GetInt64("id"), c.Param("id"))
+	if err != nil {
+		fileError(c, http.StatusNotFound, fmt.Sprintf("No such File object: %s", c.Param("id")), "id")
+		return
+	}
+	reader, err := service.OpenFileContent(file)
+	if err != nil {
+		common.LogError(c, fmt.Sprintf("open file %s failed: %s", file.FileId, err.Error()))
+		fileError(c, http.StatusInternalServerError, "read file content failed", "")
+		return
+	}
+	defer reader.Close()
+	contentType := common.GetStringIfEmpty(file.MimeType, "application/octet-stream")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.Filename))
+	c.Header("Content-Type", contentType)
+	c.Status(http.StatusOK)
+	_, _ = io.Copy(c.Writer, reader)
+}
+
+func DeleteFile(c *gin.Context) {
+	file, err := model.GetUserFileByFileId(c.GetInt64("id"), c.Param("id"))
+	if err != nil {
+		fileError(c, http.StatusNotFound, fmt.Sprintf("No such File object: %s", c.Param("id")), "id")
+		return
+	}
+	if err = service.DeleteFile(file); err != nil {
+		common.LogError(c, fmt.Sprintf("delete file %s failed: %s", file.FileId, err.Error()))
+		fileError(c, http.StatusInternalServerError, "delete file failed", "")
+		return
+	}
+	c.JSON(http.StatusOK, dto.OpenAIFileDeleteResponse{
+		Id:      file.FileId,
+		Object:  "file",
+		Deleted: true,
+	})
+}
@@ -0,0 +1,333 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"one-api/types"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	fineTuneLegacyPath = "/v1/fine-tunes"
+	fineTuneJobsPath   = "/v1/fine_tuning/jobs"
+)
+
+type upstreamFineTuneJob struct {
+	Id             string  `json:"id"`
+	Model          string  `json:"model"`
+	Status         string  `json:"status"`
+	FineTunedModel *string `json:"fine_tuned_model"`
+}
+
+func fineTunePath(legacy bool) string {
+	if legacy {
+		return fineTuneLegacyPath
+	}
+	return fineTuneJobsPath
+}
+
+func isLegacyFineTune(c *gin.Context) bool {
+	return strings.HasPrefix(c.FullPath(), fineTuneLegacyPath)
+}
+
+func doUpstreamFineTuneRequest(channel *model.Channel, keyIndex int, method string, path string, body []byte) (int, []byte, error) {
+	var reader io.Reader
+	contentType := ""
+	if body != nil {
+		reader = bytes.NewReader(body)
+		contentType = "application/json"
+	}
+	resp, err := service.DoChannelFileRequest(channel, keyIndex, method, path, reader, contentType)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, respBody, nil
+}
+
+// applyUpstreamFineTuneJob 根据上游返回更新任务，微调成功后登记为用户私有模型
+func applyUpstreamFineTuneJob(job *model.FineTuneJob, respBody []byte) {
+	var upstreamJob upstreamFineTuneJob
+	if err := common.Unmarshal(respBody, &upstreamJob); err != nil || upstreamJob.Id != job.JobId {
+		return
+	}
+	job.Status = upstreamJob.Status
+	if upstreamJob.FineTunedModel != nil && *upstreamJob.FineTunedModel != "" {
+		job.FineTunedModel = *upstreamJob.FineTunedModel
+	}
+	job.Data = string(respBody)
+}
+
+// uploadFineTuneFile 本地文件需先上传到微调任务所在的渠道，返回上游 file id
+func uploadFineTuneFile(c *gin.Context, file *model.File, channel *model.Channel, keyIndex int) (string, error) {
+	if file.IsUpstream() {
+		if file.ChannelId != channel.Id {
+			return "", fmt.Errorf("file %s belongs to another channel", file.FileId)
+		}
+		return file.FileId, nil
+	}
+	reader, err := service.OpenFileContent(file)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	upstreamFile := &model.File{
+		UserId:   file.UserId,
+		TokenId:  c.GetInt("token_id"),
+		Filename: file.Filename,
+		Purpose:  file.Purpose,
+		MimeType: file.MimeType,
+	}
+	if err = service.UploadFileToChannel(channel, keyIndex, upstreamFile, reader); err != nil {
+		return "", err
+	}
+	return upstreamFile.FileId, nil
+}
+
+func CreateFineTuneJob(c *gin.Context) {
+	if !operation_setting.GetFineTuneSetting().Enabled {
+		RelayNotImplemented(c)
+		return
+	}
+	legacy := isLegacyFineTune(c)
+	userId := c.GetInt64("id")
+	request := make(map[string]any)
+	if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+		batchError(c, http.StatusBadRequest, "Invalid request body: "+err.Error(), "")
+		return
+	}
+	baseModel := common.Interface2String(request["model"])
+	trainingFileId := common.Interface2String(request["training_file"])
+	if trainingFileId == "" {
+		batchError(c, http.StatusBadRequest, "Missing required parameter: 'training_file'.", "training_file")
+		return
+	}
+	trainingFile, err := model.GetUserFileByFileId(userId, trainingFileId)
+	if err != nil {
+		batchError(c, http.StatusBadRequest, fmt.Sprintf("No such File object: %s", trainingFileId), "training_file")
+		return
+	}
+
+	// 训练文件已在上游时沿用其渠道，否则按基础模型选择支持微调的渠道
+	var channel *model.Channel
+	keyIndex := 0
+	if trainingFile.IsUpstream() {
+		channel, err = model.CacheGetChannel(trainingFile.ChannelId)
+		if err != nil || channel.Status != common.ChannelStatusEnabled {
+			batchError(c, http.StatusBadRequest, "the channel bound to the training file is unavailable", "training_file")
+			return
+		}
+		keyIndex = trainingFile.KeyIndex
+	} else {
+		channel = selectPassThroughChannel(c, common.GetStringIfEmpty(baseModel, operation_setting.GetFineTuneSetting().DefaultModel))
+		if channel == nil {
+			batchError(c, http.StatusServiceUnavailable, fmt.Sprintf("no channel supports fine-tuning model %s", baseModel), "model")
+			return
+		}
+		var newAPIError *types.NewAPIError
+		_, keyIndex, newAPIError = channel.GetNextEnabledKey()
+		if newAPIError != nil {
+			batchError(c, http.StatusServiceUnavailable, newAPIError.Error(), "")
+			return
+		}
+	}
+	for _, param := range []string{"training_file", "validation_file"} {
+		fileId := common.Interface2String(request[param])
+		if fileId == "" {
+			continue
+		}
+		file, err := model.GetUserFileByFileId(userId, fileId)
+		if err != nil {
+			batchError(c, http.StatusBadRequest, fmt.Sprintf("No such File object: %s", fileId), param)
+			return
+		}
+		upstreamFileId, err := uploadFineTuneFile(c, file, channel, keyIndex)
+		if err != nil {
+			common.LogError(c, fmt.Sprintf("prepare fine-tune file %s failed: %s", fileId, err.Error()))
+			batchError(c, http.StatusBadRequest, err.Error(), param)
+			return
+		}
+		request[param] = upstreamFileId
+	}
+
+	body, err := common.Marshal(request)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	statusCode, respBody, err := doUpstreamFineTuneRequest(channel, keyIndex, http.MethodPost, fineTunePath(legacy), body)
+	if err != nil {
+		common.LogError(c, fmt.Sprintf("create fine-tune job on channel #%d failed: %s", channel.Id, err.Error()))
+		batchError(c, http.StatusBadGateway, "create fine-tune job on upstream failed", "")
+		return
+	}
+	if statusCode == http.StatusOK {
+		var upstreamJob upstreamFineTuneJob
+		if err = common.Unmarshal(respBody, &upstreamJob); err == nil && upstreamJob.Id != "" {
+			job := &model.FineTuneJob{
+				JobId:     upstreamJob.Id,
+				UserId:    userId,
+				TokenId:   c.GetInt("token_id"),
+				ChannelId: channel.Id,
+				KeyIndex:  keyIndex,
+				Legacy:    legacy,
+				BaseModel: common.GetStringIfEmpty(upstreamJob.Model, baseModel),
+			}
+			applyUpstreamFineTuneJob(job, respBody)
+			if err = job.Insert(); err != nil {
+				common.LogError(c, "record fine-tune job failed: "+err.Error())
+			}
+		}
+	}
+	c.Data(statusCode, "application/json", respBody)
+}
+
+func ListFineTuneJobs(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	jobs, err := model.GetUserFineTuneJobs(c.GetInt64("id"), isLegacyFineTune(c), c.Query("after"), limit+1)
+	if err != nil {
+		batchError(c, http.StatusBadRequest, err.Error(), "after")
+		return
+	}
+	hasMore := len(jobs) > limit
+	if hasMore {
+		jobs = jobs[:limit]
+	}
+	data := make([]any, 0, len(jobs))
+	for _, job := range jobs {
+		var detail map[string]any
+		if err = common.UnmarshalJsonStr(job.Data, &detail); err != nil {
+			detail = map[string]any{"id": job.JobId, "model": job.BaseModel, "status": job.Status, "fine_tuned_model": job.FineTunedModel}
+		}
+		data = append(data, detail)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"object":   "list",
+		"data":     data,
+		"has_more": hasMore,
+	})
+}
+
+// relayFineTuneJob 将任务相关请求转发到创建任务的渠道
+func relayFineTuneJob(c *gin.Context, method string, suffix string, updateJob bool) {
+	job, err := model.GetUserFineTuneJob(c.GetInt64("id"), c.Param("id"))
+	if err != nil {
+		batchError(c, http.StatusNotFound, fmt.Sprintf("No such fine-tune job: %s", c.Param("id")), "id")
+		return
+	}
+	channel, err := model.CacheGetChannel(job.ChannelId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	path := fineTunePath(job.Legacy) + "/" + job.JobId + suffix
+	if c.Request.URL.RawQuery != "" {
+		path += "?" + c.Request.URL.RawQuery
+	}
+	var body []byte
+	if method == http.MethodPost {
+		body = []byte("{}")
+	}
+	statusCode, respBody, err := doUpstreamFineTuneRequest(channel, job.KeyIndex, method, path, body)
+	if err != nil {
+		common.LogError(c, fmt.Sprintf("relay fine-tune job %s failed: %s", job.JobId, err.Error()))
+		batchError(c, http.StatusBadGateway, "request upstream fine-tune job failed", "")
+		return
+	}
+	if updateJob && statusCode == http.StatusOK {
+		applyUpstreamFineTuneJob(job, respBody)
+		_ = job.Update()
+	}
+	c.Data(statusCode, "application/json", respBody)
+}
+
+func RetrieveFineTuneJob(c *gin.Context) {
+	relayFineTuneJob(c, http.MethodGet, "", true)
+}
+
+func CancelFineTuneJob(c *gin.Context) {
+	relayFineTuneJob(c, http.MethodPost, "/cancel", true)
+}
+
+func ListFineTuneEvents(c *gin.Context) {
+	relayFineTuneJob(c, http.MethodGet, "/events", false)
+}
+
+func ListFineTuneCheckpoints(c *gin.Context) {
+	relayFineTuneJob(c, http.MethodGet, "/checkpoints", false)
+}
+
+// DeleteFineTunedModel 删除用户私有的微调模型
+func DeleteFineTunedModel(c *gin.Context) {
+	modelName := c.Param("model")
+	job, err := model.GetUserFineTunedModel(c.GetInt64("id"), modelName)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": dto.OpenAIError{
+				Message: fmt.Sprintf("The model '%s' does not exist", modelName),
+				Type:    "invalid_request_error",
+				Param:   "model",
+				Code:    "model_not_found",
+			},
+		})
+		return
+	}
+	channel, err := model.CacheGetChannel(job.ChannelId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	statusCode, respBody, err := doUpstreamFineTuneRequest(channel, job.KeyIndex, http.MethodDelete, "/v1/models/"+modelName, nil)
+	if err != nil {
+		common.LogError(c, fmt.Sprintf("delete fine-tuned model %s failed: %s", modelName, err.Error()))
+		batchError(c, http.StatusBadGateway, "delete model on upstream failed", "")
+		return
+	}
+	if statusCode == http.StatusOK {
+		job.Deleted = true
+		_ = job.Update()
+	}
+	c.Data(statusCode, "application/json", respBody)
+}
+
+func UpdateFineTuneJobs() {
+	for {
+		interval := operation_setting.GetFineTuneSetting().PollIntervalSeconds
+		if interval <= 0 {
+			interval = 60
+		}
+		time.Sleep(time.Duration(interval) * time.Second)
+		for _, job := range model.GetUnfinishedFineTuneJobs(100) {
+			channel, err := model.CacheGetChannel(job.ChannelId)
+			if err != nil {
+				continue
+			}
+			statusCode, respBody, err := doUpstreamFineTuneRequest(channel, job.KeyIndex, http.MethodGet, fineTunePath(job.Legacy)+"/"+job.JobId, nil)
+			if err != nil || statusCode != http.StatusOK {
+				continue
+			}
+			applyUpstreamFineTuneJob(job, respBody)
+			if err = job.Update(); err != nil {
+				common.SysError(fmt.Sprintf("update fine-tune job %s failed: %s", job.JobId, err.Error()))
+			}
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpdateUserBillingMode 管理员设置用户的后付费模式和信用额度
+func UpdateUserBillingMode(c *gin.Context) {
+	var req struct {
+		Id          int64 `json:"id"`
+		PostPaid    bool  `json:"post_paid"`
+		CreditLimit int   `json:"credit_limit"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	user, err := model.GetUserById(req.Id, false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	myRole := c.GetInt("role")
+	if myRole <= user.Role && myRole != common.RoleRootUser {
+		common.ApiError(c, errors.New("无权更新同权限等级或更高权限等级的用户信息"))
+		return
+	}
+	if err = model.UpdateUserBillingMode(req.Id, req.PostPaid, req.CreditLimit); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.PostPaid {
+		model.RecordLog(req.Id, model.LogTypeManage, fmt.Sprintf("管理员将用户设置为后付费，信用额度 %s", common.LogQuota(req.CreditLimit)))
+	} else if user.PostPaid {
+		model.RecordLog(req.Id, model.LogTypeManage, "管理员将用户设置为预付费")
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func GetAllInvoices(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	userId, _ := strconv.ParseInt(c.Query("user_id"), 10, 64)
+	status, _ := strconv.Atoi(c.Query("status"))
+	invoices, total, err := model.GetAllInvoices(userId, status, c.Query("period"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(invoices)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetUserInvoices(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	status, _ := strconv.Atoi(c.Query("status"))
+	invoices, total, err := model.GetAllInvoices(c.GetInt64("id"), status, c.Query("period"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(invoices)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// getInvoiceByParam 管理员可以查看所有账单，普通用户只能查看自己的账单
+func getInvoiceByParam(c *gin.Context, self bool) (*model.Invoice, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, err
+	}
+	var userId int64
+	if self {
+		userId = c.GetInt64("id")
+	}
+	return model.GetInvoiceById(id, userId)
+}
+
+func getInvoice(c *gin.Context, self bool) {
+	invoice, err := getInvoiceByParam(c, self)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    invoice,
+	})
+}
+
+func GetInvoice(c *gin.Context) {
+	getInvoice(c, false)
+}
+
+func GetUserInvoice(c *gin.Context) {
+	getInvoice(c, true)
+}
+
+// exportInvoice 按 format 参数导出 csv 或 pdf，默认 csv
+func exportInvoice(c *gin.Context, self bool) {
+	invoice, err := getInvoiceByParam(c, self)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	var data []byte
+	var contentType string
+	format := c.DefaultQuery("format", "csv")
+	switch format {
+	case "csv":
+		data, err = service.ExportInvoiceCSV(invoice)
+		contentType = "text/csv; charset=utf-8"
+	case "pdf":
+		data, err = service.ExportInvoicePDF(invoice)
+		contentType = "application/pdf"
+	default:
+		common.ApiError(c, errors.New("不支持的导出格式"))
+		return
+	}
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=invoice-%s-%d.%s", invoice.Period, invoice.Id, format))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+func ExportInvoice(c *gin.Context) {
+	exportInvoice(c, false)
+}
+
+func ExportUserInvoice(c *gin.Context) {
+	exportInvoice(c, true)
+}
+
+// GenerateInvoice 管理员手动生成账单，不指定用户时为所有尚未出账的后付费用户生成
+func GenerateInvoice(c *gin.Context) {
+	var req struct {
+		UserId int64  `json:"user_id"`
+		Period string `json:"period"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.Period == "" {
+		req.Period = model.LastInvoicePeriod(time.Now())
+	}
+	if req.UserId == 0 {
+		if _, _, err := model.InvoicePeriodRange(req.Period); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		count, err := model.GenerateMonthlyInvoices(req.Period)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "",
+			"data":    count,
+		})
+		return
+	}
+	invoice, err := model.GenerateInvoice(req.UserId, req.Period)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    invoice,
+	})
+}
+
+// UpdateInvoiceStatus 管理员标记账单已支付或作废
+func UpdateInvoiceStatus(c *gin.Context) {
+	var req struct {
+		Id     int    `json:"id"`
+		Status int    `json:"status"`
+		Remark string `json:"remark"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if len(req.Remark) > 255 {
+		common.ApiError(c, errors.New("备注过长"))
+		return
+	}
+	switch req.Status {
+	case model.InvoiceStatusPaid:
+		invoice, err := model.MarkInvoicePaid(req.Id, req.Remark)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		model.RecordLog(invoice.UserId, model.LogTypeTopup, fmt.Sprintf("账单 %s 已支付，恢复额度 %s", invoice.Period, common.LogQuota(invoice.Quota)))
+	case model.InvoiceStatusVoid:
+		if err := model.VoidInvoice(req.Id, req.Remark); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	default:
+		common.ApiError(c, errors.New("无效的账单状态"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// AutomaticallyGenerateInvoices 每小时检查一次，为后付费用户生成上个月的账单
+func AutomaticallyGenerateInvoices() {
+	for {
+		period := model.LastInvoicePeriod(time.Now())
+		count, err := model.GenerateMonthlyInvoices(period)
+		if err != nil {
+			common.SysError("generate monthly invoices failed: " + err.Error())
+		} else if count > 0 {
+			common.SysLog(fmt.Sprintf("generated %d invoices for %s", count, period))
+		}
+		time.Sleep(time.Hour)
+	}
+}
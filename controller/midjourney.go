@@ -158,7 +158,7 @@ func UpdateMidjourneyTaskBulk() {
 					common.LogError(ctx, "UpdateMidjourneyTask task error: "+err.Error())
 				} else {
 					if shouldReturnQuota {
-						err = model.IncreaseUserQuota(task.UserId, task.Quota, false)
+						err = model.RefundBillingQuota(task.UserId, task.OrganizationId, task.Quota, service.CoverActionToModelName(task.Action))
 						if err != nil {
 							common.LogError(ctx, "fail to increase user quota: "+err.Error())
 						}
@@ -170,6 +170,15 @@ func ListModels(c *gin.Context) {
 				})
 			}
 		}
+		// 用户私有的微调模型
+		for _, modelName := range model.GetUserFineTunedModels(userId) {
+			userOpenAiModels = append(userOpenAiModels, dto.OpenAIModels{
+				Id:      modelName,
+				Object:  "model",
+				Created: 1626777600,
+				OwnedBy: "user",
+			})
+		}
 	}
 	c.JSON(200, gin.H{
 		"success": true,
@@ -7,6 +7,7 @@ import (
 	"one-api/model"
 	"one-api/setting"
 	"one-api/setting/console_setting"
+	"one-api/setting/operation_setting"
 	"one-api/setting/ratio_setting"
 	"one-api/setting/system_setting"
 	"strings"
@@ -148,6 +149,42 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "routing_setting.rules":
+		err = operation_setting.ValidateRoutingRules(option.Value)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "pricing_tier_setting.models":
+		err = operation_setting.ValidatePricingTiers(option.Value)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "pricing_tier_setting.timezone":
+		err = operation_setting.ValidatePricingTimezone(option.Value)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "exact_billing_setting.model_prices":
+		err = operation_setting.ValidateExactModelPrices(option.Value)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
 	case "console_setting.uptime_kuma_groups":
 		err = console_setting.ValidateConsoleSettings(option.Value, "UptimeKumaGroups")
 		if err != nil {
@@ -0,0 +1,449 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetSelfOrganizations(c *gin.Context) {
+	orgs, err := model.GetUserOrganizations(c.GetInt64("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    orgs,
+	})
+}
+
+func validateOrganizationName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("组织名称不能为空")
+	}
+	if len(name) > 64 {
+		return "", errors.New("组织名称过长")
+	}
+	return name, nil
+}
+
+// CreateOrganization 用户创建组织并成为所有者
+func CreateOrganization(c *gin.Context) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	name, err := validateOrganizationName(req.Name)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	org, err := model.CreateOrganization(name, c.GetInt64("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    org,
+	})
+}
+
+// JoinOrganization 使用邀请码加入组织
+func JoinOrganization(c *gin.Context) {
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	org, err := model.AcceptOrganizationInvitation(req.Code, c.GetInt64("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    org,
+	})
+}
+
+func GetOrganization(c *gin.Context) {
+	orgId := common.GetContextKeyInt(c, constant.ContextKeyOrganizationId)
+	org, err := model.GetOrganizationById(orgId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	org.Role = common.GetContextKeyInt(c, constant.ContextKeyOrganizationRole)
+	member, err := model.GetOrganizationMember(orgId, c.GetInt64("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"organization": org,
+			"member":       member,
+		},
+	})
+}
+
+func UpdateOrganization(c *gin.Context) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	name, err := validateOrganizationName(req.Name)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = model.UpdateOrganizationName(common.GetContextKeyInt(c, constant.ContextKeyOrganizationId), name); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func DeleteOrganization(c *gin.Context) {
+	if err := model.DeleteOrganization(common.GetContextKeyInt(c, constant.ContextKeyOrganizationId)); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// TransferOrganizationQuota 将个人额度转入组织额度池
+func TransferOrganizationQuota(c *gin.Context) {
+	var req struct {
+		Quota int `json:"quota"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	userId := c.GetInt64("id")
+	orgId := common.GetContextKeyInt(c, constant.ContextKeyOrganizationId)
+	if err := model.TransferUserQuotaToOrganization(userId, orgId, req.Quota); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(userId, model.LogTypeManage, "向组织 #"+strconv.Itoa(orgId)+" 转入额度 "+common.LogQuota(req.Quota))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func GetOrganizationMembers(c *gin.Context) {
+	members, err := model.GetOrganizationMembers(common.GetContextKeyInt(c, constant.ContextKeyOrganizationId))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    members,
+	})
+}
+
+// getManageableMember 获取当前用户可以管理的成员，所有者不能被管理，只有所有者可以管理管理员
+func getManageableMember(c *gin.Context, userId int64) (*model.OrganizationMember, error) {
+	member, err := model.GetOrganizationMember(common.GetContextKeyInt(c, constant.ContextKeyOrganizationId), userId)
+	if err != nil {
+		return nil, errors.New("成员不存在")
+	}
+	role := common.GetContextKeyInt(c, constant.ContextKeyOrganizationRole)
+	if member.Role == common.OrganizationRoleOwner {
+		return nil, errors.New("不能修改组织所有者")
+	}
+	if member.Role >= common.OrganizationRoleAdmin && role < common.OrganizationRoleOwner {
+		return nil, errors.New("只有组织所有者可以管理管理员")
+	}
+	return member, nil
+}
+
+func UpdateOrganizationMember(c *gin.Context) {
+	var req model.OrganizationMember
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if !common.IsValidOrganizationRole(req.Role) || req.Role == common.OrganizationRoleOwner {
+		common.ApiError(c, errors.New("无效的成员角色"))
+		return
+	}
+	if req.Role >= common.OrganizationRoleAdmin && common.GetContextKeyInt(c, constant.ContextKeyOrganizationRole) < common.OrganizationRoleOwner {
+		common.ApiError(c, errors.New("只有组织所有者可以设置管理员"))
+		return
+	}
+	if req.QuotaLimit < 0 {
+		common.ApiError(c, errors.New("额度上限不能为负数"))
+		return
+	}
+	member, err := getManageableMember(c, req.UserId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = model.UpdateOrganizationMember(member.OrganizationId, member.UserId, req.Role, req.QuotaLimit); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func ResetOrganizationMemberUsedQuota(c *gin.Context) {
+	userId, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	member, err := getManageableMember(c, userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = model.ResetOrganizationMemberUsedQuota(member.OrganizationId, member.UserId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// DeleteOrganizationMember 管理员移除成员，成员也可以自行退出，所有者不能退出
+func DeleteOrganizationMember(c *gin.Context) {
+	userId, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	orgId := common.GetContextKeyInt(c, constant.ContextKeyOrganizationId)
+	role := common.GetContextKeyInt(c, constant.ContextKeyOrganizationRole)
+	if userId == c.GetInt64("id") {
+		if role == common.OrganizationRoleOwner {
+			common.ApiError(c, errors.New("组织所有者不能退出组织"))
+			return
+		}
+	} else {
+		if role < common.OrganizationRoleAdmin {
+			common.ApiError(c, errors.New("无权进行此操作，组织角色权限不足"))
+			return
+		}
+		if _, err = getManageableMember(c, userId); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
+	if err = model.DeleteOrganizationMember(orgId, userId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func GetOrganizationInvitations(c *gin.Context) {
+	invitations, err := model.GetOrganizationInvitations(common.GetContextKeyInt(c, constant.ContextKeyOrganizationId))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    invitations,
+	})
+}
+
+func CreateOrganizationInvitation(c *gin.Context) {
+	var req model.OrganizationInvitation
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if !common.IsValidOrganizationRole(req.Role) || req.Role == common.OrganizationRoleOwner {
+		common.ApiError(c, errors.New("无效的成员角色"))
+		return
+	}
+	if req.Role >= common.OrganizationRoleAdmin && common.GetContextKeyInt(c, constant.ContextKeyOrganizationRole) < common.OrganizationRoleOwner {
+		common.ApiError(c, errors.New("只有组织所有者可以邀请管理员"))
+		return
+	}
+	if req.ExpiredTime != 0 && req.ExpiredTime < common.GetTimestamp() {
+		common.ApiError(c, errors.New("过期时间不能早于当前时间"))
+		return
+	}
+	invitation := model.OrganizationInvitation{
+		OrganizationId: common.GetContextKeyInt(c, constant.ContextKeyOrganizationId),
+		Role:           req.Role,
+		InviterId:      c.GetInt64("id"),
+		ExpiredTime:    req.ExpiredTime,
+	}
+	if err := invitation.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    invitation,
+	})
+}
+
+func DeleteOrganizationInvitation(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("invitation_id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = model.DeleteOrganizationInvitation(common.GetContextKeyInt(c, constant.ContextKeyOrganizationId), id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func GetOrganizationTokens(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	tokens, total, err := model.GetOrganizationTokens(common.GetContextKeyInt(c, constant.ContextKeyOrganizationId), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	// 管理员只能查看和删除组织令牌，不能获取令牌密钥
+	for _, token := range tokens {
+		token.Clean()
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(tokens)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func DeleteOrganizationToken(c *gin.Context) {
+	tokenId, err := strconv.Atoi(c.Param("token_id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = model.DeleteOrganizationToken(common.GetContextKeyInt(c, constant.ContextKeyOrganizationId), tokenId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func GetOrganizationLogs(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	logs, total, err := model.GetOrganizationLogs(common.GetContextKeyInt(c, constant.ContextKeyOrganizationId), startTimestamp, endTimestamp,
+		c.Query("model_name"), c.Query("username"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(logs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// GetOrganizationUsage 组织用量看板，按成员和模型汇总
+func GetOrganizationUsage(c *gin.Context) {
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	usages, err := model.GetOrganizationUsage(common.GetContextKeyInt(c, constant.ContextKeyOrganizationId), startTimestamp, endTimestamp)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    usages,
+	})
+}
+
+func GetAllOrganizations(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	orgs, total, err := model.GetAllOrganizations(c.Query("keyword"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(orgs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// ManageOrganization 系统管理员修改组织额度和状态
+func ManageOrganization(c *gin.Context) {
+	var req model.Organization
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.Status != model.OrganizationStatusEnabled && req.Status != model.OrganizationStatusDisabled {
+		common.ApiError(c, errors.New("无效的组织状态"))
+		return
+	}
+	if req.Quota < 0 {
+		common.ApiError(c, errors.New("额度不能为负数"))
+		return
+	}
+	if _, err := model.GetOrganizationById(req.Id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.UpdateOrganizationByAdmin(req.Id, req.Quota, req.Status); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(c.GetInt64("id"), model.LogTypeManage, "管理员修改组织 #"+strconv.Itoa(req.Id)+" 额度为 "+common.LogQuota(req.Quota))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
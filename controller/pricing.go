@@ -3,6 +3,7 @@ package controller
 import (
 	"one-api/model"
 	"one-api/setting"
+	"one-api/setting/operation_setting"
 	"one-api/setting/ratio_setting"
 
 	"github.com/gin-gonic/gin"
@@ -38,11 +39,17 @@ func GetPricing(c *gin.Context) {
 		}
 	}
 
+	pricingTierTimezone := ""
+	if operation_setting.GetPricingTierSetting().Enabled {
+		pricingTierTimezone = operation_setting.GetPricingTierSetting().Timezone
+	}
+
 	c.JSON(200, gin.H{
-		"success":      true,
-		"data":         pricing,
-		"group_ratio":  groupRatio,
-		"usable_group": usableGroup,
+		"success":               true,
+		"data":                  pricing,
+		"group_ratio":           groupRatio,
+		"usable_group":          usableGroup,
+		"pricing_tier_timezone": pricingTierTimezone,
 	})
 }
 
@@ -17,6 +17,7 @@ import (
 	relayconstant "one-api/relay/constant"
 	"one-api/relay/helper"
 	"one-api/service"
+	"one-api/setting/operation_setting"
 	"one-api/types"
 	"strings"
 
@@ -47,7 +48,7 @@ func relayHandler(c *gin.Context, relayMode int) *types.NewAPIError {
 		err = relay.TextHelper(c)
 	}
 
-	if constant2.ErrorLogEnabled && err != nil {
+	if constant2.ErrorLogEnabled && err != nil && !errors.Is(err.Err, service.ErrHedgeLost) {
 		// 保存错误日志到mysql中
 		userId := c.GetInt64("id")
 		tokenName := c.GetString("token_name")
@@ -62,6 +63,13 @@ func relayHandler(c *gin.Context, relayMode int) *types.NewAPIError {
 		other["channel_id"] = channelId
 		other["channel_name"] = c.GetString("channel_name")
 		other["channel_type"] = c.GetInt("channel_type")
+		if common.GetContextKeyBool(c, constant.ContextKeyChannelIsMultiKey) {
+			other["admin_info"] = map[string]interface{}{
+				"is_multi_key":          true,
+				"multi_key_index":       common.GetContextKeyInt(c, constant.ContextKeyChannelMultiKeyIndex),
+				"multi_key_fingerprint": common.KeyFingerprint(common.GetContextKeyString(c, constant.ContextKeyChannelKey)),
+			}
+		}
 
 		model.RecordErrorLog(c, userId, channelId, modelName, tokenName, err.Error(), tokenId, 0, false, userGroup, other)
 	}
@@ -74,9 +82,82 @@ func Relay(c *gin.Context) {
 	requestId := c.GetString(common.RequestIdKey)
 	group := c.GetString("group")
 	originalModel := c.GetString("original_model")
-	var newAPIError *types.NewAPIError
 
+	// 响应缓存在占用渠道之前查询，命中时不占用渠道并发和熔断探测名额
+	hit, newAPIError := relay.ReplayResponseCache(c, relayMode)
+	if !hit {
+		newAPIError = relayWithFallback(c, relayMode, group, originalModel)
+		if newAPIError != nil && shouldQueueRequest(c, group, newAPIError) {
+			// 上游全部限流时排队等待，而不是立即报错
+			newAPIError = relayWithAdmissionQueue(c, relayMode, group, originalModel, newAPIError)
+		}
+	}
+	if newAPIError == nil {
+		service.NotifyAdmissionQueue(group)
+		return // 成功处理请求，直接返回
+	}
+
+	useChannel := c.GetStringSlice("use_channel")
+	if len(useChannel) > 1 {
+		retryLogStr := fmt.Sprintf("重试：%s", strings.Trim(strings.Join(strings.Fields(fmt.Sprint(useChannel)), "->"), "[]"))
+		common.LogInfo(c, retryLogStr)
+	}
+
+	//if newAPIError.StatusCode == http.StatusTooManyRequests {
+	//	common.LogError(c, fmt.Sprintf("origin 429 error: %s", newAPIError.Error()))
+	//	newAPIError.SetMessage("当前分组上游负载已饱和，请稍后再试")
+	//}
+	newAPIError.SetMessage(common.MessageWithRequestId(newAPIError.Error(), requestId))
+	c.JSON(newAPIError.StatusCode, gin.H{
+		"error": newAPIError.ToOpenAIError(),
+	})
+}
+
+// relayWithFallback 在同一模型的渠道间重试，都失败或限流时按备用链改用其他模型，成功返回 nil
+func relayWithFallback(c *gin.Context, relayMode int, group string, originalModel string) *types.NewAPIError {
+	newAPIError := relayWithRetry(c, relayMode, group, originalModel)
+	// 当前模型的渠道都失败或限流时，按备用链改用其他模型
+	fallbackModels := operation_setting.GetModelFallbackChain(group, originalModel)
+	for newAPIError != nil && len(fallbackModels) > 0 && shouldFallbackModel(c, relayMode, newAPIError) {
+		channel, fallbackModel, rest := middleware.SelectFallbackModelChannel(c, group, fallbackModels)
+		if channel == nil {
+			break
+		}
+		fallbackModels = rest
+		if err := middleware.SetupContextForSelectedChannel(c, channel, fallbackModel); err != nil {
+			newAPIError = err
+			continue
+		}
+		middleware.MarkModelFallback(c, originalModel, fallbackModel)
+		newAPIError = relayWithRetry(c, relayMode, group, fallbackModel)
+	}
+	return newAPIError
+}
+
+// relayWithRetry 在同一模型的渠道间重试，成功返回 nil
+func relayWithRetry(c *gin.Context, relayMode int, group string, originalModel string) *types.NewAPIError {
+	var newAPIError *types.NewAPIError
+	contextWindowReselected := false
 	for i := 0; i <= common.RetryTimes; i++ {
+		if i == 0 && shouldHedgeRequest(c, relayMode) {
+			newAPIError = hedgeRelayRequest(c, relayMode, group, originalModel)
+			if newAPIError == nil {
+				return nil
+			}
+			if newAPIError.GetErrorCode() == types.ErrorCodeContextWindowExceeded && !contextWindowReselected {
+				contextWindowReselected = true
+				if newAPIError = setupContextWindowChannel(c, group, originalModel); newAPIError != nil {
+					break
+				}
+				i--
+				continue
+			}
+			if !shouldRetry(c, newAPIError, common.RetryTimes-i) && !shouldRetrySaturated(c, newAPIError, common.RetryTimes-i) {
+				break
+			}
+			continue
+		}
+
 		channel, err := getChannel(c, group, originalModel, i)
 		if err != nil {
 			common.LogError(c, err.Error())
@@ -87,31 +168,48 @@ func Relay(c *gin.Context) {
 		newAPIError = relayRequest(c, relayMode, channel)
 
 		if newAPIError == nil {
-			return // 成功处理请求，直接返回
+			service.RecordChannelBreakerSuccess(c)
+			service.BindSessionAffinity(c)
+			return nil
+		}
+
+		if newAPIError.GetErrorCode() == types.ErrorCodeContextWindowExceeded && !contextWindowReselected {
+			// 固定渠道的请求没有其他渠道可选，直接返回
+			if _, ok := c.Get("specific_channel_id"); ok {
+				break
+			}
+			// 当前渠道上下文窗口不足，改选能容纳请求的渠道，不计入重试次数
+			contextWindowReselected = true
+			if newAPIError = setupContextWindowChannel(c, group, originalModel); newAPIError != nil {
+				break
+			}
+			i--
+			continue
+		}
+
+		if newAPIError.GetErrorCode() == types.ErrorCodeChannelSaturated {
+			// 渠道并发已满且等待超时，不计为渠道失败
+			if !shouldRetrySaturated(c, newAPIError, common.RetryTimes-i) {
+				break
+			}
+			continue
+		}
+
+		if errors.Is(newAPIError.Err, relay.ErrStreamInterrupted) {
+			// 响应已经开始，不再走常规重试，换渠道续写；流中断不计入渠道失败和熔断，也不触发自动禁用
+			common.LogWarn(c, fmt.Sprintf("stream interrupted on channel #%d", channel.Id))
+			resumeInterruptedStream(c, relayMode, group, originalModel)
+			return nil
 		}
 
+		service.RecordChannelRelayError(channel, originalModel, newAPIError)
 		go processChannelError(c, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan()), newAPIError)
 
 		if !shouldRetry(c, newAPIError, common.RetryTimes-i) {
 			break
 		}
 	}
-	useChannel := c.GetStringSlice("use_channel")
-	if len(useChannel) > 1 {
-		retryLogStr := fmt.Sprintf("重试：%s", strings.Trim(strings.Join(strings.Fields(fmt.Sprint(useChannel)), "->"), "[]"))
-		common.LogInfo(c, retryLogStr)
-	}
-
-	if newAPIError != nil {
-		//if newAPIError.StatusCode == http.StatusTooManyRequests {
-		//	common.LogError(c, fmt.Sprintf("origin 429 error: %s", newAPIError.Error()))
-		//	newAPIError.SetMessage("当前分组上游负载已饱和，请稍后再试")
-		//}
-		newAPIError.SetMessage(common.MessageWithRequestId(newAPIError.Error(), requestId))
-		c.JSON(newAPIError.StatusCode, gin.H{
-			"error": newAPIError.ToOpenAIError(),
-		})
-	}
+	return newAPIError
 }
 
 var upgrader = websocket.Upgrader{
@@ -150,9 +248,20 @@ func WssRelay(c *gin.Context) {
 		newAPIError = wssRequest(c, ws, relayMode, channel)
 
 		if newAPIError == nil {
+			service.RecordChannelBreakerSuccess(c)
+			service.BindSessionAffinity(c)
 			return // 成功处理请求，直接返回
 		}
 
+		if newAPIError.GetErrorCode() == types.ErrorCodeChannelSaturated {
+			// 渠道并发已满且等待超时，不计为渠道失败
+			if !shouldRetrySaturated(c, newAPIError, common.RetryTimes-i) {
+				break
+			}
+			continue
+		}
+
+		service.RecordChannelRelayError(channel, originalModel, newAPIError)
 		go processChannelError(c, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan()), newAPIError)
 
 		if !shouldRetry(c, newAPIError, common.RetryTimes-i) {
@@ -192,9 +301,20 @@ func RelayClaude(c *gin.Context) {
 		newAPIError = claudeRequest(c, channel)
 
 		if newAPIError == nil {
+			service.RecordChannelBreakerSuccess(c)
+			service.BindSessionAffinity(c)
 			return // 成功处理请求，直接返回
 		}
 
+		if newAPIError.GetErrorCode() == types.ErrorCodeChannelSaturated {
+			// 渠道并发已满且等待超时，不计为渠道失败
+			if !shouldRetrySaturated(c, newAPIError, common.RetryTimes-i) {
+				break
+			}
+			continue
+		}
+
+		service.RecordChannelRelayError(channel, originalModel, newAPIError)
 		go processChannelError(c, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan()), newAPIError)
 
 		if !shouldRetry(c, newAPIError, common.RetryTimes-i) {
@@ -217,32 +337,90 @@ func RelayClaude(c *gin.Context) {
 }
 
 func relayRequest(c *gin.Context, relayMode int, channel *model.Channel) *types.NewAPIError {
+	defer trackChannelKeyInflight(c, channel)()
 	addUsedChannel(c, channel.Id)
+	release, newAPIError := service.AcquireChannelConcurrency(c, channel)
+	if newAPIError != nil {
+		return newAPIError
+	}
+	defer release()
+	acquireChannelBreaker(c, channel)
 	requestBody, _ := common.GetRequestBody(c)
 	c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
 	return relayHandler(c, relayMode)
 }
 
 func wssRequest(c *gin.Context, ws *websocket.Conn, relayMode int, channel *model.Channel) *types.NewAPIError {
+	defer trackChannelKeyInflight(c, channel)()
 	addUsedChannel(c, channel.Id)
+	release, newAPIError := service.AcquireChannelConcurrency(c, channel)
+	if newAPIError != nil {
+		return newAPIError
+	}
+	defer release()
+	acquireChannelBreaker(c, channel)
 	requestBody, _ := common.GetRequestBody(c)
 	c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
 	return relay.WssHelper(c, ws)
 }
 
 func claudeRequest(c *gin.Context, channel *model.Channel) *types.NewAPIError {
+	defer trackChannelKeyInflight(c, channel)()
 	addUsedChannel(c, channel.Id)
+	release, newAPIError := service.AcquireChannelConcurrency(c, channel)
+	if newAPIError != nil {
+		return newAPIError
+	}
+	defer release()
+	acquireChannelBreaker(c, channel)
 	requestBody, _ := common.GetRequestBody(c)
 	c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
 	return relay.ClaudeHelper(c)
 }
 
+// acquireChannelBreaker 即将向上游发起请求时占用渠道及所用 Key 熔断器的半开探测名额
+func acquireChannelBreaker(c *gin.Context, channel *model.Channel) {
+	model.AcquireChannelBreaker(channel.Id, -1)
+	if common.GetContextKeyBool(c, constant.ContextKeyChannelIsMultiKey) {
+		model.AcquireChannelBreaker(channel.Id, common.GetContextKeyInt(c, constant.ContextKeyChannelMultiKeyIndex))
+	}
+}
+
+// trackChannelKeyInflight 记录多 Key 渠道中所用 Key 进行中的请求数，返回请求结束时调用的释放函数
+func trackChannelKeyInflight(c *gin.Context, channel *model.Channel) func() {
+	if !common.GetContextKeyBool(c, constant.ContextKeyChannelIsMultiKey) {
+		return func() {}
+	}
+	keyIndex := common.GetContextKeyInt(c, constant.ContextKeyChannelMultiKeyIndex)
+	model.AcquireChannelKeyInflight(channel.Id, keyIndex)
+	return func() {
+		model.ReleaseChannelKeyInflight(channel.Id, keyIndex)
+	}
+}
+
 func addUsedChannel(c *gin.Context, channelId int) {
 	useChannel := c.GetStringSlice("use_channel")
 	useChannel = append(useChannel, fmt.Sprintf("%d", channelId))
 	c.Set("use_channel", useChannel)
 }
 
+// setupContextWindowChannel 按已计算的提示 tokens 选择上下文窗口足够的渠道，所有渠道窗口都不足时返回 400
+func setupContextWindowChannel(c *gin.Context, group string, originalModel string) *types.NewAPIError {
+	channel, selectGroup, err := model.CacheGetRandomSatisfiedChannel(c, group, originalModel, 0)
+	if err != nil {
+		var windowErr *model.ContextWindowError
+		if errors.As(err, &windowErr) {
+			return contextWindowExceededError(windowErr, originalModel)
+		}
+		return types.NewError(fmt.Errorf("获取分组 %s 下模型 %s 的可用渠道失败: %s", selectGroup, originalModel, err.Error()), types.ErrorCodeGetChannelFailed)
+	}
+	return middleware.SetupContextForSelectedChannel(c, channel, originalModel)
+}
+
+func contextWindowExceededError(windowErr *model.ContextWindowError, originalModel string) *types.NewAPIError {
+	return types.NewErrorWithStatusCode(fmt.Errorf("prompt tokens %d exceed the maximum context window %d of available channels for model %s", windowErr.PromptTokens, windowErr.MaxWindow, originalModel), types.ErrorCodeContextWindowExceeded, http.StatusBadRequest)
+}
+
 func getChannel(c *gin.Context, group, originalModel string, retryCount int) (*model.Channel, *types.NewAPIError) {
 	if retryCount == 0 {
 		autoBan := c.GetBool("auto_ban")
@@ -259,6 +437,10 @@ func getChannel(c *gin.Context, group, originalModel string, retryCount int) (*m
 	}
 	channel, selectGroup, err := model.CacheGetRandomSatisfiedChannel(c, group, originalModel, retryCount)
 	if err != nil {
+		var windowErr *model.ContextWindowError
+		if errors.As(err, &windowErr) {
+			return nil, contextWindowExceededError(windowErr, originalModel)
+		}
 		if group == "auto" {
 			return nil, types.NewError(errors.New(fmt.Sprintf("获取自动分组下模型 %s 的可用渠道失败: %s", originalModel, err.Error())), types.ErrorCodeGetChannelFailed)
 		}
@@ -317,10 +499,44 @@ func shouldRetry(c *gin.Context, openaiErr *types.NewAPIError, retryTimes int) b
 	return true
 }
 
+// shouldRetrySaturated 渠道并发已满时换下一优先级的渠道，客户端已断开或指定了渠道时不再重试
+func shouldRetrySaturated(c *gin.Context, openaiErr *types.NewAPIError, retryTimes int) bool {
+	if openaiErr.GetErrorCode() != types.ErrorCodeChannelSaturated || retryTimes <= 0 {
+		return false
+	}
+	if _, ok := c.Get("specific_channel_id"); ok {
+		return false
+	}
+	return c.Request.Context().Err() == nil
+}
+
+// shouldFallbackModel 当前模型的渠道都失败、限流或没有可用渠道，且响应尚未开始时改用备用模型
+func shouldFallbackModel(c *gin.Context, relayMode int, openaiErr *types.NewAPIError) bool {
+	if openaiErr == nil || c.Writer.Written() {
+		return false
+	}
+	if !middleware.SupportModelFallback(relayMode) {
+		return false
+	}
+	if _, ok := c.Get("specific_channel_id"); ok {
+		return false
+	}
+	if openaiErr.StatusCode == http.StatusTooManyRequests || types.IsChannelError(openaiErr) {
+		return true
+	}
+	if types.IsLocalError(openaiErr) {
+		return openaiErr.GetErrorCode() == types.ErrorCodeGetChannelFailed
+	}
+	return openaiErr.StatusCode/100 == 5
+}
+
 func processChannelError(c *gin.Context, channelError types.ChannelError, err *types.NewAPIError) {
 	// 不要使用context获取渠道信息，异步处理时可能会出现渠道信息不一致的情况
 	// do not use context to get channel info, there may be inconsistent channel info when processing asynchronously
 	common.LogError(c, fmt.Sprintf("relay error (channel #%d, status code: %d): %s", channelError.ChannelId, err.StatusCode, err.Error()))
+	service.RecordChannelBreakerFailure(channelError, err)
+	service.CooldownChannelKey(channelError, err)
+	service.RecordChannelKeyError(channelError, err)
 	if service.ShouldDisableChannel(channelError.ChannelId, err) && channelError.AutoBan {
 		service.DisableChannel(channelError, err.Error())
 	}
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"one-api/middleware"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"one-api/types"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// shouldQueueRequest 分组开启排队、上游返回 429 且响应尚未开始时进入排队
+func shouldQueueRequest(c *gin.Context, group string, openaiErr *types.NewAPIError) bool {
+	if openaiErr == nil || openaiErr.StatusCode != http.StatusTooManyRequests || c.Writer.Written() {
+		return false
+	}
+	if _, ok := c.Get("specific_channel_id"); ok {
+		return false
+	}
+	return operation_setting.IsAdmissionQueueGroupEnabled(group) && c.Request.Context().Err() == nil
+}
+
+// relayWithAdmissionQueue 排队等待，轮到时重新选择渠道重试，等待超时后返回带 Retry-After 的 429
+func relayWithAdmissionQueue(c *gin.Context, relayMode int, group string, originalModel string, lastErr *types.NewAPIError) *types.NewAPIError {
+	ticket, ok := service.NewAdmissionTicket(c, group)
+	if !ok {
+		return admissionQueueExhausted(c, "请求排队已满，请稍后再试")
+	}
+	for ticket.Wait(c.Request.Context()) {
+		channel, _, err := model.CacheGetRandomSatisfiedChannel(c, group, originalModel, 0)
+		if err != nil {
+			// 暂时没有可用渠道，继续排队
+			continue
+		}
+		if newAPIError := middleware.SetupContextForSelectedChannel(c, channel, originalModel); newAPIError != nil {
+			return newAPIError
+		}
+		lastErr = relayWithFallback(c, relayMode, group, originalModel)
+		if lastErr == nil {
+			ticket.Admitted()
+			return nil
+		}
+		if !shouldQueueRequest(c, group, lastErr) {
+			return lastErr
+		}
+	}
+	if c.Request.Context().Err() != nil {
+		return lastErr
+	}
+	return admissionQueueExhausted(c, "当前分组上游负载已饱和，请稍后再试")
+}
+
+func admissionQueueExhausted(c *gin.Context, message string) *types.NewAPIError {
+	c.Header("Retry-After", strconv.Itoa(operation_setting.GetAdmissionQueueSetting().RetryAfterSeconds))
+	return types.NewErrorWithStatusCode(errors.New(message), types.ErrorCodeAdmissionQueueExhausted, http.StatusTooManyRequests)
+}
+
+// GetAdmissionQueueStatus 查看当前节点的排队深度和统计
+func GetAdmissionQueueStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    service.GetAdmissionQueueStats(),
+	})
+}
@@ -0,0 +1,256 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/middleware"
+	"one-api/model"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"one-api/types"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+// hedgeResponseWriter 缓存单个对冲尝试的响应，获胜后再写回客户端
+type hedgeResponseWriter struct {
+	header     http.Header
+	statusCode int
+	written    bool
+	body       bytes.Buffer
+}
+
+func (w *hedgeResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *hedgeResponseWriter) Write(data []byte) (int, error) {
+	w.written = true
+	return w.body.Write(data)
+}
+
+func (w *hedgeResponseWriter) WriteString(data string) (int, error) {
+	w.written = true
+	return w.body.WriteString(data)
+}
+
+func (w *hedgeResponseWriter) WriteHeader(statusCode int) {
+	if statusCode > 0 && !w.written {
+		w.statusCode = statusCode
+	}
+}
+
+func (w *hedgeResponseWriter) WriteHeaderNow() {
+	w.written = true
+}
+
+func (w *hedgeResponseWriter) Status() int {
+	return w.statusCode
+}
+
+func (w *hedgeResponseWriter) Size() int {
+	if !w.written {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *hedgeResponseWriter) Written() bool {
+	return w.written
+}
+
+func (w *hedgeResponseWriter) Flush() {}
+
+func (w *hedgeResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.New("hedged response does not support hijack")
+}
+
+func (w *hedgeResponseWriter) CloseNotify() <-chan bool {
+	return make(chan bool)
+}
+
+func (w *hedgeResponseWriter) Pusher() http.Pusher {
+	return nil
+}
+
+type hedgeAttempt struct {
+	ctx     *gin.Context
+	writer  *hedgeResponseWriter
+	channel *model.Channel
+	cancel  context.CancelFunc
+	err     *types.NewAPIError
+}
+
+// shouldHedgeRequest 仅对开启对冲的分组中的非流式文本请求启用
+func shouldHedgeRequest(c *gin.Context, relayMode int) bool {
+	hedgeSetting := operation_setting.GetHedgeSetting()
+	if !hedgeSetting.Enabled || hedgeSetting.DelayMs <= 0 {
+		return false
+	}
+	if relayMode != relayconstant.RelayModeChatCompletions && relayMode != relayconstant.RelayModeCompletions {
+		return false
+	}
+	if _, ok := c.Get("specific_channel_id"); ok || common.GetContextKeyBool(c, constant.ContextKeyBatchRequest) {
+		return false
+	}
+	if !operation_setting.IsHedgeGroupEnabled(c.GetString("group")) {
+		return false
+	}
+	var request struct {
+		Stream bool `json:"stream"`
+	}
+	if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+		return false
+	}
+	return !request.Stream
+}
+
+// newHedgeAttempt 为对冲尝试复制一份独立的上下文，响应写入缓冲区；
+// 未采用的请求需要计费时，上游请求不随客户端请求结束而取消
+func newHedgeAttempt(c *gin.Context, race *service.HedgeRace, detached bool) *hedgeAttempt {
+	writer := &hedgeResponseWriter{header: make(http.Header), statusCode: http.StatusOK}
+	ctx := c.Copy()
+	ctx.Writer = writer
+	parent := c.Request.Context()
+	if detached {
+		parent = context.WithoutCancel(parent)
+	}
+	requestCtx, cancel := context.WithCancel(parent)
+	ctx.Request = c.Request.Clone(requestCtx)
+	common.SetContextKey(ctx, constant.ContextKeyHedgeRace, race)
+	return &hedgeAttempt{ctx: ctx, writer: writer, cancel: cancel}
+}
+
+// newSecondaryHedgeAttempt 选择一个不同于首个渠道的渠道发起对冲请求，没有可用渠道时返回 nil
+func newSecondaryHedgeAttempt(c *gin.Context, race *service.HedgeRace, detached bool, group string, originalModel string, primaryChannelId int) *hedgeAttempt {
+	attempt := newHedgeAttempt(c, race, detached)
+	filter, _ := common.GetContextKey(c, constant.ContextKeyChannelFilter)
+	common.SetContextKey(attempt.ctx, constant.ContextKeyChannelFilter, model.ChannelFilter(func(channel *model.Channel) bool {
+		if channel.Id == primaryChannelId {
+			return false
+		}
+		if f, ok := filter.(model.ChannelFilter); ok {
+			return f(channel)
+		}
+		return true
+	}))
+	channel, _, err := model.CacheGetRandomSatisfiedChannel(attempt.ctx, group, originalModel, 0)
+	if err != nil {
+		attempt.cancel()
+		return nil
+	}
+	if newAPIError := middleware.SetupContextForSelectedChannel(attempt.ctx, channel, originalModel); newAPIError != nil {
+		attempt.cancel()
+		return nil
+	}
+	attempt.channel = channel
+	return attempt
+}
+
+// hedgeRelayRequest 首个渠道超过延迟仍未返回首字节时向第二个渠道发起相同请求，采用先成功的结果；
+// 未采用的请求默认取消，设置为全部计费时继续执行到结算
+func hedgeRelayRequest(c *gin.Context, relayMode int, group string, originalModel string) *types.NewAPIError {
+	race := service.NewHedgeRace()
+	chargeAll := operation_setting.GetHedgeSetting().ChargeAllRaced
+	results := make(chan *hedgeAttempt, 2)
+	start := func(attempt *hedgeAttempt) {
+		race.AddChannel(attempt.channel.Id)
+		go func() {
+			attempt.err = relayRequest(attempt.ctx, relayMode, attempt.channel)
+			results <- attempt
+		}()
+	}
+
+	primary := newHedgeAttempt(c, race, chargeAll)
+	channel, newAPIError := getChannel(primary.ctx, group, originalModel, 0)
+	if newAPIError != nil {
+		primary.cancel()
+		return newAPIError
+	}
+	primary.channel = channel
+	attempts := []*hedgeAttempt{primary}
+	start(primary)
+	pending := 1
+	defer func() {
+		// 合并各尝试使用过的渠道，用于重试日志
+		useChannel := make([]string, 0, len(attempts))
+		for _, attempt := range attempts {
+			useChannel = append(useChannel, attempt.ctx.GetStringSlice("use_channel")...)
+		}
+		c.Set("use_channel", useChannel)
+		if chargeAll && pending > 0 {
+			// 未采用的请求在后台执行完成并计费
+			remaining := pending
+			gopool.Go(func() {
+				for ; remaining > 0; remaining-- {
+					attempt := <-results
+					attempt.cancel()
+				}
+			})
+			return
+		}
+		for _, attempt := range attempts {
+			attempt.cancel()
+		}
+	}()
+
+	timer := time.NewTimer(time.Duration(operation_setting.GetHedgeSetting().DelayMs) * time.Millisecond)
+	defer timer.Stop()
+	responded := race.Responded()
+	var lastErr *types.NewAPIError
+	for pending > 0 {
+		select {
+		case <-responded:
+			// 首个渠道已开始返回，不再发起对冲请求
+			responded = nil
+			timer.Stop()
+		case <-timer.C:
+			if secondary := newSecondaryHedgeAttempt(c, race, chargeAll, group, originalModel, primary.channel.Id); secondary != nil {
+				common.LogInfo(c, fmt.Sprintf("hedge request to channel #%d", secondary.channel.Id))
+				attempts = append(attempts, secondary)
+				pending++
+				start(secondary)
+			}
+		case attempt := <-results:
+			pending--
+			canceled := errors.Is(attempt.ctx.Request.Context().Err(), context.Canceled)
+			attempt.cancel()
+			if attempt.err == nil {
+				writeHedgeResponse(c, attempt)
+				service.RecordChannelBreakerSuccess(attempt.ctx)
+				service.BindSessionAffinity(attempt.ctx)
+				return nil
+			}
+			if errors.Is(attempt.err.Err, service.ErrHedgeLost) || canceled {
+				continue
+			}
+			service.RecordChannelRelayError(attempt.channel, originalModel, attempt.err)
+			go processChannelError(attempt.ctx, *types.NewChannelError(attempt.channel.Id, attempt.channel.Type, attempt.channel.Name, attempt.channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(attempt.ctx, constant.ContextKeyChannelKey), attempt.channel.GetAutoBan()), attempt.err)
+			lastErr = attempt.err
+			// 已有渠道失败后不再发起新的对冲请求，剩余失败交由常规重试处理
+			timer.Stop()
+		}
+	}
+	if lastErr == nil {
+		lastErr = types.NewError(errors.New("all hedged requests were cancelled"), types.ErrorCodeDoRequestFailed)
+	}
+	return lastErr
+}
+
+func writeHedgeResponse(c *gin.Context, attempt *hedgeAttempt) {
+	for key, values := range attempt.writer.header {
+		c.Writer.Header()[key] = values
+	}
+	c.Writer.WriteHeader(attempt.writer.statusCode)
+	_, _ = c.Writer.Write(attempt.writer.body.Bytes())
+}
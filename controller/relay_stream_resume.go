@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/middleware"
+	"one-api/model"
+	"one-api/relay"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"one-api/types"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getStreamResumeChannel 选择一个本次请求尚未使用过的渠道用于续写
+func getStreamResumeChannel(c *gin.Context, group string, originalModel string, filter model.ChannelFilter) (*model.Channel, *types.NewAPIError) {
+	used := make(map[int]bool)
+	for _, id := range c.GetStringSlice("use_channel") {
+		if channelId, err := strconv.Atoi(id); err == nil {
+			used[channelId] = true
+		}
+	}
+	common.SetContextKey(c, constant.ContextKeyChannelFilter, model.ChannelFilter(func(channel *model.Channel) bool {
+		if used[channel.Id] {
+			return false
+		}
+		return filter == nil || filter(channel)
+	}))
+	channel, _, err := model.CacheGetRandomSatisfiedChannel(c, group, originalModel, 0)
+	if err != nil {
+		return nil, types.NewError(fmt.Errorf("no channel available for stream resume: %w", err), types.ErrorCodeGetChannelFailed)
+	}
+	if newAPIError := middleware.SetupContextForSelectedChannel(c, channel, originalModel); newAPIError != nil {
+		return nil, newAPIError
+	}
+	return channel, nil
+}
+
+// resumeInterruptedStream 上游流式响应中途中断后换渠道续写，客户端已收到部分响应，续写失败时直接结束流
+func resumeInterruptedStream(c *gin.Context, relayMode int, group string, originalModel string) {
+	filter, _ := common.GetContextKeyType[model.ChannelFilter](c, constant.ContextKeyChannelFilter)
+	var newAPIError *types.NewAPIError
+	for i := 0; i < operation_setting.GetStreamResumeSetting().MaxResumes; i++ {
+		channel, err := getStreamResumeChannel(c, group, originalModel, filter)
+		if err != nil {
+			newAPIError = err
+			break
+		}
+		common.LogInfo(c, fmt.Sprintf("resume interrupted stream on channel #%d", channel.Id))
+		newAPIError = relayRequest(c, relayMode, channel)
+		if newAPIError == nil {
+			service.RecordChannelBreakerSuccess(c)
+			service.BindSessionAffinity(c)
+			return
+		}
+		// 续写段再次中断时继续换渠道，不计入渠道失败
+		if errors.Is(newAPIError.Err, relay.ErrStreamInterrupted) {
+			continue
+		}
+		service.RecordChannelRelayError(channel, originalModel, newAPIError)
+		go processChannelError(c, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan()), newAPIError)
+	}
+	if newAPIError != nil {
+		common.LogError(c, "stream resume failed: "+newAPIError.Error())
+	}
+	helper.Done(c)
+}
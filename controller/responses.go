@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RetrieveResponse 读取本地保存的 /v1/responses 响应
+func RetrieveResponse(c *gin.Context) {
+	response, err := model.GetUserStoredResponse(c.GetInt64("id"), c.Param("id"))
+	if err != nil {
+		batchError(c, http.StatusNotFound, fmt.Sprintf("Response with id '%s' not found.", c.Param("id")), "response_id")
+		return
+	}
+	c.Data(http.StatusOK, "application/json", []byte(response.Data))
+}
+
+func DeleteResponse(c *gin.Context) {
+	response, err := model.GetUserStoredResponse(c.GetInt64("id"), c.Param("id"))
+	if err != nil {
+		batchError(c, http.StatusNotFound, fmt.Sprintf("Response with id '%s' not found.", c.Param("id")), "response_id")
+		return
+	}
+	if err = response.Delete(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":      response.ResponseId,
+		"object":  "response",
+		"deleted": true,
+	})
+}
+
+// CleanExpiredStoredResponses 定期清理过期的会话状态
+func CleanExpiredStoredResponses() {
+	for {
+		time.Sleep(time.Hour)
+		count, err := model.DeleteExpiredStoredResponses(common.GetTimestamp())
+		if err != nil {
+			common.SysError("clean expired stored responses failed: " + err.Error())
+		} else if count > 0 {
+			common.SysLog(fmt.Sprintf("cleaned %d expired stored responses", count))
+		}
+	}
+}
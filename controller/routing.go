@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DryRunRoutingRules 用示例请求试运行路由规则，返回命中的规则以及每条规则的匹配原因
+func DryRunRoutingRules(c *gin.Context) {
+	var request service.RoutingRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	headers := make(map[string]string, len(request.Headers))
+	for key, value := range request.Headers {
+		headers[strings.ToLower(key)] = value
+	}
+	request.Headers = headers
+	rule, results := service.MatchRoutingRule(&request)
+	data := gin.H{
+		"enabled": operation_setting.GetRoutingSetting().Enabled,
+		"matched": rule != nil,
+		"results": results,
+		"model":   request.Model,
+	}
+	if rule != nil {
+		data["rule"] = rule
+		if rule.Action.Model != "" {
+			data["model"] = rule.Action.Model
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    data,
+	})
+}
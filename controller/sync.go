@@ -165,6 +165,7 @@ func SyncGenerateAccessToken(c *gin.Context) {
 		ModelLimits:        token.ModelLimits,
 		AllowIps:           token.AllowIps,
 		Group:              token.Group,
+		ResponseCache:      token.ResponseCache,
 		Status:             common.TokenStatusEnabled,
 	}
 
@@ -650,6 +651,7 @@ func SyncUpdateTokenStatus(c *gin.Context) {
 		cleanToken.ModelLimits = token.ModelLimits
 		cleanToken.AllowIps = token.AllowIps
 		cleanToken.Group = token.Group
+		cleanToken.ResponseCache = token.ResponseCache
 		cleanToken.Status = token.Status
 	}
 
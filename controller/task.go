@@ -12,6 +12,7 @@ import (
 	"one-api/dto"
 	"one-api/model"
 	"one-api/relay"
+	"one-api/service"
 	"sort"
 	"strconv"
 	"time"
@@ -162,7 +163,7 @@ func updateSunoTaskAll(ctx context.Context, channelId int, taskIds []string, tas
 			} else {
 				quota := task.Quota
 				if quota != 0 {
-					err = model.IncreaseUserQuota(task.UserId, quota, false)
+					err = model.RefundBillingQuota(task.UserId, task.OrganizationId, quota, service.CoverTaskActionToModelName(task.Platform, task.Action))
 					if err != nil {
 						common.LogError(ctx, "fail to increase user quota: "+err.Error())
 					}
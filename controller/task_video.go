@@ -9,6 +9,7 @@ import (
 	"one-api/model"
 	"one-api/relay"
 	"one-api/relay/channel"
+	"one-api/service"
 	"time"
 )
 
@@ -117,7 +118,7 @@ func updateVideoSingleTask(ctx context.Context, adaptor channel.TaskAdaptor, cha
 		common.LogInfo(ctx, fmt.Sprintf("Task %s failed: %s", task.TaskID, task.FailReason))
 		quota := task.Quota
 		if quota != 0 {
-			if err := model.IncreaseUserQuota(task.UserId, quota, false); err != nil {
+			if err := model.RefundBillingQuota(task.UserId, task.OrganizationId, quota, service.CoverTaskActionToModelName(task.Platform, task.Action)); err != nil {
 				common.LogError(ctx, "Failed to increase user quota: "+err.Error())
 			}
 			logContent := fmt.Sprintf("Video async task failed %s, refund %s", task.TaskID, common.LogQuota(quota))
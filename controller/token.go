@@ -17,9 +17,14 @@ func GetAllTokens(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	items, err := model.WithTokenBudgets(tokens)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
 	total, _ := model.CountUserTokens(userId)
 	pageInfo.SetTotal(int(total))
-	pageInfo.SetItems(tokens)
+	pageInfo.SetItems(items)
 	common.ApiSuccess(c, pageInfo)
 	return
 }
@@ -53,10 +58,15 @@ func GetToken(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	items, err := model.WithTokenBudgets([]*model.Token{token})
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
-		"data":    token,
+		"data":    items[0],
 	})
 	return
 }
@@ -96,6 +106,13 @@ func AddToken(c *gin.Context) {
 		})
 		return
 	}
+	if token.OrganizationId > 0 {
+		// 组织令牌只能由组织中的成员（非只读）创建
+		if err = model.CheckOrganizationTokenAccess(token.OrganizationId, c.GetInt64("id")); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
 	key, err := common.GenerateKey()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -118,6 +135,8 @@ func AddToken(c *gin.Context) {
 		ModelLimits:        token.ModelLimits,
 		AllowIps:           token.AllowIps,
 		Group:              token.Group,
+		ResponseCache:      token.ResponseCache,
+		OrganizationId:     token.OrganizationId,
 	}
 	err = cleanToken.Insert()
 	if err != nil {
@@ -195,6 +214,7 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.ModelLimits = token.ModelLimits
 		cleanToken.AllowIps = token.AllowIps
 		cleanToken.Group = token.Group
+		cleanToken.ResponseCache = token.ResponseCache
 	}
 	err = cleanToken.Update()
 	if err != nil {
@@ -9,6 +9,7 @@ import (
 	"one-api/dto"
 	"one-api/model"
 	"one-api/setting"
+	"one-api/setting/operation_setting"
 	"strconv"
 	"strings"
 	"sync"
@@ -452,6 +453,15 @@ func GetSelf(c *gin.Context) {
 	}
 	// Hide admin remarks: set to empty to trigger omitempty tag, ensuring the remark field is not included in JSON returned to regular users
 	user.Remark = ""
+	if operation_setting.GetExactBillingSetting().Enabled {
+		balance := model.GetUserExactBalance(user)
+		user.BalanceUsd = &balance
+	}
+	user.Budgets, err = model.GetUserBudgets(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
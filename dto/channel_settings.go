@@ -4,4 +4,8 @@ type ChannelSettings struct {
 	ForceFormat       bool   `json:"force_format,omitempty"`
 	ThinkingToContent bool   `json:"thinking_to_content,omitempty"`
 	Proxy             string `json:"proxy"`
+	// 同时进行中的请求数上限，0 表示不限制
+	MaxConcurrency int `json:"max_concurrency,omitempty"`
+	// 并发已满时最多等待的毫秒数，超时后换下一优先级的渠道
+	ConcurrencyWaitMs int `json:"concurrency_wait_ms,omitempty"`
 }
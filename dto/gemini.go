@@ -1,4 +1,4 @@
-package gemini
+package dto
 
 import "encoding/json"
 
@@ -48,12 +48,12 @@ func (g *GeminiInlineData) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-type FunctionCall struct {
+type GeminiFunctionCall struct {
 	FunctionName string `json:"name"`
 	Arguments    any    `json:"args"`
 }
 
-type FunctionResponse struct {
+type GeminiFunctionResponse struct {
 	Name     string                 `json:"name"`
 	Response map[string]interface{} `json:"response"`
 }
@@ -77,8 +77,8 @@ type GeminiPart struct {
 	Text                string                         `json:"text,omitempty"`
 	Thought             bool                           `json:"thought,omitempty"`
 	InlineData          *GeminiInlineData              `json:"inlineData,omitempty"`
-	FunctionCall        *FunctionCall                  `json:"functionCall,omitempty"`
-	FunctionResponse    *FunctionResponse              `json:"functionResponse,omitempty"`
+	FunctionCall        *GeminiFunctionCall            `json:"functionCall,omitempty"`
+	FunctionResponse    *GeminiFunctionResponse        `json:"functionResponse,omitempty"`
 	FileData            *GeminiFileData                `json:"fileData,omitempty"`
 	ExecutableCode      *GeminiPartExecutableCode      `json:"executableCode,omitempty"`
 	CodeExecutionResult *GeminiPartCodeExecutionResult `json:"codeExecutionResult,omitempty"`
@@ -214,9 +214,9 @@ type GeminiEmbeddingRequest struct {
 }
 
 type GeminiEmbeddingResponse struct {
-	Embedding ContentEmbedding `json:"embedding"`
+	Embedding GeminiContentEmbedding `json:"embedding"`
 }
 
-type ContentEmbedding struct {
+type GeminiContentEmbedding struct {
 	Values []float64 `json:"values"`
 }
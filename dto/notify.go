@@ -13,6 +13,8 @@ const (
 	NotifyTypeQuotaExceed   = "quota_exceed"
 	NotifyTypeChannelUpdate = "channel_update"
 	NotifyTypeChannelTest   = "channel_test"
+	// 消费预算达到提醒比例
+	NotifyTypeBudgetSoftLimit = "budget_soft_limit"
 )
 
 func NewNotify(t string, title string, content string, values []interface{}) Notify {
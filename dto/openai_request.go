@@ -615,7 +615,7 @@ type OpenAIResponsesRequest struct {
 	PreviousResponseID string           `json:"previous_response_id,omitempty"`
 	Reasoning          *Reasoning       `json:"reasoning,omitempty"`
 	ServiceTier        string           `json:"service_tier,omitempty"`
-	Store              bool             `json:"store,omitempty"`
+	Store              *bool            `json:"store,omitempty"`
 	Stream             bool             `json:"stream,omitempty"`
 	Temperature        float64          `json:"temperature,omitempty"`
 	Text               json.RawMessage  `json:"text,omitempty"`
@@ -628,6 +628,55 @@ type OpenAIResponsesRequest struct {
 	Prompt             json.RawMessage  `json:"prompt,omitempty"`
 }
 
+func (r *OpenAIResponsesRequest) GetStore() bool {
+	return r.Store == nil || *r.Store
+}
+
+// ParseInputItems 将 input 统一解析为输入项数组，字符串输入视为一条 user 消息
+func (r *OpenAIResponsesRequest) ParseInputItems() ([]json.RawMessage, error) {
+	if len(r.Input) > 0 && r.Input[0] == '"' {
+		item, err := common.Marshal(ResponsesInputItem{
+			Type:    ResponsesItemTypeMessage,
+			Role:    "user",
+			Content: r.Input,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return []json.RawMessage{item}, nil
+	}
+	var items []json.RawMessage
+	if err := common.Unmarshal(r.Input, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// ResponsesInputItem /v1/responses input 数组中的输入项，同时兼容输出项（用于多轮对话回传）
+type ResponsesInputItem struct {
+	Type      string                   `json:"type,omitempty"`
+	ID        string                   `json:"id,omitempty"`
+	Role      string                   `json:"role,omitempty"`
+	Content   json.RawMessage          `json:"content,omitempty"`
+	Status    string                   `json:"status,omitempty"`
+	CallId    string                   `json:"call_id,omitempty"`
+	Name      string                   `json:"name,omitempty"`
+	Arguments string                   `json:"arguments,omitempty"`
+	Output    json.RawMessage          `json:"output,omitempty"`
+	Summary   []ResponsesOutputContent `json:"summary,omitempty"`
+}
+
+type ResponsesInputContent struct {
+	Type       string             `json:"type"`
+	Text       string             `json:"text,omitempty"`
+	ImageUrl   string             `json:"image_url,omitempty"`
+	FileId     string             `json:"file_id,omitempty"`
+	Detail     string             `json:"detail,omitempty"`
+	FileData   string             `json:"file_data,omitempty"`
+	Filename   string             `json:"filename,omitempty"`
+	InputAudio *MessageInputAudio `json:"input_audio,omitempty"`
+}
+
 type Reasoning struct {
 	Effort  string `json:"effort,omitempty"`
 	Summary string `json:"summary,omitempty"`
@@ -108,7 +108,22 @@ func main() {
 		gopool.Go(func() {
 			controller.UpdateTaskBulk()
 		})
+		gopool.Go(func() {
+			controller.UpdateBatchTasks()
+		})
+		gopool.Go(func() {
+			controller.UpdateFineTuneJobs()
+		})
+	}
+	if common.IsMasterNode {
+		gopool.Go(func() {
+			controller.CleanExpiredStoredResponses()
+		})
+		gopool.Go(func() {
+			controller.AutomaticallyGenerateInvoices()
+		})
 	}
+	model.InitQuotaRemainderUpdater()
 	if os.Getenv("BATCH_UPDATE_ENABLED") == "true" {
 		common.BatchUpdateEnabled = true
 		common.SysLog("batch update enabled with interval " + strconv.Itoa(common.BatchUpdateInterval) + "s")
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"one-api/common"
+	"one-api/constant"
 	"one-api/model"
 	"strconv"
 	"strings"
@@ -169,6 +170,41 @@ func RootAuth() func(c *gin.Context) {
 	}
 }
 
+// OrganizationAuth 在 UserAuth 之后使用，要求当前用户在路径中的组织拥有不低于 minRole 的角色
+func OrganizationAuth(minRole int) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		orgId, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无效的组织 id",
+			})
+			c.Abort()
+			return
+		}
+		member, err := model.GetOrganizationMember(orgId, c.GetInt64("id"))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "不是该组织的成员",
+			})
+			c.Abort()
+			return
+		}
+		if member.Role < minRole {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无权进行此操作，组织角色权限不足",
+			})
+			c.Abort()
+			return
+		}
+		common.SetContextKey(c, constant.ContextKeyOrganizationId, orgId)
+		common.SetContextKey(c, constant.ContextKeyOrganizationRole, member.Role)
+		c.Next()
+	}
+}
+
 func WssAuth(c *gin.Context) {
 
 }
@@ -305,6 +341,13 @@ func TokenAuth() func(c *gin.Context) {
 			return
 		}
 
+		if token.OrganizationId > 0 {
+			if err := model.CheckOrganizationTokenAccess(token.OrganizationId, token.UserId); err != nil {
+				abortWithOpenAiMessage(c, http.StatusForbidden, err.Error())
+				return
+			}
+		}
+
 		userCache.WriteContext(c)
 
 		err = SetupContextForToken(c, token, parts...)
@@ -335,6 +378,8 @@ func SetupContextForToken(c *gin.Context, token *model.Token, parts ...string) e
 	}
 	c.Set("allow_ips", token.GetIpLimitsMap())
 	c.Set("token_group", token.Group)
+	c.Set("token_response_cache", token.ResponseCache)
+	common.SetContextKey(c, constant.ContextKeyOrganizationId, token.OrganizationId)
 	if len(parts) > 1 {
 		if model.IsAdmin(token.UserId) {
 			c.Set("specific_channel_id", parts[1])
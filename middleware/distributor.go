@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"net/http"
@@ -8,11 +9,14 @@ import (
 	"one-api/constant"
 	"one-api/dto"
 	"one-api/model"
+	relaychannel "one-api/relay/channel"
 	relayconstant "one-api/relay/constant"
 	"one-api/service"
 	"one-api/setting"
+	"one-api/setting/operation_setting"
 	"one-api/setting/ratio_setting"
 	"one-api/types"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -100,9 +104,47 @@ func Distribute() func(c *gin.Context) {
 				}
 			}
 
-			if shouldSelectChannel {
+			if shouldSelectChannel && channel == nil {
+				if strings.HasPrefix(c.Request.URL.Path, "/v1/messages") {
+					// 跳过无法处理 Claude Messages 请求的渠道，重试时同样生效
+					common.SetContextKey(c, constant.ContextKeyChannelFilter, model.ChannelFilter(func(channel *model.Channel) bool {
+						return relaychannel.SupportClaudeRequest(channel.Type)
+					}))
+				} else if strings.HasPrefix(c.Request.URL.Path, "/v1/responses") {
+					// 跳过无法处理 Responses 请求的渠道
+					common.SetContextKey(c, constant.ContextKeyChannelFilter, model.ChannelFilter(func(channel *model.Channel) bool {
+						return relaychannel.SupportResponsesRequest(channel.Type)
+					}))
+				} else if c.GetInt("relay_mode") == relayconstant.RelayModeGemini {
+					// 跳过无法处理 Gemini 原生请求的渠道
+					common.SetContextKey(c, constant.ContextKeyChannelFilter, model.ChannelFilter(func(channel *model.Channel) bool {
+						return relaychannel.SupportGeminiRequest(channel.Type)
+					}))
+				}
+				// 路由规则在选择渠道前生效，重试时同样遵守规则限定的渠道范围
+				applyRoutingRule(c, userGroup, modelRequest)
 				var selectGroup string
-				channel, selectGroup, err = model.CacheGetRandomSatisfiedChannel(c, userGroup, modelRequest.Model, 0)
+				// 用户私有的微调模型固定到创建任务的渠道
+				channel = getFineTunedModelChannel(c, userGroup, modelRequest.Model)
+				if channel == nil {
+					// 请求引用了透传到上游的文件时，固定到文件所在渠道
+					channel = getFileBoundChannel(c, userGroup, modelRequest.Model)
+				}
+				if channel == nil {
+					// 会话粘性命中时沿用绑定的渠道
+					channel = applySessionAffinity(c, userGroup, modelRequest.Model)
+				}
+				if channel == nil {
+					channel, selectGroup, err = model.CacheGetRandomSatisfiedChannel(c, userGroup, modelRequest.Model, 0)
+				}
+				if err != nil && SupportModelFallback(relayconstant.Path2RelayMode(c.Request.URL.Path)) {
+					// 没有可用渠道时按备用链改用其他模型
+					fallbackModels := operation_setting.GetModelFallbackChain(userGroup, modelRequest.Model)
+					if fallbackChannel, fallbackModel, _ := SelectFallbackModelChannel(c, userGroup, fallbackModels); fallbackChannel != nil {
+						MarkModelFallback(c, modelRequest.Model, fallbackModel)
+						channel, modelRequest.Model, err = fallbackChannel, fallbackModel, nil
+					}
+				}
 				if err != nil {
 					showGroup := userGroup
 					if userGroup == "auto" {
@@ -114,6 +156,12 @@ func Distribute() func(c *gin.Context) {
 						common.SysError(fmt.Sprintf("渠道不存在：%d", channel.Id))
 						message = "数据库一致性已被破坏，请联系管理员"
 					}
+					var windowErr *model.ContextWindowError
+					if errors.As(err, &windowErr) {
+						// 有渠道仅因上下文窗口不足被跳过，属于请求本身的问题
+						abortWithOpenAiMessage(c, http.StatusBadRequest, fmt.Sprintf("请求上下文过长：提示 tokens %d 超过模型 %s 可用渠道的最大上下文窗口 %d", windowErr.PromptTokens, modelRequest.Model, windowErr.MaxWindow))
+						return
+					}
 					// 如果错误，而且渠道为空，说明是没有可用渠道
 					abortWithOpenAiMessage(c, http.StatusServiceUnavailable, message)
 					return
@@ -130,6 +178,49 @@ func Distribute() func(c *gin.Context) {
 	}
 }
 
+// getFineTunedModelChannel 查找用户私有微调模型绑定的渠道，渠道需能为用户分组下的基础模型提供服务并满足本次请求的筛选条件，并记录基础模型用于计费。
+// 微调模型只存在于该渠道，按指定渠道处理，重试、备用模型和排队都不再换渠道，直接返回上游错误
+func getFineTunedModelChannel(c *gin.Context, group string, modelName string) *model.Channel {
+	if !strings.HasPrefix(modelName, "ft:") && !strings.Contains(modelName, ":ft-") {
+		return nil
+	}
+	job, err := model.GetUserFineTunedModel(c.GetInt64("id"), modelName)
+	if err != nil {
+		return nil
+	}
+	channel, _, ok := model.CacheGetChannelForGroupModel(c, group, job.BaseModel, job.ChannelId)
+	if !ok {
+		return nil
+	}
+	common.SetContextKey(c, constant.ContextKeyFineTuneBase, job.BaseModel)
+	common.SetContextKey(c, constant.ContextKeyTokenSpecificChannelId, strconv.Itoa(channel.Id))
+	return channel
+}
+
+var fileIdPattern = regexp.MustCompile(`"(file-[A-Za-z0-9_-]+)"`)
+
+// getFileBoundChannel 查找请求体中引用的 file id 绑定的渠道，渠道需能为用户分组下的模型提供服务并满足本次请求的筛选条件，未找到返回 nil
+func getFileBoundChannel(c *gin.Context, group string, modelName string) *model.Channel {
+	if !strings.HasPrefix(c.Request.Header.Get("Content-Type"), "application/json") {
+		return nil
+	}
+	requestBody, err := common.GetRequestBody(c)
+	if err != nil || !bytes.Contains(requestBody, []byte(`"file-`)) {
+		return nil
+	}
+	userId := c.GetInt64("id")
+	for _, match := range fileIdPattern.FindAllSubmatch(requestBody, 8) {
+		channelId := model.GetFileBoundChannelId(userId, string(match[1]))
+		if channelId == 0 {
+			continue
+		}
+		if channel, _, ok := model.CacheGetChannelForGroupModel(c, group, modelName, channelId); ok {
+			return channel
+		}
+	}
+	return nil
+}
+
 func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 	var modelRequest ModelRequest
 	shouldSelectChannel := true
@@ -279,16 +370,24 @@ func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, mode
 		common.SetContextKey(c, constant.ContextKeyChannelOrganization, *channel.OpenAIOrganization)
 	}
 	common.SetContextKey(c, constant.ContextKeyChannelAutoBan, channel.GetAutoBan())
+	common.SetContextKey(c, constant.ContextKeyChannelContextWindow, channel.GetContextWindow(modelName))
 	common.SetContextKey(c, constant.ContextKeyChannelModelMapping, channel.GetModelMapping())
 	common.SetContextKey(c, constant.ContextKeyChannelStatusCodeMapping, channel.GetStatusCodeMapping())
 
-	key, index, newAPIError := channel.GetNextEnabledKey()
-	if newAPIError != nil {
-		return newAPIError
+	key, index, ok := getSessionAffinityKey(c, channel)
+	if !ok {
+		var newAPIError *types.NewAPIError
+		key, index, newAPIError = channel.GetNextEnabledKey()
+		if newAPIError != nil {
+			return newAPIError
+		}
 	}
 	if channel.ChannelInfo.IsMultiKey {
 		common.SetContextKey(c, constant.ContextKeyChannelIsMultiKey, true)
 		common.SetContextKey(c, constant.ContextKeyChannelMultiKeyIndex, index)
+	} else {
+		// 重试切换到单 Key 渠道时清除上一个渠道的多 Key 信息
+		common.SetContextKey(c, constant.ContextKeyChannelIsMultiKey, false)
 	}
 	// c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", key))
 	common.SetContextKey(c, constant.ContextKeyChannelKey, key)
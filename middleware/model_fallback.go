@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	relayconstant "one-api/relay/constant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SupportModelFallback 模型在请求体中的文本类接口支持改用备用模型
+func SupportModelFallback(relayMode int) bool {
+	switch relayMode {
+	case relayconstant.RelayModeChatCompletions, relayconstant.RelayModeCompletions,
+		relayconstant.RelayModeEmbeddings, relayconstant.RelayModeResponses:
+		return true
+	}
+	return false
+}
+
+// IsTokenModelAllowed 令牌是否允许访问该模型
+func IsTokenModelAllowed(c *gin.Context, modelName string) bool {
+	if !common.GetContextKeyBool(c, constant.ContextKeyTokenModelLimitEnabled) {
+		return true
+	}
+	tokenModelLimit, _ := common.GetContextKeyType[map[string]bool](c, constant.ContextKeyTokenModelLimit)
+	return tokenModelLimit[modelName]
+}
+
+// SelectFallbackModelChannel 依次尝试备用模型，返回第一个令牌允许访问且有可用渠道的模型、渠道以及其后剩余的备用模型
+func SelectFallbackModelChannel(c *gin.Context, group string, fallbackModels []string) (*model.Channel, string, []string) {
+	for i, fallbackModel := range fallbackModels {
+		if !IsTokenModelAllowed(c, fallbackModel) {
+			continue
+		}
+		channel, _, err := model.CacheGetRandomSatisfiedChannel(c, group, fallbackModel, 0)
+		if err != nil || channel == nil {
+			continue
+		}
+		return channel, fallbackModel, fallbackModels[i+1:]
+	}
+	return nil, "", nil
+}
+
+// MarkModelFallback 记录原始请求的模型，并在响应头中返回实际使用的模型
+func MarkModelFallback(c *gin.Context, requestedModel string, fallbackModel string) {
+	if _, ok := common.GetContextKey(c, constant.ContextKeyModelFallback); !ok {
+		common.SetContextKey(c, constant.ContextKeyModelFallback, requestedModel)
+	}
+	c.Header("X-Fallback-Model", fallbackModel)
+	common.LogInfo(c, fmt.Sprintf("model fallback: %s -> %s", requestedModel, fallbackModel))
+}
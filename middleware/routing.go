@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// applyRoutingRule 按路由规则改写请求的模型，或将渠道选择限定在规则指定的范围内
+func applyRoutingRule(c *gin.Context, group string, modelRequest *ModelRequest) {
+	if !operation_setting.GetRoutingSetting().Enabled {
+		return
+	}
+	rule, _ := service.MatchRoutingRule(service.NewRoutingRequest(c, group, modelRequest.Model))
+	if rule == nil {
+		return
+	}
+	common.SetContextKey(c, constant.ContextKeyRoutingRule, rule.Name)
+	if common.DebugEnabled {
+		common.LogInfo(c, fmt.Sprintf("routing rule %s matched", rule.Name))
+	}
+	if rule.Action.Model != "" {
+		modelRequest.Model = rule.Action.Model
+	}
+	if rule.Action.ChannelTag == "" && len(rule.Action.ChannelIds) == 0 {
+		return
+	}
+	action := rule.Action
+	filter, _ := common.GetContextKeyType[model.ChannelFilter](c, constant.ContextKeyChannelFilter)
+	common.SetContextKey(c, constant.ContextKeyChannelFilter, model.ChannelFilter(func(channel *model.Channel) bool {
+		if !service.RoutingChannelAllowed(&action, channel) {
+			return false
+		}
+		return filter == nil || filter(channel)
+	}))
+}
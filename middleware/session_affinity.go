@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"one-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// applySessionAffinity 会话已绑定渠道且该渠道仍可用时直接使用，否则返回 nil 按正常方式选择
+func applySessionAffinity(c *gin.Context, group string, modelName string) *model.Channel {
+	key := service.GetSessionAffinityKey(c, group, modelName)
+	if key == "" {
+		return nil
+	}
+	// 记录会话标识，请求成功后绑定到最终使用的渠道
+	common.SetContextKey(c, constant.ContextKeySessionAffinity, key)
+	binding, ok := service.GetSessionAffinity(key)
+	if !ok {
+		return nil
+	}
+	channel, _, ok := model.CacheGetChannelForGroupModel(c, group, modelName, binding.ChannelId)
+	if !ok {
+		return nil
+	}
+	common.SetContextKey(c, constant.ContextKeySessionBinding, binding)
+	if common.DebugEnabled {
+		common.LogInfo(c, fmt.Sprintf("session affinity hit channel #%d key #%d", binding.ChannelId, binding.KeyIndex))
+	}
+	return channel
+}
+
+// getSessionAffinityKey 渠道为会话绑定的多 Key 渠道时优先使用绑定的 Key
+func getSessionAffinityKey(c *gin.Context, channel *model.Channel) (string, int, bool) {
+	binding, ok := common.GetContextKeyType[*service.SessionAffinityBinding](c, constant.ContextKeySessionBinding)
+	if !ok || binding == nil || binding.ChannelId != channel.Id {
+		return "", 0, false
+	}
+	key, ok := channel.GetEnabledKeyByIndex(binding.KeyIndex)
+	return key, binding.KeyIndex, ok
+}
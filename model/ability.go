@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"one-api/common"
+	"one-api/dto"
+	"one-api/setting/operation_setting"
 	"strings"
 	"sync"
 
@@ -102,7 +104,7 @@ func getChannelQuery(group string, model string, retry int) (*gorm.DB, error) {
 	return channelQuery, nil
 }
 
-func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel, error) {
+func GetRandomSatisfiedChannel(group string, model string, retry int, filter ChannelFilter) (*Channel, error) {
 	var abilities []Ability
 
 	var err error = nil
@@ -118,8 +120,20 @@ func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 	if err != nil {
 		return nil, err
 	}
+	if filter != nil {
+		abilities, err = filterAbilities(abilities, filter)
+		if err != nil {
+			return nil, err
+		}
+	}
 	channel := Channel{}
-	if len(abilities) > 0 {
+	if strategy := operation_setting.GetGroupChannelSelectStrategy(group); len(abilities) > 0 && strategy != operation_setting.ChannelSelectWeightedRandom {
+		candidates := make([]channelCandidate, 0, len(abilities))
+		for _, ability_ := range abilities {
+			candidates = append(candidates, channelCandidate{Id: ability_.ChannelId, Weight: int(ability_.Weight)})
+		}
+		channel.Id = selectChannelByStats(strategy, model, candidates)
+	} else if len(abilities) > 0 {
 		// Randomly choose one
 		weightSum := uint(0)
 		for _, ability_ := range abilities {
@@ -142,6 +156,39 @@ func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 	return &channel, err
 }
 
+// channelFilterColumns 渠道筛选条件用到的字段
+var channelFilterColumns = []string{"id", "type", "status", "tag", "setting", "max_input_tokens", "context_windows"}
+
+// filterAbilities 按渠道筛选条件过滤候选 ability，只加载筛选用到的字段
+func filterAbilities(abilities []Ability, filter ChannelFilter) ([]Ability, error) {
+	if len(abilities) == 0 {
+		return abilities, nil
+	}
+	channelIds := make([]int, 0, len(abilities))
+	for _, ability_ := range abilities {
+		channelIds = append(channelIds, ability_.ChannelId)
+	}
+	var channels []*Channel
+	if err := DB.Select(channelFilterColumns).Where("id in ?", channelIds).Find(&channels).Error; err != nil {
+		return nil, err
+	}
+	allowed := make(map[int]bool, len(channels))
+	for _, channel := range channels {
+		// 只加载了部分字段，设置无法解析时不能由 GetSetting 回写整行
+		if channel.Setting != nil && common.Unmarshal([]byte(*channel.Setting), &dto.ChannelSettings{}) != nil {
+			channel.Setting = nil
+		}
+		allowed[channel.Id] = filter(channel)
+	}
+	filtered := make([]Ability, 0, len(abilities))
+	for _, ability_ := range abilities {
+		if allowed[ability_.ChannelId] {
+			filtered = append(filtered, ability_)
+		}
+	}
+	return filtered, nil
+}
+
 func (channel *Channel) AddAbilities() error {
 	models_ := strings.Split(channel.Models, ",")
 	groups_ := strings.Split(channel.Group, ",")
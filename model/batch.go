@@ -0,0 +1,227 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+
+	"gorm.io/gorm"
+)
+
+// Batch OpenAI 批处理任务，状态沿用 Task 的生命周期，
+// OpenAI 的 cancelling/cancelled/expired 等状态由对应时间戳推导
+type Batch struct {
+	Id               int64      `json:"-"`
+	BatchId          string     `json:"id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId           int64      `json:"-" gorm:"index"`
+	TokenId          int        `json:"-" gorm:"index"`
+	ChannelId        int        `json:"-" gorm:"index"` // 转发到上游执行时绑定的渠道，本地执行为 0
+	KeyIndex         int        `json:"-"`
+	Endpoint         string     `json:"endpoint" gorm:"type:varchar(64)"`
+	InputFileId      string     `json:"input_file_id" gorm:"type:varchar(64)"`
+	OutputFileId     string     `json:"output_file_id" gorm:"type:varchar(64)"`
+	ErrorFileId      string     `json:"error_file_id" gorm:"type:varchar(64)"`
+	CompletionWindow string     `json:"completion_window" gorm:"type:varchar(16)"`
+	Status           TaskStatus `json:"status" gorm:"type:varchar(20);index"`
+	FailReason       string     `json:"fail_reason"`
+	Metadata         string     `json:"metadata"`
+	RequestTotal     int        `json:"request_total"`
+	RequestCompleted int        `json:"request_completed"`
+	RequestFailed    int        `json:"request_failed"`
+	Quota            int        `json:"quota"`
+	CreatedAt        int64      `json:"created_at" gorm:"bigint;index"`
+	InProgressAt     int64      `json:"in_progress_at" gorm:"bigint"`
+	FinalizingAt     int64      `json:"finalizing_at" gorm:"bigint"`
+	CompletedAt      int64      `json:"completed_at" gorm:"bigint"`
+	FailedAt         int64      `json:"failed_at" gorm:"bigint"`
+	ExpiresAt        int64      `json:"expires_at" gorm:"bigint"`
+	ExpiredAt        int64      `json:"expired_at" gorm:"bigint"`
+	CancellingAt     int64      `json:"cancelling_at" gorm:"bigint"`
+	CancelledAt      int64      `json:"cancelled_at" gorm:"bigint"`
+	// 使用组织令牌创建时的组织，上游批处理结算时从组织额度池扣除
+	OrganizationId int `json:"-" gorm:"index;default:0"`
+	// 转发到上游时预扣的额度，结算后退回
+	PreConsumedQuota int `json:"-" gorm:"default:0"`
+}
+
+func (batch *Batch) IsUpstream() bool {
+	return batch.ChannelId != 0
+}
+
+func (batch *Batch) IsFinished() bool {
+	return batch.Status == TaskStatusSuccess || batch.Status == TaskStatusFailure
+}
+
+// OpenAIStatus 转换为 OpenAI 批处理状态
+func (batch *Batch) OpenAIStatus() string {
+	switch batch.Status {
+	case TaskStatusSuccess:
+		return "completed"
+	case TaskStatusFailure:
+		if batch.CancelledAt != 0 {
+			return "cancelled"
+		}
+		if batch.ExpiredAt != 0 {
+			return "expired"
+		}
+		return "failed"
+	case TaskStatusInProgress:
+		if batch.CancellingAt != 0 {
+			return "cancelling"
+		}
+		if batch.FinalizingAt != 0 {
+			return "finalizing"
+		}
+		return "in_progress"
+	default:
+		if batch.CancellingAt != 0 {
+			return "cancelling"
+		}
+		return "validating"
+	}
+}
+
+func (batch *Batch) Insert() error {
+	if batch.CreatedAt == 0 {
+		batch.CreatedAt = common.GetTimestamp()
+	}
+	return DB.Create(batch).Error
+}
+
+// SaveUpstreamState 写入从上游同步的状态。未完成的任务只在数据库中仍未完成时写入，
+// 已完成的任务只由完成切换方写入；取消标记只追加不清除，避免覆盖并发写入的取消
+func (batch *Batch) SaveUpstreamState() error {
+	columns := []string{"status", "output_file_id", "error_file_id", "fail_reason", "created_at",
+		"in_progress_at", "expires_at", "finalizing_at", "completed_at", "failed_at", "expired_at", "cancelled_at",
+		"request_total", "request_completed", "request_failed", "quota"}
+	if batch.CancellingAt != 0 {
+		columns = append(columns, "cancelling_at")
+	}
+	query := DB.Model(batch).Select(columns)
+	if batch.IsFinished() {
+		query = query.Where("status = ?", batch.Status)
+	} else {
+		query = query.Where("status NOT IN ?", []TaskStatus{TaskStatusSuccess, TaskStatusFailure})
+	}
+	return query.Updates(batch).Error
+}
+
+// UpdateProgress 仅更新计数，避免覆盖并发写入的取消标记
+func (batch *Batch) UpdateProgress() error {
+	return DB.Model(batch).Select("request_total", "request_completed", "request_failed", "quota").Updates(batch).Error
+}
+
+func GetUserBatchByBatchId(userId int64, batchId string) (*Batch, error) {
+	if batchId == "" {
+		return nil, errors.New("batch id 为空！")
+	}
+	var batch Batch
+	err := DB.Where("user_id = ? and batch_id = ?", userId, batchId).First(&batch).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("批处理任务不存在")
+		}
+		return nil, err
+	}
+	return &batch, nil
+}
+
+func GetBatchById(id int64) (*Batch, error) {
+	var batch Batch
+	err := DB.First(&batch, "id = ?", id).Error
+	return &batch, err
+}
+
+func GetUserBatches(userId int64, after string, limit int) ([]*Batch, error) {
+	var batches []*Batch
+	query := DB.Where("user_id = ?", userId)
+	if after != "" {
+		afterBatch, err := GetUserBatchByBatchId(userId, after)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("id < ?", afterBatch.Id)
+	}
+	err := query.Order("id desc").Limit(limit).Find(&batches).Error
+	return batches, err
+}
+
+// GetUnfinishedBatches 获取待执行或执行中的批处理任务
+func GetUnfinishedBatches(limit int) []*Batch {
+	var batches []*Batch
+	err := DB.Where("status NOT IN ?", []TaskStatus{TaskStatusSuccess, TaskStatusFailure}).
+		Order("id asc").Limit(limit).Find(&batches).Error
+	if err != nil {
+		return nil
+	}
+	return batches
+}
+
+// MarkBatchCancelling 标记取消，由执行方感知后完成取消
+func MarkBatchCancelling(id int64) error {
+	return DB.Model(&Batch{}).Where("id = ? and cancelling_at = 0", id).Update("cancelling_at", common.GetTimestamp()).Error
+}
+
+// StartBatch 将待执行且未被取消的任务切换为执行中，返回是否切换成功
+func StartBatch(batch *Batch) (bool, error) {
+	now := common.GetTimestamp()
+	result := DB.Model(&Batch{}).Where("id = ? and status = ? and cancelling_at = 0", batch.Id, TaskStatusSubmitted).
+		Updates(map[string]any{
+			"status":         TaskStatusInProgress,
+			"in_progress_at": now,
+			"request_total":  batch.RequestTotal,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected != 1 {
+		return false, nil
+	}
+	batch.Status = TaskStatusInProgress
+	batch.InProgressAt = now
+	return true, nil
+}
+
+// CancelSubmittedBatch 直接取消尚未开始执行的任务，返回是否取消成功；已开始执行的任务由 worker 感知取消标记后结束
+func CancelSubmittedBatch(id int64) (bool, error) {
+	now := common.GetTimestamp()
+	result := DB.Model(&Batch{}).Where("id = ? and status = ?", id, TaskStatusSubmitted).
+		Updates(map[string]any{
+			"status":        TaskStatusFailure,
+			"cancelling_at": gorm.Expr("case when cancelling_at = 0 then ? else cancelling_at end", now),
+			"cancelled_at":  now,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// FailBatch 将未完成的任务标记为失败，已被标记取消的任务同时记为已取消
+func FailBatch(id int64, reason string) error {
+	now := common.GetTimestamp()
+	return DB.Model(&Batch{}).Where("id = ? and status NOT IN ?", id, []TaskStatus{TaskStatusSuccess, TaskStatusFailure}).
+		Updates(map[string]any{
+			"status":       TaskStatusFailure,
+			"fail_reason":  reason,
+			"failed_at":    now,
+			"cancelled_at": gorm.Expr("case when cancelling_at <> 0 then ? else cancelled_at end", now),
+		}).Error
+}
+
+// FinishLocalBatch 写入本地执行结束后的结果，只更新执行中任务的执行方负责的字段
+func (batch *Batch) FinishLocalBatch() error {
+	return DB.Model(batch).Where("status = ?", TaskStatusInProgress).
+		Select("status", "output_file_id", "error_file_id", "finalizing_at", "completed_at", "expired_at", "cancelled_at",
+			"request_total", "request_completed", "request_failed", "quota").
+		Updates(batch).Error
+}
+
+// MarkBatchFinished 将未完成的批处理任务切换为完成状态，返回是否由本次调用完成切换，只有切换成功的一方负责登记结果文件和结算
+func MarkBatchFinished(id int64, status TaskStatus) (bool, error) {
+	result := DB.Model(&Batch{}).Where("id = ? and status NOT IN ?", id, []TaskStatus{TaskStatusSuccess, TaskStatusFailure}).
+		Update("status", status)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
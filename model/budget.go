@@ -0,0 +1,357 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	BudgetScopeUser  = "user"
+	BudgetScopeToken = "token"
+	BudgetScopeGroup = "group"
+)
+
+const (
+	BudgetPeriodDaily   = "daily"
+	BudgetPeriodWeekly  = "weekly"
+	BudgetPeriodMonthly = "monthly"
+)
+
+// Budget 按周期重置的消费预算，可以作用于用户、令牌或分组
+type Budget struct {
+	Id     int    `json:"id"`
+	Scope  string `json:"scope" gorm:"type:varchar(16);index"`
+	UserId int64  `json:"user_id" gorm:"index"` // 用户预算的用户，令牌预算为令牌所属用户
+	// 令牌预算的令牌
+	TokenId int `json:"token_id" gorm:"index"`
+	// 分组预算的分组，统计该分组下所有用户的消费
+	Group  string `json:"group" gorm:"column:group_name;type:varchar(64);index"`
+	Period string `json:"period" gorm:"type:varchar(16)"`
+	// 每个周期可消费的额度
+	QuotaLimit int `json:"quota_limit"`
+	// 消费达到上限的百分比时提醒，0 表示不提醒
+	SoftLimitPercent int   `json:"soft_limit_percent"`
+	Used             int   `json:"used"`
+	PeriodStart      int64 `json:"period_start" gorm:"bigint"`
+	SoftNotified     bool  `json:"soft_notified"`
+	CreatedTime      int64 `json:"created_time" gorm:"bigint"`
+	// 管理员设置的预算，用户不能删除
+	Locked bool `json:"locked"`
+	// 当前周期的结束时间，仅用于展示
+	ResetTime int64 `json:"reset_time" gorm:"-:all"`
+	// Refresh 之前数据库中的周期开始时间，条件更新时按此匹配
+	StoredPeriodStart int64 `json:"-" gorm:"-:all"`
+}
+
+var (
+	budgetCount          int64
+	budgetCountUpdatedAt time.Time
+	budgetCountLock      sync.Mutex
+)
+
+// hasBudgets 是否存在预算，没有预算时跳过查询，数量每分钟从数据库刷新一次
+func hasBudgets() bool {
+	budgetCountLock.Lock()
+	defer budgetCountLock.Unlock()
+	if time.Since(budgetCountUpdatedAt) > time.Minute {
+		var count int64
+		if err := DB.Model(&Budget{}).Count(&count).Error; err == nil {
+			budgetCount = count
+			budgetCountUpdatedAt = time.Now()
+		}
+	}
+	return budgetCount > 0
+}
+
+func resetBudgetCount() {
+	budgetCountLock.Lock()
+	budgetCountUpdatedAt = time.Time{}
+	budgetCountLock.Unlock()
+}
+
+// budgetPeriodRange 返回 now 所在周期的起止时间，按服务器时区计算，周预算从周一开始
+func budgetPeriodRange(period string, now time.Time) (int64, int64) {
+	y, m, d := now.Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	switch period {
+	case BudgetPeriodWeekly:
+		start := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		return start.Unix(), start.AddDate(0, 0, 7).Unix()
+	case BudgetPeriodMonthly:
+		start := time.Date(y, m, 1, 0, 0, 0, 0, now.Location())
+		return start.Unix(), start.AddDate(0, 1, 0).Unix()
+	default:
+		return day.Unix(), day.AddDate(0, 0, 1).Unix()
+	}
+}
+
+// Refresh 进入新周期后清零已用额度，只修改内存中的值
+func (budget *Budget) Refresh() {
+	start, end := budgetPeriodRange(budget.Period, time.Now())
+	budget.StoredPeriodStart = budget.PeriodStart
+	if budget.PeriodStart < start {
+		budget.Used = 0
+		budget.PeriodStart = start
+		budget.SoftNotified = false
+	}
+	budget.ResetTime = end
+}
+
+// SoftLimit 提醒阈值，未开启提醒时返回 0
+func (budget *Budget) SoftLimit() int {
+	if budget.SoftLimitPercent <= 0 || budget.SoftLimitPercent >= 100 {
+		return 0
+	}
+	return budget.QuotaLimit * budget.SoftLimitPercent / 100
+}
+
+func (budget *Budget) Validate() error {
+	switch budget.Scope {
+	case BudgetScopeUser:
+		if budget.UserId == 0 {
+			return errors.New("用户预算缺少用户")
+		}
+	case BudgetScopeToken:
+		if budget.TokenId == 0 {
+			return errors.New("令牌预算缺少令牌")
+		}
+	case BudgetScopeGroup:
+		if budget.Group == "" {
+			return errors.New("分组预算缺少分组")
+		}
+	default:
+		return errors.New("无效的预算范围")
+	}
+	switch budget.Period {
+	case BudgetPeriodDaily, BudgetPeriodWeekly, BudgetPeriodMonthly:
+	default:
+		return errors.New("无效的预算周期")
+	}
+	if budget.QuotaLimit <= 0 {
+		return errors.New("预算额度必须大于 0")
+	}
+	if budget.SoftLimitPercent < 0 || budget.SoftLimitPercent >= 100 {
+		return errors.New("提醒比例必须在 0-99 之间")
+	}
+	return nil
+}
+
+func (budget *Budget) Insert() error {
+	if budget.CreatedTime == 0 {
+		budget.CreatedTime = common.GetTimestamp()
+	}
+	budget.Used = 0
+	budget.SoftNotified = false
+	budget.PeriodStart, _ = budgetPeriodRange(budget.Period, time.Now())
+	err := DB.Create(budget).Error
+	resetBudgetCount()
+	invalidateBudgetCache(budget)
+	return err
+}
+
+// Update 只更新额度和提醒比例，修改周期时从当前周期重新计算
+func (budget *Budget) Update() error {
+	updates := map[string]interface{}{
+		"quota_limit":        budget.QuotaLimit,
+		"soft_limit_percent": budget.SoftLimitPercent,
+		"soft_notified":      false,
+	}
+	var old Budget
+	if err := DB.First(&old, budget.Id).Error; err != nil {
+		return err
+	}
+	if old.Period != budget.Period {
+		start, _ := budgetPeriodRange(budget.Period, time.Now())
+		updates["period"] = budget.Period
+		updates["period_start"] = start
+		updates["used"] = 0
+	}
+	err := DB.Model(&Budget{}).Where("id = ?", budget.Id).Updates(updates).Error
+	invalidateBudgetCache(&old)
+	return err
+}
+
+func (budget *Budget) Delete() error {
+	err := DB.Delete(budget).Error
+	resetBudgetCount()
+	invalidateBudgetCache(budget)
+	return err
+}
+
+func GetBudgetById(id int) (*Budget, error) {
+	var budget Budget
+	if err := DB.First(&budget, id).Error; err != nil {
+		return nil, err
+	}
+	budget.Refresh()
+	return &budget, nil
+}
+
+func GetAllBudgets(scope string, startIdx int, num int) ([]*Budget, int64, error) {
+	var budgets []*Budget
+	var total int64
+	tx := DB.Model(&Budget{})
+	if scope != "" {
+		tx = tx.Where("scope = ?", scope)
+	}
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := tx.Order("id desc").Limit(num).Offset(startIdx).Find(&budgets).Error; err != nil {
+		return nil, 0, err
+	}
+	for _, budget := range budgets {
+		budget.Refresh()
+	}
+	return budgets, total, nil
+}
+
+// GetUserBudgets 用户自己的预算及其令牌的预算
+func GetUserBudgets(userId int64) ([]*Budget, error) {
+	if !hasBudgets() {
+		return nil, nil
+	}
+	var budgets []*Budget
+	err := DB.Where("user_id = ? and scope in ?", userId, []string{BudgetScopeUser, BudgetScopeToken}).Order("id").Find(&budgets).Error
+	for _, budget := range budgets {
+		budget.Refresh()
+	}
+	return budgets, err
+}
+
+// GetTokenBudgets 按令牌 id 分组返回令牌预算
+func GetTokenBudgets(tokenIds []int) (map[int][]*Budget, error) {
+	result := make(map[int][]*Budget)
+	if len(tokenIds) == 0 || !hasBudgets() {
+		return result, nil
+	}
+	var budgets []*Budget
+	err := DB.Where("scope = ? and token_id in ?", BudgetScopeToken, tokenIds).Order("id").Find(&budgets).Error
+	for _, budget := range budgets {
+		budget.Refresh()
+		result[budget.TokenId] = append(result[budget.TokenId], budget)
+	}
+	return result, err
+}
+
+// getMatchingBudgets 请求匹配的用户、令牌和分组预算，开启 Redis 时从缓存读取
+func getMatchingBudgets(userId int64, tokenId int, group string) ([]*Budget, error) {
+	if !hasBudgets() {
+		return nil, nil
+	}
+	if common.RedisEnabled {
+		budgets, err := cacheGetScopeBudgets(BudgetScopeUser, strconv.FormatInt(userId, 10), "user_id = ?", userId)
+		if err != nil {
+			return nil, err
+		}
+		if tokenId != 0 {
+			tokenBudgets, err := cacheGetScopeBudgets(BudgetScopeToken, strconv.Itoa(tokenId), "token_id = ?", tokenId)
+			if err != nil {
+				return nil, err
+			}
+			budgets = append(budgets, tokenBudgets...)
+		}
+		if group != "" {
+			groupBudgets, err := cacheGetScopeBudgets(BudgetScopeGroup, group, "group_name = ?", group)
+			if err != nil {
+				return nil, err
+			}
+			budgets = append(budgets, groupBudgets...)
+		}
+		return budgets, nil
+	}
+	var budgets []*Budget
+	err := DB.Where("(scope = ? and user_id = ?) or (scope = ? and token_id = ?) or (scope = ? and group_name = ?)",
+		BudgetScopeUser, userId, BudgetScopeToken, tokenId, BudgetScopeGroup, group).Find(&budgets).Error
+	return budgets, err
+}
+
+// TokenWithBudgets 附带周期预算的令牌，预算不能放在 Token 中，Token 会以哈希形式缓存到 Redis
+type TokenWithBudgets struct {
+	*Token
+	Budgets []*Budget `json:"budgets,omitempty"`
+}
+
+// WithTokenBudgets 为令牌列表附加预算状态
+func WithTokenBudgets(tokens []*Token) ([]*TokenWithBudgets, error) {
+	ids := make([]int, 0, len(tokens))
+	for _, token := range tokens {
+		ids = append(ids, token.Id)
+	}
+	budgets, err := GetTokenBudgets(ids)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*TokenWithBudgets, 0, len(tokens))
+	for _, token := range tokens {
+		result = append(result, &TokenWithBudgets{Token: token, Budgets: budgets[token.Id]})
+	}
+	return result, nil
+}
+
+// GetMatchingBudgets 请求需要满足的用户、令牌和分组预算
+func GetMatchingBudgets(userId int64, tokenId int, group string) ([]*Budget, error) {
+	budgets, err := getMatchingBudgets(userId, tokenId, group)
+	if err != nil {
+		return nil, err
+	}
+	for _, budget := range budgets {
+		budget.Refresh()
+	}
+	return budgets, nil
+}
+
+// RecordBudgetSpend 将本次消费计入匹配的预算，进入新周期时先清零
+func RecordBudgetSpend(userId int64, tokenId int, group string, quota int) {
+	if quota <= 0 {
+		return
+	}
+	budgets, err := getMatchingBudgets(userId, tokenId, group)
+	if err != nil {
+		common.SysError("failed to get budgets: " + err.Error())
+		return
+	}
+	for _, budget := range budgets {
+		if start, _ := budgetPeriodRange(budget.Period, time.Now()); budget.PeriodStart < start {
+			// 只有一个请求能完成重置，其余请求继续累加
+			result := DB.Model(&Budget{}).Where("id = ? and period_start = ?", budget.Id, budget.PeriodStart).
+				Updates(map[string]interface{}{"used": quota, "period_start": start, "soft_notified": false})
+			if result.Error == nil && result.RowsAffected > 0 {
+				if err = cacheDeleteBudget(budget.Id); err != nil {
+					common.SysError("failed to invalidate budget cache: " + err.Error())
+				}
+				continue
+			}
+			// 其他请求已完成重置，缓存中仍是上一周期的数据
+			if err = cacheDeleteBudget(budget.Id); err != nil {
+				common.SysError("failed to invalidate budget cache: " + err.Error())
+			}
+		}
+		err = DB.Model(&Budget{}).Where("id = ?", budget.Id).Update("used", gorm.Expr("used + ?", quota)).Error
+		if err != nil {
+			common.SysError("failed to record budget spend: " + err.Error())
+			continue
+		}
+		if err = cacheIncrBudgetUsed(budget.Id, int64(quota)); err != nil {
+			common.SysError("failed to update budget cache: " + err.Error())
+		}
+	}
+}
+
+// MarkBudgetSoftNotified 标记本周期已发送提醒，返回 false 表示已被其他请求标记；按数据库中的周期开始时间匹配
+func MarkBudgetSoftNotified(budget *Budget) bool {
+	result := DB.Model(&Budget{}).Where("id = ? and period_start = ? and soft_notified = ?", budget.Id, budget.StoredPeriodStart, false).
+		Update("soft_notified", true)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false
+	}
+	if err := cacheSetBudgetSoftNotified(budget.Id); err != nil {
+		common.SysError("failed to update budget cache: " + err.Error())
+	}
+	return true
+}
@@ -0,0 +1,124 @@
+package model
+
+import (
+	"fmt"
+	"one-api/common"
+	"strconv"
+	"time"
+)
+
+// 预算以哈希形式逐个缓存，消费时原子累加 Used；用户、令牌、分组到预算 id 的索引单独缓存，没有预算时缓存空列表
+
+func getBudgetCacheKey(id int) string {
+	return fmt.Sprintf("budget:%d", id)
+}
+
+func getBudgetIndexCacheKey(scope string, key string) string {
+	return fmt.Sprintf("budgets:%s:%s", scope, key)
+}
+
+// budgetIndexKey 预算在索引中的键，用户预算按用户、令牌预算按令牌、分组预算按分组
+func budgetIndexKey(budget *Budget) string {
+	switch budget.Scope {
+	case BudgetScopeToken:
+		return strconv.Itoa(budget.TokenId)
+	case BudgetScopeGroup:
+		return budget.Group
+	default:
+		return strconv.FormatInt(budget.UserId, 10)
+	}
+}
+
+func cacheSetBudget(budget Budget) error {
+	if !common.RedisEnabled {
+		return nil
+	}
+	return common.RedisHSetObj(getBudgetCacheKey(budget.Id), &budget, time.Duration(common.RedisKeyCacheSeconds())*time.Second)
+}
+
+func cacheGetBudget(id int) (*Budget, error) {
+	var budget Budget
+	if err := common.RedisHGetObj(getBudgetCacheKey(id), &budget); err != nil {
+		return nil, err
+	}
+	return &budget, nil
+}
+
+func cacheIncrBudgetUsed(id int, delta int64) error {
+	if !common.RedisEnabled {
+		return nil
+	}
+	return common.RedisHIncrBy(getBudgetCacheKey(id), "Used", delta)
+}
+
+func cacheSetBudgetSoftNotified(id int) error {
+	if !common.RedisEnabled {
+		return nil
+	}
+	return common.RedisHSetField(getBudgetCacheKey(id), "SoftNotified", strconv.FormatBool(true))
+}
+
+func cacheDeleteBudget(id int) error {
+	if !common.RedisEnabled {
+		return nil
+	}
+	return common.RedisDelKey(getBudgetCacheKey(id))
+}
+
+// invalidateBudgetCache 预算新增、修改或删除后清除预算及其所在索引的缓存
+func invalidateBudgetCache(budget *Budget) {
+	if !common.RedisEnabled {
+		return
+	}
+	if err := common.RedisDelKey(getBudgetIndexCacheKey(budget.Scope, budgetIndexKey(budget))); err != nil {
+		common.SysError("failed to invalidate budget index cache: " + err.Error())
+	}
+	if err := cacheDeleteBudget(budget.Id); err != nil {
+		common.SysError("failed to invalidate budget cache: " + err.Error())
+	}
+}
+
+// cacheGetScopeBudgets 从缓存获取某个用户、令牌或分组的预算，缓存不存在时从数据库加载并写入缓存
+func cacheGetScopeBudgets(scope string, key string, query string, arg interface{}) ([]*Budget, error) {
+	indexKey := getBudgetIndexCacheKey(scope, key)
+	if data, err := common.RedisGet(indexKey); err == nil {
+		var ids []int
+		if err = common.Unmarshal([]byte(data), &ids); err == nil {
+			budgets := make([]*Budget, 0, len(ids))
+			for _, id := range ids {
+				budget, err := cacheGetBudget(id)
+				if err != nil {
+					budget = &Budget{}
+					if err = DB.Where("id = ?", id).Limit(1).Find(budget).Error; err != nil {
+						return nil, err
+					}
+					// 已被删除的预算等待索引过期
+					if budget.Id == 0 {
+						continue
+					}
+					if err = cacheSetBudget(*budget); err != nil {
+						common.SysError("failed to update budget cache: " + err.Error())
+					}
+				}
+				budgets = append(budgets, budget)
+			}
+			return budgets, nil
+		}
+	}
+	var budgets []*Budget
+	if err := DB.Where("scope = ? and "+query, scope, arg).Find(&budgets).Error; err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(budgets))
+	for _, budget := range budgets {
+		ids = append(ids, budget.Id)
+		if err := cacheSetBudget(*budget); err != nil {
+			common.SysError("failed to update budget cache: " + err.Error())
+		}
+	}
+	data, _ := common.Marshal(ids)
+	if err := common.RedisSet(indexKey, string(data), time.Duration(common.RedisKeyCacheSeconds())*time.Second); err != nil {
+		common.SysError("failed to update budget index cache: " + err.Error())
+	}
+	return budgets, nil
+}
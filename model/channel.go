@@ -12,6 +12,7 @@ import (
 	"one-api/types"
 	"strings"
 	"sync"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -36,14 +37,15 @@ type Channel struct {
 	Group              string  `json:"group" gorm:"type:varchar(64);default:'default'"`
 	UsedQuota          int64   `json:"used_quota" gorm:"bigint;default:0"`
 	ModelMapping       *string `json:"model_mapping" gorm:"type:text"`
-	//MaxInputTokens     *int    `json:"max_input_tokens" gorm:"default:0"`
-	StatusCodeMapping *string `json:"status_code_mapping" gorm:"type:varchar(1024);default:''"`
-	Priority          *int64  `json:"priority" gorm:"bigint;default:0"`
-	AutoBan           *int    `json:"auto_ban" gorm:"default:1"`
-	OtherInfo         string  `json:"other_info"`
-	Tag               *string `json:"tag" gorm:"index"`
-	Setting           *string `json:"setting" gorm:"type:text"` // 渠道额外设置
-	ParamOverride     *string `json:"param_override" gorm:"type:text"`
+	MaxInputTokens     *int    `json:"max_input_tokens" gorm:"default:0"` // 渠道默认的最大输入 tokens，0 表示不限制
+	ContextWindows     *string `json:"context_windows" gorm:"type:text"`  // 按模型设置的最大输入 tokens，JSON 对象
+	StatusCodeMapping  *string `json:"status_code_mapping" gorm:"type:varchar(1024);default:''"`
+	Priority           *int64  `json:"priority" gorm:"bigint;default:0"`
+	AutoBan            *int    `json:"auto_ban" gorm:"default:1"`
+	OtherInfo          string  `json:"other_info"`
+	Tag                *string `json:"tag" gorm:"index"`
+	Setting            *string `json:"setting" gorm:"type:text"` // 渠道额外设置
+	ParamOverride      *string `json:"param_override" gorm:"type:text"`
 	// add after v0.8.5
 	ChannelInfo ChannelInfo `json:"channel_info" gorm:"type:json"`
 }
@@ -54,6 +56,11 @@ type ChannelInfo struct {
 	MultiKeyStatusList   map[int]int           `json:"multi_key_status_list"`   // key状态列表，key index -> status
 	MultiKeyPollingIndex int                   `json:"multi_key_polling_index"` // 多Key模式下轮询的key索引
 	MultiKeyMode         constant.MultiKeyMode `json:"multi_key_mode"`
+	// token_bucket 模式下每个 Key 每分钟的请求数和 tokens 上限，0 表示不限制
+	MultiKeyRPM int `json:"multi_key_rpm,omitempty"`
+	MultiKeyTPM int `json:"multi_key_tpm,omitempty"`
+	// Key 遇到 429 后的冷却时间，0 使用默认值
+	MultiKeyCooldownSeconds int `json:"multi_key_cooldown_seconds,omitempty"`
 }
 
 // Value implements driver.Valuer interface
@@ -88,6 +95,36 @@ func (channel *Channel) getKeys() []string {
 	return keys
 }
 
+// GetKeyByIndex 获取指定下标的 key，非多 key 模式下直接返回原始 key
+func (channel *Channel) GetKeyByIndex(index int) (string, error) {
+	if !channel.ChannelInfo.IsMultiKey {
+		return channel.Key, nil
+	}
+	keys := channel.getKeys()
+	if index < 0 || index >= len(keys) {
+		return "", fmt.Errorf("key index %d out of range", index)
+	}
+	return keys[index], nil
+}
+
+// GetEnabledKeyByIndex 获取指定索引的 Key，索引无效、Key 已禁用或熔断中时返回 false
+func (channel *Channel) GetEnabledKeyByIndex(index int) (string, bool) {
+	if !channel.ChannelInfo.IsMultiKey {
+		return "", false
+	}
+	keys := channel.getKeys()
+	if index < 0 || index >= len(keys) {
+		return "", false
+	}
+	if channel.getKeyStatus(index, time.Now().Unix()) != common.ChannelStatusEnabled {
+		return "", false
+	}
+	if !IsChannelKeyBreakerAvailable(channel.Id, index) || !takeChannelKey(channel, index) {
+		return "", false
+	}
+	return keys[index], true
+}
+
 func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 	// If not in multi-key mode, return the original key string directly.
 	if !channel.ChannelInfo.IsMultiKey {
@@ -102,14 +139,16 @@ func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 	}
 
 	statusList := channel.ChannelInfo.MultiKeyStatusList
+	cooldowns := getChannelKeyCooldowns(channel.Id, time.Now().Unix())
 	// helper to get key status, default to enabled when missing
 	getStatus := func(idx int) int {
-		if statusList == nil {
-			return common.ChannelStatusEnabled
-		}
 		if status, ok := statusList[idx]; ok {
 			return status
 		}
+		// 冷却中的 Key 暂不使用
+		if _, ok := cooldowns[idx]; ok {
+			return common.ChannelStatusCoolingDown
+		}
 		return common.ChannelStatusEnabled
 	}
 
@@ -122,8 +161,29 @@ func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 	}
 	// If no specific status list or none enabled, fall back to first key
 	if len(enabledIdx) == 0 {
+		// 全部 Key 都在冷却时使用最早结束冷却的 Key
+		if idx, ok := channel.earliestCooldownKey(cooldowns); ok && idx < len(keys) {
+			return keys[idx], idx, nil
+		}
 		return keys[0], 0, nil
 	}
+	// 跳过熔断中的 Key，全部熔断时忽略熔断状态
+	availableIdx := make([]int, 0, len(enabledIdx))
+	for _, idx := range enabledIdx {
+		if IsChannelKeyBreakerAvailable(channel.Id, idx) {
+			availableIdx = append(availableIdx, idx)
+		}
+	}
+	if len(availableIdx) > 0 && len(availableIdx) < len(enabledIdx) {
+		enabledIdx = availableIdx
+		statusList = make(map[int]int, len(keys))
+		for i := range keys {
+			statusList[i] = common.ChannelStatusAutoDisabled
+		}
+		for _, idx := range enabledIdx {
+			statusList[idx] = common.ChannelStatusEnabled
+		}
+	}
 
 	switch channel.ChannelInfo.MultiKeyMode {
 	case constant.MultiKeyModeRandom:
@@ -166,6 +226,9 @@ func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 		}
 		// Fallback – should not happen, but return first enabled key
 		return keys[enabledIdx[0]], enabledIdx[0], nil
+	case constant.MultiKeyModeLeastRecentlyUsed, constant.MultiKeyModeLeastInflight, constant.MultiKeyModeTokenBucket:
+		selectedIdx := selectChannelKeyByUsage(channel, enabledIdx)
+		return keys[selectedIdx], selectedIdx, nil
 	default:
 		// Unknown mode, default to first enabled key (or original key string)
 		return keys[enabledIdx[0]], enabledIdx[0], nil
@@ -390,6 +453,22 @@ func (channel *Channel) GetModelMapping() string {
 	return *channel.ModelMapping
 }
 
+// GetContextWindow 获取渠道对模型的最大输入 tokens，未设置模型时使用渠道默认值，0 表示不限制
+func (channel *Channel) GetContextWindow(modelName string) int {
+	if channel.ContextWindows != nil && *channel.ContextWindows != "" {
+		windows := make(map[string]int)
+		if err := json.Unmarshal([]byte(*channel.ContextWindows), &windows); err == nil {
+			if window, ok := windows[modelName]; ok {
+				return window
+			}
+		}
+	}
+	if channel.MaxInputTokens != nil {
+		return *channel.MaxInputTokens
+	}
+	return 0
+}
+
 func (channel *Channel) GetStatusCodeMapping() string {
 	if channel.StatusCodeMapping == nil {
 		return ""
@@ -542,6 +621,7 @@ func handlerMultiKeyUpdate(channel *Channel, usingKey string, status int) {
 		} else {
 			channel.ChannelInfo.MultiKeyStatusList[keyIndex] = status
 		}
+		clearChannelKeyCooldown(channel.Id, keyIndex)
 		if len(channel.ChannelInfo.MultiKeyStatusList) >= channel.ChannelInfo.MultiKeySize {
 			channel.Status = common.ChannelStatusAutoDisabled
 			info := channel.GetOtherInfo()
@@ -0,0 +1,218 @@
+package model
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/setting/operation_setting"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	BreakerStateClosed   = "closed"
+	BreakerStateOpen     = "open"
+	BreakerStateHalfOpen = "half_open"
+)
+
+// ChannelBreaker 渠道或多 Key 渠道单个 Key 的熔断状态，KeyIndex 为 -1 表示渠道级
+type ChannelBreaker struct {
+	ChannelId           int    `json:"channel_id"`
+	KeyIndex            int    `json:"key_index"`
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	HalfOpenSuccesses   int    `json:"half_open_successes"`
+	Probing             int    `json:"probing"`    // 半开状态下进行中的探测请求数
+	OpenCount           int    `json:"open_count"` // 连续熔断次数，用于冷却时间退避
+	OpenedAt            int64  `json:"opened_at"`
+	CooldownSeconds     int64  `json:"cooldown_seconds"`
+	LastProbeAt         int64  `json:"last_probe_at"`
+	LastError           string `json:"last_error"`
+}
+
+var channelBreakers = make(map[string]*ChannelBreaker)
+var channelBreakerLock sync.Mutex
+
+func channelBreakerKey(channelId int, keyIndex int) string {
+	return fmt.Sprintf("%d:%d", channelId, keyIndex)
+}
+
+// available 熔断器是否放行请求，open 状态冷却结束后视为可探测
+func (b *ChannelBreaker) available(now int64) bool {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	switch b.State {
+	case BreakerStateOpen:
+		return now-b.OpenedAt >= b.CooldownSeconds
+	case BreakerStateHalfOpen:
+		// 探测请求长时间没有结果时不再占用名额
+		return b.Probing < setting.HalfOpenMaxRequests || now-b.LastProbeAt >= b.CooldownSeconds
+	default:
+		return true
+	}
+}
+
+func (b *ChannelBreaker) open(now int64, reason string) {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	b.OpenCount++
+	cooldown := setting.CooldownSeconds
+	for i := 1; i < b.OpenCount && cooldown < setting.MaxCooldownSeconds; i++ {
+		cooldown *= 2
+	}
+	if setting.MaxCooldownSeconds > 0 && cooldown > setting.MaxCooldownSeconds {
+		cooldown = setting.MaxCooldownSeconds
+	}
+	b.State = BreakerStateOpen
+	b.OpenedAt = now
+	b.CooldownSeconds = cooldown
+	b.HalfOpenSuccesses = 0
+	b.Probing = 0
+	b.LastError = reason
+}
+
+func isChannelBreakerAvailable(channelId int, keyIndex int) bool {
+	if !operation_setting.GetCircuitBreakerSetting().Enabled {
+		return true
+	}
+	channelBreakerLock.Lock()
+	defer channelBreakerLock.Unlock()
+	breaker, ok := channelBreakers[channelBreakerKey(channelId, keyIndex)]
+	return !ok || breaker.available(time.Now().Unix())
+}
+
+// IsChannelBreakerAvailable 渠道是否未被熔断
+func IsChannelBreakerAvailable(channelId int) bool {
+	return isChannelBreakerAvailable(channelId, -1)
+}
+
+// IsChannelKeyBreakerAvailable 多 Key 渠道的某个 Key 是否未被熔断
+func IsChannelKeyBreakerAvailable(channelId int, keyIndex int) bool {
+	return isChannelBreakerAvailable(channelId, keyIndex)
+}
+
+// AcquireChannelBreaker 向上游发起请求时调用，冷却结束的熔断器进入半开状态并占用一个探测名额
+func AcquireChannelBreaker(channelId int, keyIndex int) {
+	if !operation_setting.GetCircuitBreakerSetting().Enabled {
+		return
+	}
+	channelBreakerLock.Lock()
+	defer channelBreakerLock.Unlock()
+	breaker, ok := channelBreakers[channelBreakerKey(channelId, keyIndex)]
+	if !ok || breaker.State == BreakerStateClosed {
+		return
+	}
+	now := time.Now().Unix()
+	if !breaker.available(now) {
+		return
+	}
+	if breaker.State == BreakerStateOpen || now-breaker.LastProbeAt >= breaker.CooldownSeconds {
+		breaker.Probing = 0
+	}
+	breaker.State = BreakerStateHalfOpen
+	breaker.Probing++
+	breaker.LastProbeAt = now
+}
+
+func recordChannelBreaker(channelId int, keyIndex int, success bool, reason string, now int64) {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	key := channelBreakerKey(channelId, keyIndex)
+	breaker, ok := channelBreakers[key]
+	if !ok {
+		if success {
+			return
+		}
+		breaker = &ChannelBreaker{ChannelId: channelId, KeyIndex: keyIndex, State: BreakerStateClosed}
+		channelBreakers[key] = breaker
+	}
+	switch breaker.State {
+	case BreakerStateClosed:
+		if success {
+			// 正常状态下成功即清除记录
+			delete(channelBreakers, key)
+			return
+		}
+		breaker.ConsecutiveFailures++
+		breaker.LastError = reason
+		if breaker.ConsecutiveFailures >= setting.FailureThreshold {
+			breaker.open(now, reason)
+		}
+	case BreakerStateHalfOpen:
+		if breaker.Probing > 0 {
+			breaker.Probing--
+		}
+		if !success {
+			breaker.open(now, reason)
+			return
+		}
+		breaker.HalfOpenSuccesses++
+		if breaker.HalfOpenSuccesses >= setting.HalfOpenSuccessThreshold {
+			delete(channelBreakers, key)
+		}
+	}
+	// open 状态下收到的是熔断前发出的请求结果，忽略
+}
+
+// RecordChannelBreakerResult 记录请求结果，keyIndex >= 0 时同时记录该 Key 的熔断状态
+func RecordChannelBreakerResult(channelId int, keyIndex int, success bool, reason string) {
+	if channelId <= 0 || !operation_setting.GetCircuitBreakerSetting().Enabled {
+		return
+	}
+	now := time.Now().Unix()
+	channelBreakerLock.Lock()
+	defer channelBreakerLock.Unlock()
+	recordChannelBreaker(channelId, -1, success, reason, now)
+	if keyIndex >= 0 {
+		recordChannelBreaker(channelId, keyIndex, success, reason, now)
+	}
+}
+
+// GetChannelBreakers 获取所有未恢复的熔断器
+func GetChannelBreakers() []ChannelBreaker {
+	channelBreakerLock.Lock()
+	defer channelBreakerLock.Unlock()
+	breakers := make([]ChannelBreaker, 0, len(channelBreakers))
+	for _, breaker := range channelBreakers {
+		breakers = append(breakers, *breaker)
+	}
+	sort.Slice(breakers, func(i, j int) bool {
+		if breakers[i].ChannelId != breakers[j].ChannelId {
+			return breakers[i].ChannelId < breakers[j].ChannelId
+		}
+		return breakers[i].KeyIndex < breakers[j].KeyIndex
+	})
+	return breakers
+}
+
+// ResetChannelBreakers 手动恢复渠道及其所有 Key 的熔断状态
+func ResetChannelBreakers(channelId int) {
+	channelBreakerLock.Lock()
+	defer channelBreakerLock.Unlock()
+	for key, breaker := range channelBreakers {
+		if breaker.ChannelId == channelId {
+			delete(channelBreakers, key)
+		}
+	}
+}
+
+// GetChannelKeyIndex 根据 Key 查找多 Key 渠道中的索引，非多 Key 渠道返回 -1
+func GetChannelKeyIndex(channelId int, usingKey string) int {
+	if usingKey == "" {
+		return -1
+	}
+	var channel *Channel
+	if common.MemoryCacheEnabled {
+		channelSyncLock.RLock()
+		channel = channelsIDM[channelId]
+		channelSyncLock.RUnlock()
+	} else {
+		channel, _ = GetChannelById(channelId, true)
+	}
+	if channel == nil || !channel.ChannelInfo.IsMultiKey {
+		return -1
+	}
+	for i, key := range channel.getKeys() {
+		if key == usingKey {
+			return i
+		}
+	}
+	return -1
+}
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"math/rand"
 	"one-api/common"
+	"one-api/constant"
 	"one-api/setting"
+	"one-api/setting/operation_setting"
 	"sort"
 	"strings"
 	"sync"
@@ -79,10 +81,24 @@ func SyncChannelCache(frequency int) {
 	}
 }
 
+// ChannelFilter 渠道筛选条件，返回 false 的渠道不参与本次选择
+type ChannelFilter func(channel *Channel) bool
+
+func getChannelFilter(c *gin.Context) ChannelFilter {
+	if value, ok := common.GetContextKey(c, constant.ContextKeyChannelFilter); ok {
+		if filter, ok := value.(ChannelFilter); ok {
+			return filter
+		}
+	}
+	return nil
+}
+
 func CacheGetRandomSatisfiedChannel(c *gin.Context, group string, model string, retry int) (*Channel, string, error) {
 	var channel *Channel
 	var err error
 	selectGroup := group
+	windowFilter := &contextWindowFilter{modelName: model, promptTokens: c.GetInt("prompt_tokens")}
+	filter := windowFilter.wrap(withBreakerFilter(getChannelFilter(c)))
 	if group == "auto" {
 		if len(setting.AutoGroups) == 0 {
 			return nil, selectGroup, errors.New("auto groups is not enabled")
@@ -91,7 +107,7 @@ func CacheGetRandomSatisfiedChannel(c *gin.Context, group string, model string,
 			if common.DebugEnabled {
 				println("autoGroup:", autoGroup)
 			}
-			channel, _ = getRandomSatisfiedChannel(autoGroup, model, retry)
+			channel, _ = getRandomUnsaturatedChannel(autoGroup, model, retry, filter)
 			if channel == nil {
 				continue
 			} else {
@@ -104,18 +120,170 @@ func CacheGetRandomSatisfiedChannel(c *gin.Context, group string, model string,
 			}
 		}
 	} else {
-		channel, err = getRandomSatisfiedChannel(group, model, retry)
+		channel, err = getRandomUnsaturatedChannel(group, model, retry, filter)
 		if err != nil {
+			if windowErr := windowFilter.err(); windowErr != nil {
+				return nil, group, windowErr
+			}
 			return nil, group, err
 		}
 	}
 	if channel == nil {
+		if windowErr := windowFilter.err(); windowErr != nil {
+			return nil, group, windowErr
+		}
 		return nil, group, errors.New("channel not found")
 	}
 	return channel, selectGroup, nil
 }
 
-func getRandomSatisfiedChannel(group string, model string, retry int) (*Channel, error) {
+// CacheGetChannelForGroupModel 检查指定渠道能否为分组下的模型提供服务并满足本次请求的筛选条件，用于会话粘性等固定渠道的场景
+func CacheGetChannelForGroupModel(c *gin.Context, group string, model string, channelId int) (*Channel, string, bool) {
+	channel, err := CacheGetChannel(channelId)
+	if err != nil || channel.Status != common.ChannelStatusEnabled {
+		return nil, group, false
+	}
+	windowFilter := &contextWindowFilter{modelName: model, promptTokens: c.GetInt("prompt_tokens")}
+	filter := windowFilter.wrap(withBreakerFilter(getChannelFilter(c)))
+	if filter != nil && !filter(channel) {
+		return nil, group, false
+	}
+	groups := []string{group}
+	if group == "auto" {
+		groups = setting.AutoGroups
+	}
+	for _, g := range groups {
+		if !channelHasAbility(g, model, channelId) {
+			continue
+		}
+		if group == "auto" {
+			c.Set("auto_group", g)
+		}
+		return channel, g, true
+	}
+	return nil, group, false
+}
+
+func channelHasAbility(group string, model string, channelId int) bool {
+	if strings.HasPrefix(model, "gpt-4-gizmo") {
+		model = "gpt-4-gizmo-*"
+	}
+	if strings.HasPrefix(model, "gpt-4o-gizmo") {
+		model = "gpt-4o-gizmo-*"
+	}
+	if !common.MemoryCacheEnabled {
+		var count int64
+		DB.Model(&Ability{}).Where(commonGroupCol+" = ? and model = ? and channel_id = ? and enabled = ?", group, model, channelId, true).Count(&count)
+		return count > 0
+	}
+	channelSyncLock.RLock()
+	defer channelSyncLock.RUnlock()
+	for _, id := range group2model2channels[group][model] {
+		if id == channelId {
+			return true
+		}
+	}
+	return false
+}
+
+// getRandomUnsaturatedChannel 优先选择并发未满的渠道，全部已满时仍按原条件选择，由请求排队等待名额
+func getRandomUnsaturatedChannel(group string, model string, retry int, filter ChannelFilter) (*Channel, error) {
+	available := unsaturatedChannelFilter(group, model)
+	channel, err := getRandomSatisfiedChannel(group, model, retry, func(channel *Channel) bool {
+		if !available(channel) {
+			return false
+		}
+		return filter == nil || filter(channel)
+	})
+	if channel != nil {
+		return channel, err
+	}
+	return getRandomSatisfiedChannel(group, model, retry, filter)
+}
+
+// unsaturatedChannelFilter 判断渠道并发是否未满的筛选条件，开启内存缓存时一次性查询所有候选渠道的并发数
+func unsaturatedChannelFilter(group string, model string) ChannelFilter {
+	if !common.MemoryCacheEnabled {
+		return IsChannelConcurrencyAvailable
+	}
+	if strings.HasPrefix(model, "gpt-4-gizmo") {
+		model = "gpt-4-gizmo-*"
+	}
+	if strings.HasPrefix(model, "gpt-4o-gizmo") {
+		model = "gpt-4o-gizmo-*"
+	}
+	channelSyncLock.RLock()
+	channelIds := group2model2channels[group][model]
+	channels := make([]*Channel, 0, len(channelIds))
+	for _, channelId := range channelIds {
+		if channel, ok := channelsIDM[channelId]; ok {
+			channels = append(channels, channel)
+		}
+	}
+	channelSyncLock.RUnlock()
+	saturated := getSaturatedChannels(channels)
+	return func(channel *Channel) bool {
+		return !saturated[channel.Id]
+	}
+}
+
+// ContextWindowError 候选渠道都因上下文窗口不足被跳过
+type ContextWindowError struct {
+	PromptTokens int
+	MaxWindow    int
+}
+
+func (e *ContextWindowError) Error() string {
+	return fmt.Sprintf("prompt tokens %d exceed the maximum context window %d of available channels", e.PromptTokens, e.MaxWindow)
+}
+
+// contextWindowFilter 已计算提示 tokens 时跳过上下文窗口不足的渠道，并记录被跳过渠道中最大的窗口
+type contextWindowFilter struct {
+	modelName    string
+	promptTokens int
+	maxWindow    int
+}
+
+func (f *contextWindowFilter) wrap(filter ChannelFilter) ChannelFilter {
+	if f.promptTokens <= 0 {
+		return filter
+	}
+	return func(channel *Channel) bool {
+		if filter != nil && !filter(channel) {
+			return false
+		}
+		if window := channel.GetContextWindow(f.modelName); window > 0 && f.promptTokens > window {
+			if window > f.maxWindow {
+				f.maxWindow = window
+			}
+			return false
+		}
+		return true
+	}
+}
+
+// err 没有选到渠道时调用，有渠道仅因上下文窗口不足被跳过时返回 ContextWindowError
+func (f *contextWindowFilter) err() error {
+	if f.maxWindow == 0 {
+		return nil
+	}
+	return &ContextWindowError{PromptTokens: f.promptTokens, MaxWindow: f.maxWindow}
+}
+
+// withBreakerFilter 在筛选条件上叠加熔断判断，熔断中的渠道不参与选择
+func withBreakerFilter(filter ChannelFilter) ChannelFilter {
+	if !operation_setting.GetCircuitBreakerSetting().Enabled {
+		return filter
+	}
+	return func(channel *Channel) bool {
+		if !IsChannelBreakerAvailable(channel.Id) {
+			return false
+		}
+		return filter == nil || filter(channel)
+	}
+}
+
+func getRandomSatisfiedChannel(group string, model string, retry int, filter ChannelFilter) (*Channel, error) {
 	if strings.HasPrefix(model, "gpt-4-gizmo") {
 		model = "gpt-4-gizmo-*"
 	}
@@ -125,31 +293,43 @@ func getRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 
 	// if memory cache is disabled, get channel directly from database
 	if !common.MemoryCacheEnabled {
-		return GetRandomSatisfiedChannel(group, model, retry)
+		return GetRandomSatisfiedChannel(group, model, retry, filter)
 	}
 
+	// 只在锁内取出候选渠道，筛选条件和按统计选择可能访问 Redis，在锁外执行
 	channelSyncLock.RLock()
-	defer channelSyncLock.RUnlock()
-	channels := group2model2channels[group][model]
+	channelIds := group2model2channels[group][model]
+	channels := make([]*Channel, 0, len(channelIds))
+	for _, channelId := range channelIds {
+		channel, ok := channelsIDM[channelId]
+		if !ok {
+			channelSyncLock.RUnlock()
+			return nil, fmt.Errorf("数据库一致性错误，渠道# %d 不存在，请联系管理员修复", channelId)
+		}
+		channels = append(channels, channel)
+	}
+	channelSyncLock.RUnlock()
+	if filter != nil {
+		filtered := make([]*Channel, 0, len(channels))
+		for _, channel := range channels {
+			if filter(channel) {
+				filtered = append(filtered, channel)
+			}
+		}
+		channels = filtered
+	}
 
 	if len(channels) == 0 {
 		return nil, errors.New("channel not found")
 	}
 
 	if len(channels) == 1 {
-		if channel, ok := channelsIDM[channels[0]]; ok {
-			return channel, nil
-		}
-		return nil, fmt.Errorf("数据库一致性错误，渠道# %d 不存在，请联系管理员修复", channels[0])
+		return channels[0], nil
 	}
 
 	uniquePriorities := make(map[int]bool)
-	for _, channelId := range channels {
-		if channel, ok := channelsIDM[channelId]; ok {
-			uniquePriorities[int(channel.GetPriority())] = true
-		} else {
-			return nil, fmt.Errorf("数据库一致性错误，渠道# %d 不存在，请联系管理员修复", channelId)
-		}
+	for _, channel := range channels {
+		uniquePriorities[int(channel.GetPriority())] = true
 	}
 	var sortedUniquePriorities []int
 	for priority := range uniquePriorities {
@@ -164,14 +344,20 @@ func getRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 
 	// get the priority for the given retry number
 	var targetChannels []*Channel
-	for _, channelId := range channels {
-		if channel, ok := channelsIDM[channelId]; ok {
-			if channel.GetPriority() == targetPriority {
-				targetChannels = append(targetChannels, channel)
-			}
-		} else {
-			return nil, fmt.Errorf("数据库一致性错误，渠道# %d 不存在，请联系管理员修复", channelId)
+	for _, channel := range channels {
+		if channel.GetPriority() == targetPriority {
+			targetChannels = append(targetChannels, channel)
+		}
+	}
+
+	if strategy := operation_setting.GetGroupChannelSelectStrategy(group); strategy != operation_setting.ChannelSelectWeightedRandom {
+		candidates := make([]channelCandidate, 0, len(targetChannels))
+		targetChannelMap := make(map[int]*Channel, len(targetChannels))
+		for _, channel := range targetChannels {
+			candidates = append(candidates, channelCandidate{Id: channel.Id, Weight: channel.GetWeight()})
+			targetChannelMap[channel.Id] = channel
 		}
+		return targetChannelMap[selectChannelByStats(strategy, model, candidates)], nil
 	}
 
 	// 平滑系数
@@ -0,0 +1,150 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"one-api/common"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/go-redis/redis/v8"
+)
+
+// 多节点共享并发名额时，节点异常退出未释放的名额在该时间后失效
+const channelConcurrencyLeaseSeconds = 15 * 60
+
+// 清理过期名额后，名额未满时占用一个
+var channelConcurrencyAcquireScript = redis.NewScript(`
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[1] - ARGV[3])
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[2]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], ARGV[1], ARGV[4])
+redis.call('EXPIRE', KEYS[1], ARGV[3])
+return 1
+`)
+
+var channelConcurrency = make(map[int]int)
+var channelConcurrencyLock sync.Mutex
+
+func channelConcurrencyKey(channelId int) string {
+	return fmt.Sprintf("channel_concurrency:%d", channelId)
+}
+
+// TryAcquireChannelConcurrency 渠道进行中的请求数未达到上限时占用一个名额，返回用于释放的标识
+func TryAcquireChannelConcurrency(channelId int, limit int) (string, bool) {
+	if common.RedisEnabled {
+		member := common.GetUUID()
+		now := time.Now().Unix()
+		result, err := channelConcurrencyAcquireScript.Run(context.Background(), common.RDB,
+			[]string{channelConcurrencyKey(channelId)}, now, limit, channelConcurrencyLeaseSeconds, member).Int()
+		if err != nil {
+			// Redis 不可用时不限制并发
+			common.SysError("failed to acquire channel concurrency: " + err.Error())
+			return "", true
+		}
+		return member, result == 1
+	}
+	channelConcurrencyLock.Lock()
+	defer channelConcurrencyLock.Unlock()
+	if channelConcurrency[channelId] >= limit {
+		return "", false
+	}
+	channelConcurrency[channelId]++
+	return "", true
+}
+
+// ReleaseChannelConcurrency 释放 TryAcquireChannelConcurrency 占用的名额
+func ReleaseChannelConcurrency(channelId int, member string) {
+	if common.RedisEnabled {
+		if member == "" {
+			return
+		}
+		if err := common.RDB.ZRem(context.Background(), channelConcurrencyKey(channelId), member).Err(); err != nil {
+			common.SysError("failed to release channel concurrency: " + err.Error())
+		}
+		return
+	}
+	channelConcurrencyLock.Lock()
+	defer channelConcurrencyLock.Unlock()
+	if channelConcurrency[channelId] > 0 {
+		channelConcurrency[channelId]--
+	}
+}
+
+// KeepChannelConcurrencyLease 请求进行中定期续期占用的名额，避免超过租约时间的长请求名额失效被其他请求占用，返回停止续期的函数
+func KeepChannelConcurrencyLease(channelId int, member string) func() {
+	if !common.RedisEnabled || member == "" {
+		return func() {}
+	}
+	done := make(chan struct{})
+	gopool.Go(func() {
+		ticker := time.NewTicker(channelConcurrencyLeaseSeconds / 3 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				key := channelConcurrencyKey(channelId)
+				pipe := common.RDB.Pipeline()
+				pipe.ZAddXX(context.Background(), key, &redis.Z{Score: float64(time.Now().Unix()), Member: member})
+				pipe.Expire(context.Background(), key, channelConcurrencyLeaseSeconds*time.Second)
+				if _, err := pipe.Exec(context.Background()); err != nil {
+					common.SysError("failed to refresh channel concurrency lease: " + err.Error())
+				}
+			}
+		}
+	})
+	return func() {
+		close(done)
+	}
+}
+
+// IsChannelConcurrencyAvailable 渠道进行中的请求数是否未达到设置的上限
+func IsChannelConcurrencyAvailable(channel *Channel) bool {
+	return !getSaturatedChannels([]*Channel{channel})[channel.Id]
+}
+
+// getSaturatedChannels 返回进行中的请求数已达到上限的渠道，Redis 中的计数通过一次 pipeline 查询，查询失败时视为未满
+func getSaturatedChannels(channels []*Channel) map[int]bool {
+	saturated := make(map[int]bool)
+	limits := make(map[int]int)
+	for _, channel := range channels {
+		if limit := channel.GetSetting().MaxConcurrency; limit > 0 {
+			limits[channel.Id] = limit
+		}
+	}
+	if len(limits) == 0 {
+		return saturated
+	}
+	if common.RedisEnabled {
+		since := strconv.FormatInt(time.Now().Unix()-channelConcurrencyLeaseSeconds, 10)
+		pipe := common.RDB.Pipeline()
+		counts := make(map[int]*redis.IntCmd, len(limits))
+		for channelId := range limits {
+			counts[channelId] = pipe.ZCount(context.Background(), channelConcurrencyKey(channelId), "("+since, "+inf")
+		}
+		if _, err := pipe.Exec(context.Background()); err != nil {
+			// Redis 不可用时不限制并发
+			common.SysError("failed to count channel concurrency, treating channels as unsaturated: " + err.Error())
+			return saturated
+		}
+		for channelId, count := range counts {
+			if count.Val() >= int64(limits[channelId]) {
+				saturated[channelId] = true
+			}
+		}
+		return saturated
+	}
+	channelConcurrencyLock.Lock()
+	defer channelConcurrencyLock.Unlock()
+	for channelId, limit := range limits {
+		if channelConcurrency[channelId] >= limit {
+			saturated[channelId] = true
+		}
+	}
+	return saturated
+}
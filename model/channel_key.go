@@ -0,0 +1,188 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"strings"
+	"time"
+)
+
+// ChannelKeyInfo 多 Key 渠道中单个 Key 的状态和统计，不包含 Key 原文
+type ChannelKeyInfo struct {
+	Index         int             `json:"index"`
+	Fingerprint   string          `json:"fingerprint"`
+	Status        int             `json:"status"`
+	CooldownUntil int64           `json:"cooldown_until,omitempty"`
+	Stats         ChannelKeyStats `json:"stats"`
+}
+
+// isJsonArrayKey Key 是否以 JSON 数组保存（如 Vertex AI）
+func (channel *Channel) isJsonArrayKey() bool {
+	return strings.HasPrefix(strings.TrimSpace(channel.Key), "[")
+}
+
+func (channel *Channel) setKeys(keys []string, jsonArray bool) {
+	if jsonArray {
+		channel.Key = "[" + strings.Join(keys, ",") + "]"
+	} else {
+		channel.Key = strings.Join(keys, "\n")
+	}
+	channel.ChannelInfo.MultiKeySize = len(keys)
+}
+
+func getMultiKeyChannel(channelId int) (*Channel, error) {
+	channel, err := GetChannelById(channelId, true)
+	if err != nil {
+		return nil, err
+	}
+	if !channel.ChannelInfo.IsMultiKey {
+		return nil, errors.New("渠道不是多 Key 渠道")
+	}
+	return channel, nil
+}
+
+// GetChannelKeyInfos 获取多 Key 渠道所有 Key 的状态和统计
+func GetChannelKeyInfos(channelId int) ([]ChannelKeyInfo, error) {
+	channel, err := getMultiKeyChannel(channelId)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	stats := GetChannelKeyStats(channelId)
+	keys := channel.getKeys()
+	infos := make([]ChannelKeyInfo, len(keys))
+	for i, key := range keys {
+		infos[i] = ChannelKeyInfo{
+			Index:       i,
+			Fingerprint: common.KeyFingerprint(key),
+			Status:      channel.getKeyStatus(i, now),
+			Stats:       stats[i],
+		}
+		if infos[i].Status == common.ChannelStatusCoolingDown {
+			infos[i].CooldownUntil = getChannelKeyCooldownUntil(channelId, i)
+		}
+	}
+	return infos, nil
+}
+
+// saveMultiKeyChannel 保存 Key 变更，渠道启用状态变化时同步更新 abilities
+func saveMultiKeyChannel(channel *Channel, beforeStatus int) error {
+	if len(channel.ChannelInfo.MultiKeyStatusList) >= channel.ChannelInfo.MultiKeySize {
+		if channel.Status == common.ChannelStatusEnabled {
+			channel.Status = common.ChannelStatusAutoDisabled
+			info := channel.GetOtherInfo()
+			info["status_reason"] = "All keys are disabled"
+			info["status_time"] = common.GetTimestamp()
+			channel.SetOtherInfo(info)
+		}
+	} else if channel.Status == common.ChannelStatusAutoDisabled {
+		channel.Status = common.ChannelStatusEnabled
+	}
+	err := DB.Model(channel).Select("key", "status", "other_info", "channel_info").Updates(channel).Error
+	if err != nil {
+		return err
+	}
+	if beforeStatus != channel.Status {
+		return UpdateAbilityStatus(channel.Id, channel.Status == common.ChannelStatusEnabled)
+	}
+	return nil
+}
+
+// SetChannelKeyStatus 手动启用或禁用多 Key 渠道中的单个 Key
+func SetChannelKeyStatus(channelId int, keyIndex int, enabled bool) error {
+	channel, err := getMultiKeyChannel(channelId)
+	if err != nil {
+		return err
+	}
+	if keyIndex < 0 || keyIndex >= len(channel.getKeys()) {
+		return fmt.Errorf("key index %d out of range", keyIndex)
+	}
+	beforeStatus := channel.Status
+	if channel.ChannelInfo.MultiKeyStatusList == nil {
+		channel.ChannelInfo.MultiKeyStatusList = make(map[int]int)
+	}
+	if enabled {
+		delete(channel.ChannelInfo.MultiKeyStatusList, keyIndex)
+	} else {
+		channel.ChannelInfo.MultiKeyStatusList[keyIndex] = common.ChannelStatusManuallyDisabled
+	}
+	clearChannelKeyCooldown(channelId, keyIndex)
+	return saveMultiKeyChannel(channel, beforeStatus)
+}
+
+// AppendChannelKeys 向多 Key 渠道末尾追加 Key，已有 Key 的索引不变
+func AppendChannelKeys(channelId int, newKeys []string) error {
+	channel, err := getMultiKeyChannel(channelId)
+	if err != nil {
+		return err
+	}
+	jsonArray := channel.isJsonArrayKey()
+	keys := channel.getKeys()
+	for _, key := range newKeys {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		if jsonArray && !json.Valid([]byte(key)) {
+			return errors.New("该渠道的 Key 为 JSON 格式，追加的 Key 也必须是合法的 JSON")
+		}
+		if !jsonArray && strings.Contains(key, "\n") {
+			return errors.New("Key 不能包含换行")
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == channel.ChannelInfo.MultiKeySize {
+		return errors.New("没有需要追加的 Key")
+	}
+	channel.setKeys(keys, jsonArray)
+	return saveMultiKeyChannel(channel, channel.Status)
+}
+
+// RemoveChannelKey 删除多 Key 渠道中的单个 Key，之后的 Key 索引依次前移
+func RemoveChannelKey(channelId int, keyIndex int) error {
+	channel, err := getMultiKeyChannel(channelId)
+	if err != nil {
+		return err
+	}
+	keys := channel.getKeys()
+	if keyIndex < 0 || keyIndex >= len(keys) {
+		return fmt.Errorf("key index %d out of range", keyIndex)
+	}
+	if len(keys) == 1 {
+		return errors.New("不能删除渠道的最后一个 Key")
+	}
+	info := &channel.ChannelInfo
+	shift := func(idx int) (int, bool) {
+		if idx == keyIndex {
+			return 0, false
+		}
+		if idx > keyIndex {
+			return idx - 1, true
+		}
+		return idx, true
+	}
+	statusList := make(map[int]int, len(info.MultiKeyStatusList))
+	for idx, status := range info.MultiKeyStatusList {
+		if newIdx, ok := shift(idx); ok {
+			statusList[newIdx] = status
+		}
+	}
+	info.MultiKeyStatusList = statusList
+	if info.MultiKeyPollingIndex > keyIndex {
+		info.MultiKeyPollingIndex--
+	}
+	beforeStatus := channel.Status
+	channel.setKeys(append(keys[:keyIndex], keys[keyIndex+1:]...), channel.isJsonArrayKey())
+	if info.MultiKeyPollingIndex >= info.MultiKeySize {
+		info.MultiKeyPollingIndex = 0
+	}
+	if err := saveMultiKeyChannel(channel, beforeStatus); err != nil {
+		return err
+	}
+	// 索引已变化，按索引记录的熔断、统计和冷却不再对应
+	ResetChannelBreakers(channelId)
+	resetChannelKeyUsages(channelId)
+	return nil
+}
@@ -0,0 +1,290 @@
+package model
+
+import (
+	"one-api/common"
+	"one-api/constant"
+	"sync"
+	"time"
+)
+
+// 多 Key 渠道中 Key 遇到 429 后默认的冷却时间
+const defaultChannelKeyCooldownSeconds = 60
+
+// ChannelKeyStats 多 Key 渠道中单个 Key 自进程启动以来的请求统计
+type ChannelKeyStats struct {
+	Requests    int64  `json:"requests"`
+	Errors      int64  `json:"errors"`
+	Tokens      int64  `json:"tokens"`
+	Inflight    int    `json:"inflight"`
+	LastUsedAt  int64  `json:"last_used_at"`
+	LastError   string `json:"last_error"`
+	LastErrorAt int64  `json:"last_error_at"`
+}
+
+// channelKeyUsage 多 Key 渠道中单个 Key 的使用情况，各节点分别统计
+type channelKeyUsage struct {
+	lastUsed   int64 // 上次被选中的时间，纳秒
+	inflight   int   // 进行中的请求数
+	rpmTokens  float64
+	tpmTokens  float64
+	refilledAt time.Time
+	stats      ChannelKeyStats
+	// 被上游限流后的冷却结束时间，unix 时间
+	cooldownUntil int64
+}
+
+type channelKeyUsageKey struct {
+	channelId int
+	keyIndex  int
+}
+
+var channelKeyUsages = make(map[channelKeyUsageKey]*channelKeyUsage)
+var channelKeyUsageLock sync.Mutex
+
+func getChannelKeyUsage(channelId int, keyIndex int) *channelKeyUsage {
+	key := channelKeyUsageKey{channelId: channelId, keyIndex: keyIndex}
+	usage, ok := channelKeyUsages[key]
+	if !ok {
+		usage = &channelKeyUsage{}
+		channelKeyUsages[key] = usage
+	}
+	return usage
+}
+
+// refill 按经过的时间补充令牌桶，首次使用时令牌桶为满
+func (u *channelKeyUsage) refill(info *ChannelInfo, now time.Time) {
+	if u.refilledAt.IsZero() {
+		u.rpmTokens = float64(info.MultiKeyRPM)
+		u.tpmTokens = float64(info.MultiKeyTPM)
+		u.refilledAt = now
+		return
+	}
+	minutes := now.Sub(u.refilledAt).Minutes()
+	if info.MultiKeyRPM > 0 {
+		u.rpmTokens = min(float64(info.MultiKeyRPM), u.rpmTokens+minutes*float64(info.MultiKeyRPM))
+	}
+	if info.MultiKeyTPM > 0 {
+		u.tpmTokens = min(float64(info.MultiKeyTPM), u.tpmTokens+minutes*float64(info.MultiKeyTPM))
+	}
+	u.refilledAt = now
+}
+
+// limited Key 当前是否已达到 RPM 或 TPM 上限
+func (u *channelKeyUsage) limited(info *ChannelInfo) bool {
+	if info.MultiKeyRPM > 0 && u.rpmTokens < 1 {
+		return true
+	}
+	return info.MultiKeyTPM > 0 && u.tpmTokens <= 0
+}
+
+func (u *channelKeyUsage) take(info *ChannelInfo, now time.Time) {
+	u.lastUsed = now.UnixNano()
+	if info.MultiKeyRPM > 0 {
+		u.rpmTokens--
+	}
+}
+
+// selectChannelKeyByUsage 按渠道的多 Key 模式从可用 Key 中选择一个，token_bucket 模式跳过已达到限额的 Key，全部达到限额时选择最久未使用的 Key
+func selectChannelKeyByUsage(channel *Channel, candidates []int) int {
+	info := &channel.ChannelInfo
+	now := time.Now()
+	channelKeyUsageLock.Lock()
+	defer channelKeyUsageLock.Unlock()
+	selected, fallback := -1, -1
+	var selectedUsage, fallbackUsage *channelKeyUsage
+	for _, idx := range candidates {
+		usage := getChannelKeyUsage(channel.Id, idx)
+		usage.refill(info, now)
+		if fallback < 0 || usage.lastUsed < fallbackUsage.lastUsed {
+			fallback, fallbackUsage = idx, usage
+		}
+		if info.MultiKeyMode == constant.MultiKeyModeTokenBucket && usage.limited(info) {
+			continue
+		}
+		if selected < 0 {
+			selected, selectedUsage = idx, usage
+			continue
+		}
+		if info.MultiKeyMode == constant.MultiKeyModeLeastInflight && usage.inflight != selectedUsage.inflight {
+			if usage.inflight < selectedUsage.inflight {
+				selected, selectedUsage = idx, usage
+			}
+			continue
+		}
+		if usage.lastUsed < selectedUsage.lastUsed {
+			selected, selectedUsage = idx, usage
+		}
+	}
+	if selected < 0 {
+		selected, selectedUsage = fallback, fallbackUsage
+	}
+	selectedUsage.take(info, now)
+	return selected
+}
+
+// takeChannelKey 直接使用指定的 Key 时记录使用情况，token_bucket 模式下 Key 已达到限额时返回 false
+func takeChannelKey(channel *Channel, keyIndex int) bool {
+	info := &channel.ChannelInfo
+	now := time.Now()
+	channelKeyUsageLock.Lock()
+	defer channelKeyUsageLock.Unlock()
+	usage := getChannelKeyUsage(channel.Id, keyIndex)
+	usage.refill(info, now)
+	if info.MultiKeyMode == constant.MultiKeyModeTokenBucket && usage.limited(info) {
+		return false
+	}
+	usage.take(info, now)
+	return true
+}
+
+// AcquireChannelKeyInflight 多 Key 渠道的 Key 开始处理请求
+func AcquireChannelKeyInflight(channelId int, keyIndex int) {
+	channelKeyUsageLock.Lock()
+	defer channelKeyUsageLock.Unlock()
+	usage := getChannelKeyUsage(channelId, keyIndex)
+	usage.inflight++
+	usage.stats.Requests++
+	usage.stats.LastUsedAt = time.Now().Unix()
+}
+
+// ReleaseChannelKeyInflight 多 Key 渠道的 Key 请求处理结束
+func ReleaseChannelKeyInflight(channelId int, keyIndex int) {
+	channelKeyUsageLock.Lock()
+	defer channelKeyUsageLock.Unlock()
+	usage := getChannelKeyUsage(channelId, keyIndex)
+	if usage.inflight > 0 {
+		usage.inflight--
+	}
+}
+
+// RecordChannelKeyTokens 请求完成后从 Key 的 TPM 令牌桶中扣除实际使用的 tokens
+func RecordChannelKeyTokens(channelId int, keyIndex int, tokens int) {
+	if keyIndex < 0 || tokens <= 0 {
+		return
+	}
+	channelKeyUsageLock.Lock()
+	defer channelKeyUsageLock.Unlock()
+	usage := getChannelKeyUsage(channelId, keyIndex)
+	usage.tpmTokens -= float64(tokens)
+	usage.stats.Tokens += int64(tokens)
+}
+
+// RecordChannelKeyError 记录 Key 的失败请求
+func RecordChannelKeyError(channelId int, keyIndex int, message string) {
+	if keyIndex < 0 {
+		return
+	}
+	channelKeyUsageLock.Lock()
+	defer channelKeyUsageLock.Unlock()
+	usage := getChannelKeyUsage(channelId, keyIndex)
+	usage.stats.Errors++
+	usage.stats.LastError = message
+	usage.stats.LastErrorAt = time.Now().Unix()
+}
+
+// GetChannelKeyStats 获取渠道各 Key 的请求统计，key 为 Key 索引
+func GetChannelKeyStats(channelId int) map[int]ChannelKeyStats {
+	channelKeyUsageLock.Lock()
+	defer channelKeyUsageLock.Unlock()
+	result := make(map[int]ChannelKeyStats)
+	for key, usage := range channelKeyUsages {
+		if key.channelId == channelId {
+			stats := usage.stats
+			stats.Inflight = usage.inflight
+			result[key.keyIndex] = stats
+		}
+	}
+	return result
+}
+
+// resetChannelKeyUsages 清除渠道所有 Key 的使用情况和冷却状态，删除 Key 导致索引变化时调用
+func resetChannelKeyUsages(channelId int) {
+	channelKeyUsageLock.Lock()
+	defer channelKeyUsageLock.Unlock()
+	for key := range channelKeyUsages {
+		if key.channelId == channelId {
+			delete(channelKeyUsages, key)
+		}
+	}
+}
+
+// getChannelKeyCooldowns 渠道中仍在冷却的 Key，key index -> 冷却结束时间
+func getChannelKeyCooldowns(channelId int, now int64) map[int]int64 {
+	channelKeyUsageLock.Lock()
+	defer channelKeyUsageLock.Unlock()
+	cooldowns := make(map[int]int64)
+	for key, usage := range channelKeyUsages {
+		if key.channelId == channelId && usage.cooldownUntil > now {
+			cooldowns[key.keyIndex] = usage.cooldownUntil
+		}
+	}
+	return cooldowns
+}
+
+func getChannelKeyCooldownUntil(channelId int, keyIndex int) int64 {
+	channelKeyUsageLock.Lock()
+	defer channelKeyUsageLock.Unlock()
+	if usage, ok := channelKeyUsages[channelKeyUsageKey{channelId: channelId, keyIndex: keyIndex}]; ok {
+		return usage.cooldownUntil
+	}
+	return 0
+}
+
+// clearChannelKeyCooldown Key 被手动启用或禁用时结束冷却
+func clearChannelKeyCooldown(channelId int, keyIndex int) {
+	channelKeyUsageLock.Lock()
+	defer channelKeyUsageLock.Unlock()
+	if usage, ok := channelKeyUsages[channelKeyUsageKey{channelId: channelId, keyIndex: keyIndex}]; ok {
+		usage.cooldownUntil = 0
+	}
+}
+
+// getKeyStatus 获取 Key 的状态，未被禁用但仍在冷却的 Key 返回冷却中
+func (channel *Channel) getKeyStatus(keyIndex int, now int64) int {
+	if status, ok := channel.ChannelInfo.MultiKeyStatusList[keyIndex]; ok {
+		return status
+	}
+	if getChannelKeyCooldownUntil(channel.Id, keyIndex) > now {
+		return common.ChannelStatusCoolingDown
+	}
+	return common.ChannelStatusEnabled
+}
+
+// earliestCooldownKey 未被禁用的冷却中的 Key 里最早结束冷却的一个
+func (channel *Channel) earliestCooldownKey(cooldowns map[int]int64) (int, bool) {
+	selected := -1
+	for idx, until := range cooldowns {
+		if _, disabled := channel.ChannelInfo.MultiKeyStatusList[idx]; disabled {
+			continue
+		}
+		if selected < 0 || until < cooldowns[selected] {
+			selected = idx
+		}
+	}
+	return selected, selected >= 0
+}
+
+// CooldownChannelKey 多 Key 渠道的 Key 被上游限流时暂停使用一段时间，而不是禁用该 Key。
+// 冷却状态只保存在当前节点的内存中，不写入数据库
+func CooldownChannelKey(channelId int, keyIndex int) bool {
+	info, err := CacheGetChannelInfo(channelId)
+	if err != nil || !info.IsMultiKey || keyIndex < 0 || keyIndex >= info.MultiKeySize {
+		return false
+	}
+	if _, disabled := info.MultiKeyStatusList[keyIndex]; disabled {
+		return false
+	}
+	seconds := info.MultiKeyCooldownSeconds
+	if seconds <= 0 {
+		seconds = defaultChannelKeyCooldownSeconds
+	}
+	until := time.Now().Unix() + int64(seconds)
+	channelKeyUsageLock.Lock()
+	defer channelKeyUsageLock.Unlock()
+	usage := getChannelKeyUsage(channelId, keyIndex)
+	if until <= usage.cooldownUntil {
+		return false
+	}
+	usage.cooldownUntil = until
+	return true
+}
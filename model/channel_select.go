@@ -0,0 +1,113 @@
+package model
+
+import (
+	"math"
+	"math/rand"
+	"one-api/setting/operation_setting"
+)
+
+// channelCandidate 同一优先级下参与选择的渠道
+type channelCandidate struct {
+	Id     int
+	Weight int
+}
+
+// 与加权随机一致的平滑系数，避免权重为 0 的渠道永远不被选中
+const channelWeightSmoothing = 10
+
+func clampFloat(value float64, min float64, max float64) float64 {
+	return math.Min(math.Max(value, min), max)
+}
+
+// channelScores 根据在线统计计算渠道得分，没有统计的渠道得分为 1
+func channelScores(modelName string, candidates []channelCandidate) []float64 {
+	selectSetting := operation_setting.GetChannelSelectSetting()
+	exponent := selectSetting.SuccessRateExponent
+	if exponent <= 0 {
+		exponent = 1
+	}
+	stats := make([]ChannelStats, len(candidates))
+	found := make([]bool, len(candidates))
+	// 以有统计渠道的平均首字时间和输出速度作为参考值
+	var ttftSum, tpsSum float64
+	var ttftCount, tpsCount int
+	for i, candidate := range candidates {
+		stats[i], found[i] = GetChannelStats(candidate.Id, modelName)
+		if !found[i] {
+			continue
+		}
+		if stats[i].TTFT > 0 {
+			ttftSum += stats[i].TTFT
+			ttftCount++
+		}
+		if stats[i].TokensPerSecond > 0 {
+			tpsSum += stats[i].TokensPerSecond
+			tpsCount++
+		}
+	}
+	scores := make([]float64, len(candidates))
+	for i := range candidates {
+		if !found[i] {
+			scores[i] = 1
+			continue
+		}
+		score := math.Pow(math.Max(stats[i].SuccessRate, 0.01), exponent)
+		if stats[i].TTFT > 0 && ttftCount > 0 {
+			score *= clampFloat(ttftSum/float64(ttftCount)/stats[i].TTFT, 0.2, 5)
+		}
+		if stats[i].TokensPerSecond > 0 && tpsCount > 0 {
+			score *= clampFloat(stats[i].TokensPerSecond/(tpsSum/float64(tpsCount)), 0.5, 2)
+		}
+		scores[i] = score
+	}
+	return scores
+}
+
+func pickWeighted(weights []float64) int {
+	total := 0.0
+	for _, weight := range weights {
+		total += weight
+	}
+	if total <= 0 {
+		return rand.Intn(len(weights))
+	}
+	random := rand.Float64() * total
+	for i, weight := range weights {
+		random -= weight
+		if random < 0 {
+			return i
+		}
+	}
+	return len(weights) - 1
+}
+
+// selectChannelByStats 按自适应策略从候选渠道中选择一个，返回渠道 id
+func selectChannelByStats(strategy string, modelName string, candidates []channelCandidate) int {
+	if len(candidates) == 1 {
+		return candidates[0].Id
+	}
+	scores := channelScores(modelName, candidates)
+	staticWeights := make([]float64, len(candidates))
+	for i, candidate := range candidates {
+		staticWeights[i] = float64(candidate.Weight + channelWeightSmoothing)
+	}
+	switch strategy {
+	case operation_setting.ChannelSelectPowerOfTwo:
+		// 按静态权重抽取两个不同的渠道，选择得分更高的一个
+		first := pickWeighted(staticWeights)
+		rest := make([]float64, len(staticWeights))
+		copy(rest, staticWeights)
+		rest[first] = 0
+		second := pickWeighted(rest)
+		if second == first || scores[first] >= scores[second] {
+			return candidates[first].Id
+		}
+		return candidates[second].Id
+	default:
+		weights := make([]float64, len(candidates))
+		for i := range candidates {
+			weights[i] = staticWeights[i] * scores[i]
+		}
+		return candidates[pickWeighted(weights)].Id
+	}
+}
@@ -0,0 +1,114 @@
+package model
+
+import (
+	"fmt"
+	"one-api/setting/operation_setting"
+	"sync"
+	"time"
+)
+
+// ChannelStats 渠道在线流量的滚动统计（EWMA）
+type ChannelStats struct {
+	Samples         int64   `json:"samples"`
+	SuccessRate     float64 `json:"success_rate"`
+	TTFT            float64 `json:"ttft"` // 首字时间，毫秒
+	TokensPerSecond float64 `json:"tokens_per_second"`
+	LastUpdate      int64   `json:"last_update"`
+}
+
+var channelStats = make(map[string]*ChannelStats)
+var channelStatsLock sync.RWMutex
+
+func channelStatsKey(channelId int, modelName string) string {
+	if modelName == "" {
+		return fmt.Sprintf("%d", channelId)
+	}
+	return fmt.Sprintf("%d:%s", channelId, modelName)
+}
+
+func ewma(old float64, value float64, alpha float64) float64 {
+	return alpha*value + (1-alpha)*old
+}
+
+func updateChannelStats(key string, success bool, ttft float64, tokensPerSecond float64, now int64) {
+	selectSetting := operation_setting.GetChannelSelectSetting()
+	alpha := selectSetting.EWMAAlpha
+	if alpha <= 0 || alpha > 1 {
+		alpha = 0.2
+	}
+	successValue := 0.0
+	if success {
+		successValue = 1
+	}
+	stats, ok := channelStats[key]
+	// 长时间没有流量的统计已失效，重新开始
+	if !ok || (selectSetting.StatsTTLSeconds > 0 && now-stats.LastUpdate > selectSetting.StatsTTLSeconds) {
+		stats = &ChannelStats{SuccessRate: successValue}
+		channelStats[key] = stats
+	} else {
+		stats.SuccessRate = ewma(stats.SuccessRate, successValue, alpha)
+	}
+	if success && ttft > 0 {
+		if stats.TTFT == 0 {
+			stats.TTFT = ttft
+		} else {
+			stats.TTFT = ewma(stats.TTFT, ttft, alpha)
+		}
+	}
+	if success && tokensPerSecond > 0 {
+		if stats.TokensPerSecond == 0 {
+			stats.TokensPerSecond = tokensPerSecond
+		} else {
+			stats.TokensPerSecond = ewma(stats.TokensPerSecond, tokensPerSecond, alpha)
+		}
+	}
+	stats.Samples++
+	stats.LastUpdate = now
+}
+
+// RecordChannelStats 记录一次渠道请求结果，同时更新渠道和渠道+模型两个维度的统计
+func RecordChannelStats(channelId int, modelName string, success bool, ttft time.Duration, tokensPerSecond float64) {
+	if channelId <= 0 {
+		return
+	}
+	now := time.Now().Unix()
+	ttftMs := float64(ttft.Milliseconds())
+	channelStatsLock.Lock()
+	defer channelStatsLock.Unlock()
+	updateChannelStats(channelStatsKey(channelId, ""), success, ttftMs, tokensPerSecond, now)
+	if modelName != "" {
+		updateChannelStats(channelStatsKey(channelId, modelName), success, ttftMs, tokensPerSecond, now)
+	}
+}
+
+// GetChannelStats 获取渠道统计，模型维度样本足够时优先使用模型维度，统计失效时返回 false
+func GetChannelStats(channelId int, modelName string) (ChannelStats, bool) {
+	selectSetting := operation_setting.GetChannelSelectSetting()
+	now := time.Now().Unix()
+	channelStatsLock.RLock()
+	defer channelStatsLock.RUnlock()
+	valid := func(stats *ChannelStats) bool {
+		if stats == nil || stats.Samples < int64(selectSetting.MinSamples) {
+			return false
+		}
+		return selectSetting.StatsTTLSeconds <= 0 || now-stats.LastUpdate <= selectSetting.StatsTTLSeconds
+	}
+	if stats := channelStats[channelStatsKey(channelId, modelName)]; modelName != "" && valid(stats) {
+		return *stats, true
+	}
+	if stats := channelStats[channelStatsKey(channelId, "")]; valid(stats) {
+		return *stats, true
+	}
+	return ChannelStats{}, false
+}
+
+// GetAllChannelStats 获取全部统计，key 为渠道 id 或 渠道 id:模型
+func GetAllChannelStats() map[string]ChannelStats {
+	channelStatsLock.RLock()
+	defer channelStatsLock.RUnlock()
+	result := make(map[string]ChannelStats, len(channelStats))
+	for key, stats := range channelStats {
+		result[key] = *stats
+	}
+	return result
+}
@@ -0,0 +1,109 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+
+	"gorm.io/gorm"
+)
+
+const (
+	FileStorageLocal    = "local"
+	FileStorageUpstream = "upstream"
+)
+
+const (
+	FileStatusUploaded  = "uploaded"
+	FileStatusProcessed = "processed"
+	FileStatusError     = "error"
+)
+
+type File struct {
+	Id          int    `json:"-"`
+	FileId      string `json:"id" gorm:"type:varchar(64);uniqueIndex"` // 对外暴露的 file id，透传文件与上游保持一致
+	UserId      int64  `json:"-" gorm:"index"`
+	TokenId     int    `json:"-" gorm:"index"`
+	ChannelId   int    `json:"-" gorm:"index"` // 透传到上游时绑定的渠道，本地存储为 0
+	KeyIndex    int    `json:"-"`              // 多 key 渠道上传时使用的 key 下标
+	Filename    string `json:"filename" gorm:"type:varchar(255)"`
+	Purpose     string `json:"purpose" gorm:"type:varchar(32);index"`
+	Bytes       int64  `json:"bytes"`
+	MimeType    string `json:"-" gorm:"type:varchar(128)"`
+	Storage     string `json:"-" gorm:"type:varchar(16)"`
+	StoragePath string `json:"-"`
+	Status      string `json:"status" gorm:"type:varchar(16)"`
+	CreatedAt   int64  `json:"created_at" gorm:"bigint;index"`
+}
+
+func (file *File) IsUpstream() bool {
+	return file.Storage == FileStorageUpstream
+}
+
+func (file *File) Insert() error {
+	if file.CreatedAt == 0 {
+		file.CreatedAt = common.GetTimestamp()
+	}
+	return DB.Create(file).Error
+}
+
+func (file *File) Delete() error {
+	return DB.Delete(file).Error
+}
+
+func GetUserFileByFileId(userId int64, fileId string) (*File, error) {
+	if fileId == "" {
+		return nil, errors.New("file id 为空！")
+	}
+	var file File
+	err := DB.Where("user_id = ? and file_id = ?", userId, fileId).First(&file).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("文件不存在")
+		}
+		return nil, err
+	}
+	return &file, nil
+}
+
+// GetUserFiles 按 OpenAI list 语义查询文件，after 为上一页最后一个 file id
+func GetUserFiles(userId int64, purpose string, after string, limit int, asc bool) ([]*File, error) {
+	var files []*File
+	query := DB.Where("user_id = ?", userId)
+	if purpose != "" {
+		query = query.Where("purpose = ?", purpose)
+	}
+	if after != "" {
+		afterFile, err := GetUserFileByFileId(userId, after)
+		if err != nil {
+			return nil, err
+		}
+		if asc {
+			query = query.Where("id > ?", afterFile.Id)
+		} else {
+			query = query.Where("id < ?", afterFile.Id)
+		}
+	}
+	order := "id desc"
+	if asc {
+		order = "id asc"
+	}
+	err := query.Order(order).Limit(limit).Find(&files).Error
+	return files, err
+}
+
+// GetUserFileBytes 统计用户当前占用的存储字节数
+func GetUserFileBytes(userId int64) (int64, error) {
+	var total int64
+	err := DB.Model(&File{}).Where("user_id = ?", userId).Select("COALESCE(SUM(bytes), 0)").Scan(&total).Error
+	return total, err
+}
+
+// GetFileBoundChannelId 返回透传文件绑定的渠道，未绑定或不存在时返回 0
+func GetFileBoundChannelId(userId int64, fileId string) int {
+	var file File
+	err := DB.Select("channel_id").Where("user_id = ? and file_id = ? and storage = ?", userId, fileId, FileStorageUpstream).First(&file).Error
+	if err != nil {
+		return 0
+	}
+	return file.ChannelId
+}
@@ -0,0 +1,107 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+
+	"gorm.io/gorm"
+)
+
+// FineTuneJob 微调任务与渠道、用户的绑定，微调产出的模型为该用户私有
+type FineTuneJob struct {
+	Id             int64  `json:"-"`
+	JobId          string `json:"id" gorm:"type:varchar(128);uniqueIndex"`
+	UserId         int64  `json:"-" gorm:"index"`
+	TokenId        int    `json:"-" gorm:"index"`
+	ChannelId      int    `json:"-" gorm:"index"`
+	KeyIndex       int    `json:"-"`
+	Legacy         bool   `json:"-"` // 旧版 /v1/fine-tunes 接口创建
+	BaseModel      string `json:"model" gorm:"type:varchar(128)"`
+	FineTunedModel string `json:"fine_tuned_model" gorm:"type:varchar(255);index"`
+	Status         string `json:"status" gorm:"type:varchar(32);index"`
+	Deleted        bool   `json:"-"` // 微调模型已删除，不再可用
+	Data           string `json:"-"` // 上游返回的最新任务详情
+	CreatedAt      int64  `json:"created_at" gorm:"bigint;index"`
+	UpdatedAt      int64  `json:"updated_at" gorm:"bigint"`
+}
+
+func (job *FineTuneJob) IsFinished() bool {
+	switch job.Status {
+	case "succeeded", "failed", "cancelled":
+		return true
+	}
+	return false
+}
+
+func (job *FineTuneJob) Insert() error {
+	now := common.GetTimestamp()
+	if job.CreatedAt == 0 {
+		job.CreatedAt = now
+	}
+	job.UpdatedAt = now
+	return DB.Create(job).Error
+}
+
+func (job *FineTuneJob) Update() error {
+	job.UpdatedAt = common.GetTimestamp()
+	return DB.Save(job).Error
+}
+
+func GetUserFineTuneJob(userId int64, jobId string) (*FineTuneJob, error) {
+	if jobId == "" {
+		return nil, errors.New("job id 为空！")
+	}
+	var job FineTuneJob
+	err := DB.Where("user_id = ? and job_id = ?", userId, jobId).First(&job).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("微调任务不存在")
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+func GetUserFineTuneJobs(userId int64, legacy bool, after string, limit int) ([]*FineTuneJob, error) {
+	var jobs []*FineTuneJob
+	query := DB.Where("user_id = ? and legacy = ?", userId, legacy)
+	if after != "" {
+		afterJob, err := GetUserFineTuneJob(userId, after)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("id < ?", afterJob.Id)
+	}
+	err := query.Order("id desc").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
+
+func GetUnfinishedFineTuneJobs(limit int) []*FineTuneJob {
+	var jobs []*FineTuneJob
+	err := DB.Where("status NOT IN ?", []string{"succeeded", "failed", "cancelled"}).
+		Order("id asc").Limit(limit).Find(&jobs).Error
+	if err != nil {
+		return nil
+	}
+	return jobs
+}
+
+// GetUserFineTunedModel 查询用户私有的微调模型
+func GetUserFineTunedModel(userId int64, modelName string) (*FineTuneJob, error) {
+	var job FineTuneJob
+	err := DB.Where("user_id = ? and fine_tuned_model = ? and deleted = ?", userId, modelName, false).First(&job).Error
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func GetUserFineTunedModels(userId int64) []string {
+	var models []string
+	err := DB.Model(&FineTuneJob{}).Where("user_id = ? and fine_tuned_model <> '' and deleted = ?", userId, false).
+		Pluck("fine_tuned_model", &models).Error
+	if err != nil {
+		return nil
+	}
+	return models
+}
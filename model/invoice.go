@@ -0,0 +1,302 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+const (
+	InvoiceStatusUnpaid = 1
+	InvoiceStatusPaid   = 2
+	InvoiceStatusVoid   = 3
+)
+
+// Invoice 后付费用户的月度账单，由当月的后付费消费流水汇总生成
+type Invoice struct {
+	Id       int    `json:"id"`
+	UserId   int64  `json:"user_id" gorm:"uniqueIndex:idx_invoice_user_period"`
+	Username string `json:"username" gorm:"type:varchar(64)"`
+	// 账单月份，格式 YYYY-MM
+	Period       string          `json:"period" gorm:"type:varchar(7);uniqueIndex:idx_invoice_user_period"`
+	PeriodStart  int64           `json:"period_start" gorm:"bigint"`
+	PeriodEnd    int64           `json:"period_end" gorm:"bigint"`
+	Quota        int             `json:"quota" gorm:"type:int;default:0"`
+	Amount       decimal.Decimal `json:"amount" gorm:"type:decimal(20,10);default:0"`
+	RequestCount int             `json:"request_count" gorm:"type:int;default:0"`
+	Status       int             `json:"status" gorm:"type:int;default:1;index"`
+	CreatedTime  int64           `json:"created_time" gorm:"bigint"`
+	PaidTime     int64           `json:"paid_time" gorm:"bigint"`
+	Remark       string          `json:"remark" gorm:"type:varchar(255)"`
+	Items        []*InvoiceItem  `json:"items,omitempty" gorm:"-:all"`
+}
+
+// InvoiceItem 账单明细，按模型和令牌汇总
+type InvoiceItem struct {
+	Id               int             `json:"id"`
+	InvoiceId        int             `json:"invoice_id" gorm:"index"`
+	ModelName        string          `json:"model_name"`
+	TokenId          int             `json:"token_id"`
+	TokenName        string          `json:"token_name"`
+	RequestCount     int             `json:"request_count"`
+	PromptTokens     int             `json:"prompt_tokens"`
+	CompletionTokens int             `json:"completion_tokens"`
+	Quota            int             `json:"quota"`
+	Amount           decimal.Decimal `json:"amount" gorm:"type:decimal(20,10);default:0"`
+}
+
+// PostPaidUsage 后付费用户的个人消费流水，无论是否开启消费日志都会记录，
+// 与可被清理的日志表分开保存，账单由此汇总
+type PostPaidUsage struct {
+	Id               int64           `json:"id"`
+	UserId           int64           `json:"user_id" gorm:"index:idx_post_paid_usage_user_time"`
+	CreatedAt        int64           `json:"created_at" gorm:"bigint;index:idx_post_paid_usage_user_time"`
+	ModelName        string          `json:"model_name" gorm:"type:varchar(255)"`
+	TokenId          int             `json:"token_id"`
+	TokenName        string          `json:"token_name" gorm:"type:varchar(255)"`
+	PromptTokens     int             `json:"prompt_tokens"`
+	CompletionTokens int             `json:"completion_tokens"`
+	Quota            int             `json:"quota"`
+	Cost             decimal.Decimal `json:"cost" gorm:"type:decimal(20,10);default:0"`
+}
+
+// recordPostPaidUsage 后付费用户的个人消费写入账单流水，组织令牌的消费从组织额度池扣除，不计入个人账单
+func recordPostPaidUsage(userId int64, params RecordConsumeLogParams, cost decimal.Decimal) {
+	userCache, err := GetUserCache(userId)
+	if err != nil || !userCache.PostPaid {
+		return
+	}
+	usage := &PostPaidUsage{
+		UserId:           userId,
+		CreatedAt:        common.GetTimestamp(),
+		ModelName:        params.ModelName,
+		TokenId:          params.TokenId,
+		TokenName:        params.TokenName,
+		PromptTokens:     params.PromptTokens,
+		CompletionTokens: params.CompletionTokens,
+		Quota:            params.Quota,
+		Cost:             cost,
+	}
+	if err = DB.Create(usage).Error; err != nil {
+		common.SysError(fmt.Sprintf("failed to record post-paid usage for user %d: %s", userId, err.Error()))
+	}
+}
+
+// RefundBillingQuota 退还失败任务预扣的额度，个人消费同时写入等额的负数流水冲销提交时记入账单的消费
+func RefundBillingQuota(userId int64, orgId int, quota int, modelName string) error {
+	if err := IncreaseBillingQuota(userId, orgId, quota); err != nil {
+		return err
+	}
+	if orgId == 0 {
+		recordPostPaidUsage(userId, RecordConsumeLogParams{ModelName: modelName, Quota: -quota}, QuotaToCost(-quota))
+	}
+	return nil
+}
+
+// InvoicePeriodRange 返回账单月份的起止时间，按服务器时区计算
+func InvoicePeriodRange(period string) (int64, int64, error) {
+	start, err := time.ParseInLocation("2006-01", period, time.Local)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid invoice period %q, expected YYYY-MM", period)
+	}
+	return start.Unix(), start.AddDate(0, 1, 0).Unix(), nil
+}
+
+// LastInvoicePeriod 上一个自然月
+func LastInvoicePeriod(now time.Time) string {
+	return now.AddDate(0, 0, -now.Day()).Format("2006-01")
+}
+
+// GenerateInvoice 汇总用户在账单月份的后付费消费流水生成账单，未支付的账单会被重新生成
+func GenerateInvoice(userId int64, period string) (*Invoice, error) {
+	start, end, err := InvoicePeriodRange(period)
+	if err != nil {
+		return nil, err
+	}
+	if end > time.Now().Unix() {
+		return nil, errors.New("账单月份尚未结束")
+	}
+	user, err := GetUserById(userId, false)
+	if err != nil {
+		return nil, err
+	}
+	var items []*InvoiceItem
+	err = DB.Model(&PostPaidUsage{}).
+		Select("model_name, token_id, token_name, sum(case when quota < 0 then -1 else 1 end) request_count, sum(prompt_tokens) prompt_tokens, "+
+			"sum(completion_tokens) completion_tokens, sum(quota) quota, coalesce(sum(cost), 0) amount").
+		Where("user_id = ? and created_at >= ? and created_at < ?", userId, start, end).
+		Group("model_name, token_id, token_name").
+		Order("model_name, token_name").
+		Scan(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	invoice := &Invoice{
+		UserId:      userId,
+		Username:    user.Username,
+		Period:      period,
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Status:      InvoiceStatusUnpaid,
+		CreatedTime: common.GetTimestamp(),
+		Items:       items,
+	}
+	for _, item := range items {
+		// SQLite 按浮点数求和，保留与 cost 字段相同的精度
+		item.Amount = item.Amount.Round(10)
+		invoice.Quota += item.Quota
+		invoice.Amount = invoice.Amount.Add(item.Amount)
+		invoice.RequestCount += item.RequestCount
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		var old Invoice
+		if err := tx.Where("user_id = ? and period = ?", userId, period).Limit(1).Find(&old).Error; err != nil {
+			return err
+		}
+		if old.Id != 0 {
+			if old.Status == InvoiceStatusPaid {
+				return errors.New("账单已支付，不能重新生成")
+			}
+			if err := tx.Where("invoice_id = ?", old.Id).Delete(&InvoiceItem{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&old).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(invoice).Error; err != nil {
+			return err
+		}
+		for _, item := range items {
+			item.InvoiceId = invoice.Id
+		}
+		if len(items) > 0 {
+			return tx.CreateInBatches(items, 100).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+// GenerateMonthlyInvoices 为所有尚未出账的后付费用户以及当月有后付费消费的用户生成账单，返回生成的数量
+func GenerateMonthlyInvoices(period string) (int, error) {
+	start, end, err := InvoicePeriodRange(period)
+	if err != nil {
+		return 0, err
+	}
+	var userIds []int64
+	err = DB.Model(&User{}).
+		Where("post_paid = ? or id in (?)", true,
+			DB.Model(&PostPaidUsage{}).Select("user_id").Where("created_at >= ? and created_at < ?", start, end)).
+		Where("id not in (?)", DB.Model(&Invoice{}).Select("user_id").Where("period = ?", period)).
+		Pluck("id", &userIds).Error
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, userId := range userIds {
+		if _, err = GenerateInvoice(userId, period); err != nil {
+			common.SysError(fmt.Sprintf("failed to generate invoice for user %d: %s", userId, err.Error()))
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+func GetAllInvoices(userId int64, status int, period string, startIdx int, num int) ([]*Invoice, int64, error) {
+	var invoices []*Invoice
+	var total int64
+	tx := DB.Model(&Invoice{})
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	if status != 0 {
+		tx = tx.Where("status = ?", status)
+	}
+	if period != "" {
+		tx = tx.Where("period = ?", period)
+	}
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := tx.Order("id desc").Limit(num).Offset(startIdx).Find(&invoices).Error; err != nil {
+		return nil, 0, err
+	}
+	return invoices, total, nil
+}
+
+// GetInvoiceById 获取账单及其明细，userId 不为 0 时只返回该用户的账单
+func GetInvoiceById(id int, userId int64) (*Invoice, error) {
+	var invoice Invoice
+	tx := DB.Where("id = ?", id)
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	if err := tx.First(&invoice).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Where("invoice_id = ?", id).Order("id").Find(&invoice.Items).Error; err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// MarkInvoicePaid 标记账单已支付，并用账单额度抵消用户的透支，余额最多恢复到 0
+func MarkInvoicePaid(id int, remark string) (*Invoice, error) {
+	invoice, err := GetInvoiceById(id, 0)
+	if err != nil {
+		return nil, err
+	}
+	// 账单状态与透支补回在同一事务中提交，避免账单已支付但余额未补回
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Invoice{}).Where("id = ? and status = ?", id, InvoiceStatusUnpaid).
+			Updates(map[string]interface{}{"status": InvoiceStatusPaid, "paid_time": common.GetTimestamp(), "remark": remark})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("只能标记未支付的账单")
+		}
+		if invoice.Quota > 0 {
+			return settleUserOverdraft(tx, invoice.UserId, invoice.Quota)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if invoice.Quota > 0 {
+		if err = invalidateUserCache(invoice.UserId); err != nil {
+			common.SysError("failed to invalidate user cache: " + err.Error())
+		}
+	}
+	invoice.Status = InvoiceStatusPaid
+	return invoice, nil
+}
+
+// settleUserOverdraft 将透支的余额补回，补回的额度不超过 quota 且余额不会超过 0
+func settleUserOverdraft(tx *gorm.DB, userId int64, quota int) error {
+	return tx.Model(&User{}).Where("id = ? and quota < ?", userId, 0).
+		Update("quota", gorm.Expr("case when quota + ? > 0 then 0 else quota + ? end", quota, quota)).Error
+}
+
+// VoidInvoice 作废未支付的账单，不影响用户余额
+func VoidInvoice(id int, remark string) error {
+	result := DB.Model(&Invoice{}).Where("id = ? and status = ?", id, InvoiceStatusUnpaid).
+		Updates(map[string]interface{}{"status": InvoiceStatusVoid, "remark": remark})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("只能作废未支付的账单")
+	}
+	return nil
+}
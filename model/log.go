@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"one-api/common"
+	"one-api/constant"
 	"os"
 	"strings"
 	"time"
@@ -11,6 +12,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
@@ -34,6 +36,10 @@ type Log struct {
 	Group            string `json:"group" gorm:"index"`
 	Ip               string `json:"ip" gorm:"index;default:''"`
 	Other            string `json:"other"`
+	// 本次消费的美元金额，精确到小数点后 10 位
+	Cost decimal.Decimal `json:"cost" gorm:"type:decimal(20,10);default:0"`
+	// 使用组织令牌时的组织
+	OrganizationId int `json:"organization_id,omitempty" gorm:"default:0;index"`
 }
 
 const (
@@ -148,10 +154,39 @@ type RecordConsumeLogParams struct {
 	IsStream         bool                   `json:"is_stream"`
 	Group            string                 `json:"group"`
 	Other            map[string]interface{} `json:"other"`
+	// 精确计费的美元金额，为空时按额度换算
+	Cost *decimal.Decimal `json:"cost,omitempty"`
+	// 不计入消费预算，如渠道测试
+	SkipBudget bool `json:"-"`
+}
+
+// QuotaToCost 按 QuotaPerUnit 将额度换算为美元
+func QuotaToCost(quota int) decimal.Decimal {
+	return decimal.NewFromInt(int64(quota)).Div(decimal.NewFromFloat(common.QuotaPerUnit))
 }
 
 func RecordConsumeLog(c *gin.Context, userId int64, params RecordConsumeLogParams) {
 	common.LogInfo(c, fmt.Sprintf("record consume log: userId=%d, params=%s", userId, common.GetJsonString(params)))
+	// 预算按实际消费累计，与是否记录日志无关
+	if !params.SkipBudget {
+		gopool.Go(func() {
+			RecordBudgetSpend(userId, params.TokenId, params.Group, params.Quota)
+		})
+	}
+	organizationId := common.GetContextKeyInt(c, constant.ContextKeyOrganizationId)
+	if organizationId > 0 {
+		gopool.Go(func() {
+			UpdateOrganizationUsedQuotaAndRequestCount(organizationId, params.Quota)
+		})
+	}
+	cost := QuotaToCost(params.Quota)
+	if params.Cost != nil {
+		cost = *params.Cost
+	}
+	// 后付费账单按流水汇总，与是否记录日志无关
+	if organizationId == 0 {
+		recordPostPaidUsage(userId, params, cost)
+	}
 	if !common.LogConsumeEnabled {
 		return
 	}
@@ -186,7 +221,9 @@ func RecordConsumeLog(c *gin.Context, userId int64, params RecordConsumeLogParam
 			}
 			return ""
 		}(),
-		Other: otherStr,
+		Other:          otherStr,
+		Cost:           cost,
+		OrganizationId: organizationId,
 	}
 	err := LOG_DB.Create(log).Error
 	if err != nil {
@@ -194,7 +231,7 @@ func RecordConsumeLog(c *gin.Context, userId int64, params RecordConsumeLogParam
 	}
 	if common.DataExportEnabled {
 		gopool.Go(func() {
-			LogQuotaData(userId, username, params.ModelName, params.Quota, common.GetTimestamp(), params.PromptTokens+params.CompletionTokens)
+			LogQuotaData(userId, username, params.ModelName, params.Quota, cost, common.GetTimestamp(), params.PromptTokens+params.CompletionTokens)
 		})
 	}
 }
@@ -321,13 +358,14 @@ func SearchUserLogs(userId int64, keyword string) (logs []*Log, err error) {
 }
 
 type Stat struct {
-	Quota int `json:"quota"`
-	Rpm   int `json:"rpm"`
-	Tpm   int `json:"tpm"`
+	Quota int             `json:"quota"`
+	Cost  decimal.Decimal `json:"cost"`
+	Rpm   int             `json:"rpm"`
+	Tpm   int             `json:"tpm"`
 }
 
 func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, channel int, group string) (stat Stat) {
-	tx := LOG_DB.Table("logs").Select("sum(quota) quota")
+	tx := LOG_DB.Table("logs").Select("sum(quota) quota, coalesce(sum(cost), 0) cost")
 
 	// 为rpm和tpm创建单独的查询
 	rpmTpmQuery := LOG_DB.Table("logs").Select("count(*) rpm, sum(prompt_tokens) + sum(completion_tokens) tpm")
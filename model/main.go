@@ -251,6 +251,17 @@ func migrateDB() error {
 		&QuotaData{},
 		&Task{},
 		&Setup{},
+		&File{},
+		&Batch{},
+		&FineTuneJob{},
+		&StoredResponse{},
+		&Budget{},
+		&Organization{},
+		&OrganizationMember{},
+		&OrganizationInvitation{},
+		&Invoice{},
+		&InvoiceItem{},
+		&PostPaidUsage{},
 	)
 	if err != nil {
 		return err
@@ -277,6 +288,17 @@ func migrateDBFast() error {
 		{&QuotaData{}, "QuotaData"},
 		{&Task{}, "Task"},
 		{&Setup{}, "Setup"},
+		{&File{}, "File"},
+		{&Batch{}, "Batch"},
+		{&FineTuneJob{}, "FineTuneJob"},
+		{&StoredResponse{}, "StoredResponse"},
+		{&Budget{}, "Budget"},
+		{&Organization{}, "Organization"},
+		{&OrganizationMember{}, "OrganizationMember"},
+		{&OrganizationInvitation{}, "OrganizationInvitation"},
+		{&Invoice{}, "Invoice"},
+		{&InvoiceItem{}, "InvoiceItem"},
+		{&PostPaidUsage{}, "PostPaidUsage"},
 	}
 	// 动态计算migration数量，确保errChan缓冲区足够大
 	errChan := make(chan error, len(migrations))
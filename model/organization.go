@@ -0,0 +1,510 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+	"strings"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"gorm.io/gorm"
+)
+
+const (
+	OrganizationStatusEnabled  = 1
+	OrganizationStatusDisabled = 2
+)
+
+// Organization 组织拥有共享的额度池，组织令牌的消费从额度池中扣除
+type Organization struct {
+	Id           int    `json:"id"`
+	Name         string `json:"name" gorm:"type:varchar(64)"`
+	OwnerId      int64  `json:"owner_id" gorm:"index"`
+	Quota        int    `json:"quota" gorm:"type:int;default:0"`
+	UsedQuota    int    `json:"used_quota" gorm:"type:int;default:0"`
+	RequestCount int    `json:"request_count" gorm:"type:int;default:0"`
+	Status       int    `json:"status" gorm:"type:int;default:1"`
+	CreatedTime  int64  `json:"created_time" gorm:"bigint"`
+	// 当前用户在组织中的角色，仅用于展示
+	Role int `json:"role,omitempty" gorm:"-:all"`
+}
+
+type OrganizationMember struct {
+	Id             int   `json:"id"`
+	OrganizationId int   `json:"organization_id" gorm:"uniqueIndex:idx_organization_user"`
+	UserId         int64 `json:"user_id" gorm:"uniqueIndex:idx_organization_user;index"`
+	Role           int   `json:"role" gorm:"type:int;default:2"`
+	// 成员累计可消费的组织额度，0 表示不限制
+	QuotaLimit  int    `json:"quota_limit" gorm:"type:int;default:0"`
+	UsedQuota   int    `json:"used_quota" gorm:"type:int;default:0"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	Username    string `json:"username" gorm:"->;-:migration"`
+}
+
+// OrganizationInvitation 组织邀请码，只能使用一次
+type OrganizationInvitation struct {
+	Id             int    `json:"id"`
+	OrganizationId int    `json:"organization_id" gorm:"index"`
+	Code           string `json:"code" gorm:"type:varchar(32);uniqueIndex"`
+	Role           int    `json:"role" gorm:"type:int;default:2"`
+	InviterId      int64  `json:"inviter_id"`
+	// 过期时间，0 表示永不过期
+	ExpiredTime int64 `json:"expired_time" gorm:"bigint"`
+	UsedUserId  int64 `json:"used_user_id"`
+	UsedTime    int64 `json:"used_time" gorm:"bigint"`
+	CreatedTime int64 `json:"created_time" gorm:"bigint"`
+}
+
+// OrganizationUsage 组织按成员和模型汇总的用量
+type OrganizationUsage struct {
+	UserId    int64  `json:"user_id"`
+	Username  string `json:"username"`
+	ModelName string `json:"model_name"`
+	Quota     int    `json:"quota"`
+	Count     int    `json:"count"`
+	TokenUsed int    `json:"token_used"`
+}
+
+// CreateOrganization 创建组织，创建者成为所有者
+func CreateOrganization(name string, ownerId int64) (*Organization, error) {
+	org := &Organization{
+		Name:        name,
+		OwnerId:     ownerId,
+		Status:      OrganizationStatusEnabled,
+		CreatedTime: common.GetTimestamp(),
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&OrganizationMember{
+			OrganizationId: org.Id,
+			UserId:         ownerId,
+			Role:           common.OrganizationRoleOwner,
+			CreatedTime:    org.CreatedTime,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	org.Role = common.OrganizationRoleOwner
+	return org, nil
+}
+
+func GetOrganizationById(id int) (*Organization, error) {
+	var org Organization
+	if err := DB.First(&org, id).Error; err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// GetUserOrganizations 用户加入的所有组织
+func GetUserOrganizations(userId int64) ([]*Organization, error) {
+	var members []*OrganizationMember
+	if err := DB.Where("user_id = ?", userId).Find(&members).Error; err != nil {
+		return nil, err
+	}
+	roles := make(map[int]int, len(members))
+	ids := make([]int, 0, len(members))
+	for _, member := range members {
+		roles[member.OrganizationId] = member.Role
+		ids = append(ids, member.OrganizationId)
+	}
+	var orgs []*Organization
+	if len(ids) == 0 {
+		return orgs, nil
+	}
+	if err := DB.Where("id in ?", ids).Order("id").Find(&orgs).Error; err != nil {
+		return nil, err
+	}
+	for _, org := range orgs {
+		org.Role = roles[org.Id]
+	}
+	return orgs, nil
+}
+
+func GetAllOrganizations(keyword string, startIdx int, num int) ([]*Organization, int64, error) {
+	var orgs []*Organization
+	var total int64
+	tx := DB.Model(&Organization{})
+	if keyword != "" {
+		tx = tx.Where("name LIKE ?", "%"+keyword+"%")
+	}
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := tx.Order("id desc").Limit(num).Offset(startIdx).Find(&orgs).Error; err != nil {
+		return nil, 0, err
+	}
+	return orgs, total, nil
+}
+
+func UpdateOrganizationName(id int, name string) error {
+	err := DB.Model(&Organization{}).Where("id = ?", id).Update("name", name).Error
+	invalidateOrganizationCache(id)
+	return err
+}
+
+// UpdateOrganizationByAdmin 系统管理员修改组织额度和状态
+func UpdateOrganizationByAdmin(id int, quota int, status int) error {
+	err := DB.Model(&Organization{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"quota": quota, "status": status}).Error
+	invalidateOrganizationCache(id)
+	return err
+}
+
+// DeleteOrganization 删除组织及其成员和邀请，剩余额度退还给所有者，组织令牌随之失效
+func DeleteOrganization(id int) error {
+	org, err := GetOrganizationById(id)
+	if err != nil {
+		return err
+	}
+	var memberIds []int64
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&OrganizationMember{}).Where("organization_id = ?", id).Pluck("user_id", &memberIds).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", id).Delete(&OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", id).Delete(&OrganizationInvitation{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Organization{}, id).Error
+	})
+	if err != nil {
+		return err
+	}
+	invalidateOrganizationCache(id)
+	for _, userId := range memberIds {
+		invalidateOrganizationMemberCache(id, userId)
+	}
+	if org.Quota > 0 {
+		return IncreaseUserQuota(org.OwnerId, org.Quota, true)
+	}
+	return nil
+}
+
+func GetOrganizationMember(orgId int, userId int64) (*OrganizationMember, error) {
+	var member OrganizationMember
+	if err := DB.Where("organization_id = ? and user_id = ?", orgId, userId).First(&member).Error; err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+func GetOrganizationMembers(orgId int) ([]*OrganizationMember, error) {
+	var members []*OrganizationMember
+	err := DB.Table("organization_members").
+		Select("organization_members.*, users.username").
+		Joins("left join users on users.id = organization_members.user_id").
+		Where("organization_members.organization_id = ?", orgId).
+		Order("organization_members.id").
+		Find(&members).Error
+	return members, err
+}
+
+// UpdateOrganizationMember 修改成员角色和额度上限
+func UpdateOrganizationMember(orgId int, userId int64, role int, quotaLimit int) error {
+	err := DB.Model(&OrganizationMember{}).Where("organization_id = ? and user_id = ?", orgId, userId).
+		Updates(map[string]interface{}{"role": role, "quota_limit": quotaLimit}).Error
+	invalidateOrganizationMemberCache(orgId, userId)
+	return err
+}
+
+// ResetOrganizationMemberUsedQuota 清零成员已用额度，重新开始计算上限
+func ResetOrganizationMemberUsedQuota(orgId int, userId int64) error {
+	err := DB.Model(&OrganizationMember{}).Where("organization_id = ? and user_id = ?", orgId, userId).
+		Update("used_quota", 0).Error
+	invalidateOrganizationMemberCache(orgId, userId)
+	return err
+}
+
+func DeleteOrganizationMember(orgId int, userId int64) error {
+	err := DB.Where("organization_id = ? and user_id = ?", orgId, userId).Delete(&OrganizationMember{}).Error
+	invalidateOrganizationMemberCache(orgId, userId)
+	return err
+}
+
+// CheckOrganizationTokenAccess 组织令牌只能在组织启用且所属用户仍是成员（非只读）时使用
+func CheckOrganizationTokenAccess(orgId int, userId int64) error {
+	org, err := GetOrganizationCache(orgId)
+	if err != nil {
+		return errors.New("令牌所属组织不存在")
+	}
+	if org.Status != OrganizationStatusEnabled {
+		return errors.New("令牌所属组织已被禁用")
+	}
+	member, err := GetOrganizationMemberCache(orgId, userId)
+	if err != nil || member.Role < common.OrganizationRoleMember {
+		return errors.New("无权使用该组织的令牌")
+	}
+	return nil
+}
+
+func (invitation *OrganizationInvitation) Insert() error {
+	invitation.Code = strings.ToLower(common.GetRandomString(16))
+	invitation.CreatedTime = common.GetTimestamp()
+	return DB.Create(invitation).Error
+}
+
+func GetOrganizationInvitations(orgId int) ([]*OrganizationInvitation, error) {
+	var invitations []*OrganizationInvitation
+	err := DB.Where("organization_id = ?", orgId).Order("id desc").Find(&invitations).Error
+	return invitations, err
+}
+
+func DeleteOrganizationInvitation(orgId int, id int) error {
+	result := DB.Where("organization_id = ? and id = ?", orgId, id).Delete(&OrganizationInvitation{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("邀请不存在")
+	}
+	return nil
+}
+
+// AcceptOrganizationInvitation 使用邀请码加入组织
+func AcceptOrganizationInvitation(code string, userId int64) (*Organization, error) {
+	var org *Organization
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var invitation OrganizationInvitation
+		if err := tx.Where("code = ?", strings.TrimSpace(code)).First(&invitation).Error; err != nil {
+			return errors.New("邀请码无效")
+		}
+		if invitation.UsedUserId != 0 {
+			return errors.New("邀请码已被使用")
+		}
+		if invitation.ExpiredTime != 0 && invitation.ExpiredTime < common.GetTimestamp() {
+			return errors.New("邀请码已过期")
+		}
+		var count int64
+		tx.Model(&OrganizationMember{}).Where("organization_id = ? and user_id = ?", invitation.OrganizationId, userId).Count(&count)
+		if count > 0 {
+			return errors.New("已经是该组织的成员")
+		}
+		// 防止并发使用同一个邀请码
+		result := tx.Model(&OrganizationInvitation{}).Where("id = ? and used_user_id = ?", invitation.Id, 0).
+			Updates(map[string]interface{}{"used_user_id": userId, "used_time": common.GetTimestamp()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("邀请码已被使用")
+		}
+		if err := tx.Create(&OrganizationMember{
+			OrganizationId: invitation.OrganizationId,
+			UserId:         userId,
+			Role:           invitation.Role,
+			CreatedTime:    common.GetTimestamp(),
+		}).Error; err != nil {
+			return err
+		}
+		org = &Organization{}
+		if err := tx.First(org, invitation.OrganizationId).Error; err != nil {
+			return err
+		}
+		org.Role = invitation.Role
+		return nil
+	})
+	return org, err
+}
+
+// TransferUserQuotaToOrganization 将用户的个人额度转入组织额度池
+func TransferUserQuotaToOrganization(userId int64, orgId int, quota int) error {
+	if quota <= 0 {
+		return errors.New("额度必须大于 0")
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).Where("id = ? and quota >= ?", userId, quota).
+			Update("quota", gorm.Expr("quota - ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("用户额度不足")
+		}
+		return tx.Model(&Organization{}).Where("id = ?", orgId).Update("quota", gorm.Expr("quota + ?", quota)).Error
+	})
+	if err != nil {
+		return err
+	}
+	gopool.Go(func() {
+		if err := cacheDecrUserQuota(userId, int64(quota)); err != nil {
+			common.SysError("failed to decrease user quota cache: " + err.Error())
+		}
+	})
+	cacheIncrOrganizationQuota(orgId, 0, -quota)
+	return nil
+}
+
+// GetOrganizationAvailableQuota 成员可使用的组织额度，受额度池余额和成员上限共同限制
+func GetOrganizationAvailableQuota(orgId int, userId int64) (int, error) {
+	org, err := GetOrganizationCache(orgId)
+	if err != nil {
+		return 0, err
+	}
+	member, err := GetOrganizationMemberCache(orgId, userId)
+	if err != nil {
+		return 0, errors.New("不是该组织的成员")
+	}
+	quota := org.Quota
+	if member.QuotaLimit > 0 && member.QuotaLimit-member.UsedQuota < quota {
+		quota = member.QuotaLimit - member.UsedQuota
+	}
+	return quota, nil
+}
+
+// decreaseOrganizationQuota 在同一事务中扣除额度池并累计成员已用额度，quota 为负表示退还；
+// strict 时扣除不允许超出余额和成员上限，否则与个人额度一样允许透支
+func decreaseOrganizationQuota(orgId int, userId int64, quota int, strict bool) error {
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		orgTx := tx.Model(&Organization{}).Where("id = ?", orgId)
+		if strict && quota > 0 {
+			orgTx = orgTx.Where("quota >= ?", quota)
+		}
+		result := orgTx.Update("quota", gorm.Expr("quota - ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 && strict && quota > 0 {
+			return errors.New("组织额度不足")
+		}
+		memberTx := tx.Model(&OrganizationMember{}).Where("organization_id = ? and user_id = ?", orgId, userId)
+		if strict && quota > 0 {
+			memberTx = memberTx.Where("(quota_limit = 0 or used_quota + ? <= quota_limit)", quota)
+		}
+		result = memberTx.Update("used_quota", gorm.Expr("used_quota + ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 && strict && quota > 0 {
+			return errors.New("超出成员的组织额度上限")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	cacheIncrOrganizationQuota(orgId, userId, quota)
+	return nil
+}
+
+// GetBillingQuota 请求可用的额度，组织令牌使用组织额度，否则使用用户额度，后付费用户包含信用额度
+func GetBillingQuota(userId int64, orgId int) (int, error) {
+	if orgId > 0 {
+		return GetOrganizationAvailableQuota(orgId, userId)
+	}
+	userCache, err := GetUserCache(userId)
+	if err != nil {
+		return 0, err
+	}
+	return userCache.AvailableQuota(), nil
+}
+
+// DecreaseBillingQuota 预扣请求的额度，组织令牌同时计入成员已用额度，不允许超出组织余额和成员上限
+func DecreaseBillingQuota(userId int64, orgId int, quota int) error {
+	if orgId > 0 {
+		if quota < 0 {
+			return errors.New("quota 不能为负数！")
+		}
+		return decreaseOrganizationQuota(orgId, userId, quota, true)
+	}
+	return DecreaseUserQuota(userId, quota)
+}
+
+// SettleBillingQuota 结算请求实际消耗超出预扣的部分，请求已经完成，组织额度池和成员上限与个人额度一样允许透支
+func SettleBillingQuota(userId int64, orgId int, quota int) error {
+	if orgId > 0 {
+		if quota < 0 {
+			return errors.New("quota 不能为负数！")
+		}
+		return decreaseOrganizationQuota(orgId, userId, quota, false)
+	}
+	return DecreaseUserQuota(userId, quota)
+}
+
+// IncreaseBillingQuota 退还请求的额度
+func IncreaseBillingQuota(userId int64, orgId int, quota int) error {
+	if orgId > 0 {
+		if quota < 0 {
+			return errors.New("quota 不能为负数！")
+		}
+		return decreaseOrganizationQuota(orgId, userId, -quota, false)
+	}
+	return IncreaseUserQuota(userId, quota, false)
+}
+
+// UpdateOrganizationUsedQuotaAndRequestCount 累计组织的消费统计
+func UpdateOrganizationUsedQuotaAndRequestCount(orgId int, quota int) {
+	err := DB.Model(&Organization{}).Where("id = ?", orgId).Updates(map[string]interface{}{
+		"used_quota":    gorm.Expr("used_quota + ?", quota),
+		"request_count": gorm.Expr("request_count + ?", 1),
+	}).Error
+	if err != nil {
+		common.SysError("failed to update organization used quota: " + err.Error())
+	}
+}
+
+func GetOrganizationTokens(orgId int, startIdx int, num int) ([]*Token, int64, error) {
+	var tokens []*Token
+	var total int64
+	tx := DB.Model(&Token{}).Where("organization_id = ?", orgId)
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := tx.Order("id desc").Limit(num).Offset(startIdx).Find(&tokens).Error; err != nil {
+		return nil, 0, err
+	}
+	return tokens, total, nil
+}
+
+func GetOrganizationLogs(orgId int, startTimestamp int64, endTimestamp int64, modelName string, username string, startIdx int, num int) (logs []*Log, total int64, err error) {
+	tx := LOG_DB.Where("logs.organization_id = ? and logs.type = ?", orgId, LogTypeConsume)
+	if modelName != "" {
+		tx = tx.Where("logs.model_name like ?", modelName)
+	}
+	if username != "" {
+		tx = tx.Where("logs.username = ?", username)
+	}
+	if startTimestamp != 0 {
+		tx = tx.Where("logs.created_at >= ?", startTimestamp)
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("logs.created_at <= ?", endTimestamp)
+	}
+	if err = tx.Model(&Log{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err = tx.Order("logs.id desc").Limit(num).Offset(startIdx).Find(&logs).Error; err != nil {
+		return nil, 0, err
+	}
+	formatUserLogs(logs)
+	return logs, total, nil
+}
+
+// GetOrganizationUsage 按成员和模型汇总组织在时间段内的用量
+func GetOrganizationUsage(orgId int, startTimestamp int64, endTimestamp int64) ([]*OrganizationUsage, error) {
+	var usages []*OrganizationUsage
+	tx := LOG_DB.Table("logs").
+		Select("user_id, username, model_name, sum(quota) quota, count(*) count, sum(prompt_tokens) + sum(completion_tokens) token_used").
+		Where("organization_id = ? and type = ?", orgId, LogTypeConsume)
+	if startTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", startTimestamp)
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", endTimestamp)
+	}
+	err := tx.Group("user_id, username, model_name").Order("quota desc").Scan(&usages).Error
+	return usages, err
+}
+
+// DeleteOrganizationToken 组织管理员删除组织令牌
+func DeleteOrganizationToken(orgId int, tokenId int) error {
+	var token Token
+	if err := DB.Where("id = ? and organization_id = ?", tokenId, orgId).First(&token).Error; err != nil {
+		return err
+	}
+	return token.Delete()
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"fmt"
+	"one-api/common"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+func getOrganizationCacheKey(orgId int) string {
+	return fmt.Sprintf("organization:%d", orgId)
+}
+
+func getOrganizationMemberCacheKey(orgId int, userId int64) string {
+	return fmt.Sprintf("organization_member:%d:%d", orgId, userId)
+}
+
+func invalidateOrganizationCache(orgId int) {
+	if !common.RedisEnabled {
+		return
+	}
+	if err := common.RedisDelKey(getOrganizationCacheKey(orgId)); err != nil {
+		common.SysError("failed to delete organization cache: " + err.Error())
+	}
+}
+
+func invalidateOrganizationMemberCache(orgId int, userId int64) {
+	if !common.RedisEnabled {
+		return
+	}
+	if err := common.RedisDelKey(getOrganizationMemberCacheKey(orgId, userId)); err != nil {
+		common.SysError("failed to delete organization member cache: " + err.Error())
+	}
+}
+
+// GetOrganizationCache 优先从 Redis 获取组织，用于请求链路上的状态和额度检查
+func GetOrganizationCache(orgId int) (org *Organization, err error) {
+	if common.RedisEnabled {
+		var cached Organization
+		if err = common.RedisHGetObj(getOrganizationCacheKey(orgId), &cached); err == nil {
+			return &cached, nil
+		}
+	}
+	org, err = GetOrganizationById(orgId)
+	if err != nil {
+		return nil, err
+	}
+	if common.RedisEnabled {
+		cached := *org
+		gopool.Go(func() {
+			if err := common.RedisHSetObj(getOrganizationCacheKey(orgId), &cached, time.Duration(common.RedisKeyCacheSeconds())*time.Second); err != nil {
+				common.SysError("failed to update organization cache: " + err.Error())
+			}
+		})
+	}
+	return org, nil
+}
+
+// GetOrganizationMemberCache 优先从 Redis 获取组织成员，用于请求链路上的权限和成员额度检查
+func GetOrganizationMemberCache(orgId int, userId int64) (member *OrganizationMember, err error) {
+	if common.RedisEnabled {
+		var cached OrganizationMember
+		if err = common.RedisHGetObj(getOrganizationMemberCacheKey(orgId, userId), &cached); err == nil {
+			return &cached, nil
+		}
+	}
+	member, err = GetOrganizationMember(orgId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if common.RedisEnabled {
+		cached := *member
+		gopool.Go(func() {
+			if err := common.RedisHSetObj(getOrganizationMemberCacheKey(orgId, userId), &cached, time.Duration(common.RedisKeyCacheSeconds())*time.Second); err != nil {
+				common.SysError("failed to update organization member cache: " + err.Error())
+			}
+		})
+	}
+	return member, nil
+}
+
+// cacheIncrOrganizationQuota 同步额度池和成员已用额度的缓存，quota 为正表示扣除
+func cacheIncrOrganizationQuota(orgId int, userId int64, quota int) {
+	if !common.RedisEnabled {
+		return
+	}
+	gopool.Go(func() {
+		if err := common.RedisHIncrBy(getOrganizationCacheKey(orgId), "Quota", int64(-quota)); err != nil {
+			common.SysError("failed to update organization quota cache: " + err.Error())
+		}
+		if userId == 0 {
+			return
+		}
+		if err := common.RedisHIncrBy(getOrganizationMemberCacheKey(orgId, userId), "UsedQuota", int64(quota)); err != nil {
+			common.SysError("failed to update organization member cache: " + err.Error())
+		}
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"one-api/common"
 	"one-api/constant"
+	"one-api/setting/operation_setting"
 	"one-api/setting/ratio_setting"
 	"one-api/types"
 	"sync"
@@ -19,6 +20,8 @@ type Pricing struct {
 	CompletionRatio        float64                 `json:"completion_ratio"`
 	EnableGroup            []string                `json:"enable_groups"`
 	SupportedEndpointTypes []constant.EndpointType `json:"supported_endpoint_types"`
+	// 阶梯和时段价格规则，时段按 pricing_tier_timezone 计算
+	PricingTiers *operation_setting.ModelPricingTiers `json:"pricing_tiers,omitempty"`
 }
 
 var (
@@ -121,6 +124,7 @@ func updatePricing() {
 			pricing.CompletionRatio = ratio_setting.GetCompletionRatio(model)
 			pricing.QuotaType = 0
 		}
+		pricing.PricingTiers = operation_setting.GetModelPricingTiers(model)
 		pricingMap = append(pricingMap, pricing)
 	}
 	lastGetPricingTime = time.Now()
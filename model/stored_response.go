@@ -0,0 +1,64 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+
+	"gorm.io/gorm"
+)
+
+// StoredResponse /v1/responses 的会话状态，previous_response_id 在本地展开，不依赖上游是否保存
+type StoredResponse struct {
+	Id         int64  `json:"-"`
+	ResponseId string `json:"id" gorm:"type:varchar(128);uniqueIndex"`
+	UserId     int64  `json:"-" gorm:"index"`
+	Model      string `json:"model" gorm:"type:varchar(128)"`
+	Input      string `json:"-"` // 展开后的全部输入项（JSON 数组）
+	Output     string `json:"-"` // 本次输出项（JSON 数组）
+	Data       string `json:"-"` // 完整的响应对象
+	CreatedAt  int64  `json:"created_at" gorm:"bigint;index"`
+	ExpiresAt  int64  `json:"expires_at" gorm:"bigint;index"`
+}
+
+func (response *StoredResponse) Insert() error {
+	if response.CreatedAt == 0 {
+		response.CreatedAt = common.GetTimestamp()
+	}
+	return DB.Create(response).Error
+}
+
+func (response *StoredResponse) Delete() error {
+	return DB.Delete(response).Error
+}
+
+func GetUserStoredResponse(userId int64, responseId string) (*StoredResponse, error) {
+	if responseId == "" {
+		return nil, errors.New("response id 为空！")
+	}
+	var response StoredResponse
+	err := DB.Where("user_id = ? and response_id = ?", userId, responseId).
+		Where("expires_at = 0 or expires_at > ?", common.GetTimestamp()).First(&response).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("response 不存在")
+		}
+		return nil, err
+	}
+	return &response, nil
+}
+
+// TrimUserStoredResponses 只保留用户最新的 keep 条记录
+func TrimUserStoredResponses(userId int64, keep int) error {
+	var ids []int64
+	err := DB.Model(&StoredResponse{}).Where("user_id = ?", userId).
+		Order("id desc").Offset(keep).Limit(100).Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	return DB.Where("id in ?", ids).Delete(&StoredResponse{}).Error
+}
+
+func DeleteExpiredStoredResponses(now int64) (int64, error) {
+	result := DB.Where("expires_at > 0 and expires_at <= ?", now).Delete(&StoredResponse{})
+	return result.RowsAffected, result.Error
+}
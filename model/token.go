@@ -26,6 +26,8 @@ type Token struct {
 	AllowIps           *string        `json:"allow_ips" gorm:"default:''"`
 	UsedQuota          int            `json:"used_quota" gorm:"default:0"` // used quota
 	Group              string         `json:"group" gorm:"default:''"`
+	ResponseCache      bool           `json:"response_cache"`                         // 是否使用响应缓存
+	OrganizationId     int            `json:"organization_id" gorm:"default:0;index"` // 组织令牌，消费从组织额度池扣除
 	DeletedAt          gorm.DeletedAt `gorm:"index"`
 }
 
@@ -184,7 +186,7 @@ func (token *Token) Update() (err error) {
 		}
 	}()
 	err = DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota",
-		"model_limits_enabled", "model_limits", "allow_ips", "group").Updates(token).Error
+		"model_limits_enabled", "model_limits", "allow_ips", "group", "response_cache").Updates(token).Error
 	return err
 }
 
@@ -8,8 +8,10 @@ import (
 	"one-api/dto"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
@@ -44,17 +46,30 @@ type User struct {
 	Setting          string         `json:"setting" gorm:"type:text;column:setting"`
 	Remark           string         `json:"remark,omitempty" gorm:"type:varchar(255)" validate:"max=255"`
 	StripeCustomer   string         `json:"stripe_customer" gorm:"type:varchar(64);column:stripe_customer;index"`
+	// 精确计费时尚未扣除的不足 1 的额度
+	QuotaRemainder decimal.Decimal `json:"-" gorm:"type:decimal(20,10);default:0;column:quota_remainder"`
+	// 精确计费时的美元余额，仅用于展示
+	BalanceUsd *decimal.Decimal `json:"balance_usd,omitempty" gorm:"-:all"`
+	// 用户及其令牌的周期预算，仅用于展示
+	Budgets []*Budget `json:"budgets,omitempty" gorm:"-:all"`
+	// 后付费用户按月出账，余额可以透支到 -CreditLimit
+	PostPaid    bool `json:"post_paid" gorm:"default:false"`
+	CreditLimit int  `json:"credit_limit" gorm:"type:int;default:0"`
+	// 切换为后付费的时间
+	PostPaidTime int64 `json:"post_paid_time" gorm:"bigint;default:0"`
 }
 
 func (user *User) ToBaseUser() *UserBase {
 	cache := &UserBase{
-		Id:       user.Id,
-		Group:    user.Group,
-		Quota:    user.Quota,
-		Status:   user.Status,
-		Username: user.Username,
-		Setting:  user.Setting,
-		Email:    user.Email,
+		Id:          user.Id,
+		Group:       user.Group,
+		Quota:       user.Quota,
+		Status:      user.Status,
+		Username:    user.Username,
+		Setting:     user.Setting,
+		Email:       user.Email,
+		PostPaid:    user.PostPaid,
+		CreditLimit: user.CreditLimit,
 	}
 	return cache
 }
@@ -388,6 +403,33 @@ func (user *User) Edit(updatePassword bool) error {
 	return updateUserCache(*user)
 }
 
+// UpdateUserBillingMode 设置用户为预付费或后付费，后付费用户可以透支到信用额度
+func UpdateUserBillingMode(id int64, postPaid bool, creditLimit int) error {
+	if creditLimit < 0 {
+		return errors.New("信用额度不能为负数")
+	}
+	if !postPaid {
+		creditLimit = 0
+	}
+	user, err := GetUserById(id, false)
+	if err != nil {
+		return err
+	}
+	updates := map[string]interface{}{"post_paid": postPaid, "credit_limit": creditLimit}
+	if postPaid && !user.PostPaid {
+		// 账单按全部消费出账，支付后只补回透支，仍有预付余额时切换会重复收取这部分消费
+		if user.Quota > 0 {
+			return fmt.Errorf("用户仍有 %s 预付余额，请先清零余额再切换为后付费", common.LogQuota(user.Quota))
+		}
+		updates["post_paid_time"] = common.GetTimestamp()
+	}
+	err = DB.Model(&User{}).Where("id = ?", id).Updates(updates).Error
+	if err != nil {
+		return err
+	}
+	return invalidateUserCache(id)
+}
+
 func (user *User) Delete() error {
 	if user.Id == 0 {
 		return errors.New("id 为空！")
@@ -712,6 +754,84 @@ func decreaseUserQuota(id int64, quota int64) (err error) {
 	return err
 }
 
+var userQuotaRemainders = make(map[int64]decimal.Decimal)
+var userQuotaRemainderLock sync.Mutex
+
+// CarryUserQuotaRemainder 累计不足 1 的额度，余数满 1 时返回需要额外扣除的整数额度。
+// 余数先在内存中累计，定期写入数据库，避免每次请求都读写用户表
+func CarryUserQuotaRemainder(id int64, fraction decimal.Decimal) int {
+	if !fraction.IsPositive() {
+		return 0
+	}
+	userQuotaRemainderLock.Lock()
+	defer userQuotaRemainderLock.Unlock()
+	remainder := userQuotaRemainders[id].Add(fraction.Round(10))
+	whole := remainder.Floor()
+	userQuotaRemainders[id] = remainder.Sub(whole)
+	return int(whole.IntPart())
+}
+
+func carryUserQuotaRemainder(id int64, fraction decimal.Decimal) (int, error) {
+	err := DB.Model(&User{}).Where("id = ?", id).Update("quota_remainder", gorm.Expr("quota_remainder + ?", fraction)).Error
+	if err != nil {
+		return 0, err
+	}
+	var user User
+	if err = DB.Select("quota_remainder").Where("id = ?", id).First(&user).Error; err != nil {
+		return 0, err
+	}
+	whole := user.QuotaRemainder.Floor().IntPart()
+	if whole < 1 {
+		return 0, nil
+	}
+	// 并发扣除时只有一个请求能取走整数部分，其余留到下次
+	result := DB.Model(&User{}).Where("id = ? AND quota_remainder >= ?", id, whole).
+		Update("quota_remainder", gorm.Expr("quota_remainder - ?", whole))
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, nil
+	}
+	return int(whole), nil
+}
+
+// flushUserQuotaRemainders 将内存中累计的余数写入数据库，数据库中的余数满 1 时取回内存，由用户的下一次请求扣除
+func flushUserQuotaRemainders() {
+	userQuotaRemainderLock.Lock()
+	store := userQuotaRemainders
+	userQuotaRemainders = make(map[int64]decimal.Decimal)
+	userQuotaRemainderLock.Unlock()
+	for id, remainder := range store {
+		if !remainder.IsPositive() {
+			continue
+		}
+		whole, err := carryUserQuotaRemainder(id, remainder)
+		if err != nil {
+			common.SysError("failed to batch update user quota remainder: " + err.Error())
+			continue
+		}
+		if whole > 0 {
+			userQuotaRemainderLock.Lock()
+			userQuotaRemainders[id] = userQuotaRemainders[id].Add(decimal.NewFromInt(int64(whole)))
+			userQuotaRemainderLock.Unlock()
+		}
+	}
+}
+
+// getPendingUserQuotaRemainder 内存中尚未写入数据库的余数
+func getPendingUserQuotaRemainder(id int64) decimal.Decimal {
+	userQuotaRemainderLock.Lock()
+	defer userQuotaRemainderLock.Unlock()
+	return userQuotaRemainders[id]
+}
+
+// GetUserExactBalance 精确计费时的美元余额，即额度减去尚未扣除的余数
+func GetUserExactBalance(user *User) decimal.Decimal {
+	quota := decimal.NewFromInt(int64(user.Quota)).Sub(user.QuotaRemainder).Sub(getPendingUserQuotaRemainder(user.Id))
+	return quota.Div(decimal.NewFromFloat(common.QuotaPerUnit))
+}
+
 func DeltaUpdateUserQuota(id int64, delta int) (err error) {
 	if delta == 0 {
 		return nil
@@ -21,6 +21,9 @@ type UserBase struct {
 	Status   int    `json:"status"`
 	Username string `json:"username"`
 	Setting  string `json:"setting"`
+	// 后付费用户的透支额度
+	PostPaid    bool `json:"post_paid"`
+	CreditLimit int  `json:"credit_limit"`
 }
 
 func (user *UserBase) WriteContext(c *gin.Context) {
@@ -32,6 +35,14 @@ func (user *UserBase) WriteContext(c *gin.Context) {
 	common.SetContextKey(c, constant.ContextKeyUserSetting, user.GetSetting())
 }
 
+// AvailableQuota 可用于请求的额度，后付费用户可以透支到信用额度
+func (user *UserBase) AvailableQuota() int {
+	if user.PostPaid {
+		return user.Quota + user.CreditLimit
+	}
+	return user.Quota
+}
+
 func (user *UserBase) GetSetting() dto.UserSetting {
 	setting := dto.UserSetting{}
 	if user.Setting != "" {
@@ -99,13 +110,15 @@ func GetUserCache(userId int64) (userCache *UserBase, err error) {
 
 	// Create cache object from user data
 	userCache = &UserBase{
-		Id:       user.Id,
-		Group:    user.Group,
-		Quota:    user.Quota,
-		Status:   user.Status,
-		Username: user.Username,
-		Setting:  user.Setting,
-		Email:    user.Email,
+		Id:          user.Id,
+		Group:       user.Group,
+		Quota:       user.Quota,
+		Status:      user.Status,
+		Username:    user.Username,
+		Setting:     user.Setting,
+		Email:       user.Email,
+		PostPaid:    user.PostPaid,
+		CreditLimit: user.CreditLimit,
 	}
 
 	return userCache, nil
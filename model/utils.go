@@ -38,6 +38,16 @@ func InitBatchUpdater() {
 	})
 }
 
+// InitQuotaRemainderUpdater 精确计费的额度余数始终在内存中累计，定期写入数据库
+func InitQuotaRemainderUpdater() {
+	gopool.Go(func() {
+		for {
+			time.Sleep(time.Duration(common.BatchUpdateInterval) * time.Second)
+			flushUserQuotaRemainders()
+		}
+	})
+}
+
 func addNewRecord(type_ int, id int64, value int64) {
 	batchUpdateLocks[type_].Lock()
 	defer batchUpdateLocks[type_].Unlock()
@@ -238,6 +238,10 @@ func doRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http
 		}
 	}
 
+	if service.GetHedgeRace(c) != nil {
+		// 对冲请求需要在另一渠道获胜后取消上游请求
+		req = req.WithContext(c.Request.Context())
+	}
 	resp, err := client.Do(req)
 
 	if err != nil {
@@ -246,6 +250,9 @@ func doRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http
 	if resp == nil {
 		return nil, errors.New("resp is nil")
 	}
+	if race := service.GetHedgeRace(c); race != nil {
+		race.MarkResponded()
+	}
 
 	_ = req.Body.Close()
 	_ = c.Request.Body.Close()
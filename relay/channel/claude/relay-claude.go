@@ -639,6 +639,36 @@ func HandleStreamResponseData(c *gin.Context, info *relaycommon.RelayInfo, claud
 		if err != nil {
 			common.LogError(c, "send_stream_response_failed: "+err.Error())
 		}
+	} else if info.RelayFormat == relaycommon.RelayFormatGemini {
+		response := StreamResponseClaude2OpenAI(requestMode, &claudeResponse)
+
+		if !FormatClaudeResponseInfo(requestMode, &claudeResponse, response, claudeInfo) {
+			return nil
+		}
+		if response == nil {
+			return nil
+		}
+		if geminiResponse := service.StreamResponseOpenAI2Gemini(response, info); geminiResponse != nil {
+			err = helper.ObjectData(c, geminiResponse)
+			if err != nil {
+				common.LogError(c, "send_stream_response_failed: "+err.Error())
+			}
+		}
+	} else if info.RelayFormat == relaycommon.RelayFormatOpenAIResponses {
+		response := StreamResponseClaude2OpenAI(requestMode, &claudeResponse)
+
+		if !FormatClaudeResponseInfo(requestMode, &claudeResponse, response, claudeInfo) {
+			return nil
+		}
+		if response == nil {
+			return nil
+		}
+		for _, event := range service.StreamResponseOpenAI2Responses(response, info) {
+			err = helper.ResponsesData(c, event)
+			if err != nil {
+				common.LogError(c, "send_stream_response_failed: "+err.Error())
+			}
+		}
 	}
 	return nil
 }
@@ -671,6 +701,17 @@ func HandleStreamFinalResponse(c *gin.Context, info *relaycommon.RelayInfo, clau
 			}
 		}
 		helper.Done(c)
+	} else if info.RelayFormat == relaycommon.RelayFormatGemini {
+		info.GeminiConvertInfo.Done = true
+		geminiResponse := service.StreamResponseOpenAI2Gemini(&dto.ChatCompletionsStreamResponse{Usage: claudeInfo.Usage}, info)
+		if geminiResponse != nil {
+			_ = helper.ObjectData(c, geminiResponse)
+		}
+	} else if info.RelayFormat == relaycommon.RelayFormatOpenAIResponses {
+		info.ResponsesConvertInfo.Done = true
+		for _, event := range service.StreamResponseOpenAI2Responses(&dto.ChatCompletionsStreamResponse{Usage: claudeInfo.Usage}, info) {
+			_ = helper.ResponsesData(c, event)
+		}
 	}
 }
 
@@ -730,6 +771,20 @@ func HandleClaudeResponseData(c *gin.Context, info *relaycommon.RelayInfo, claud
 		}
 	case relaycommon.RelayFormatClaude:
 		responseData = data
+	case relaycommon.RelayFormatGemini:
+		openaiResponse := ResponseClaude2OpenAI(requestMode, &claudeResponse)
+		openaiResponse.Usage = *claudeInfo.Usage
+		responseData, err = json.Marshal(service.ResponseOpenAI2Gemini(openaiResponse, info))
+		if err != nil {
+			return types.NewError(err, types.ErrorCodeBadResponseBody)
+		}
+	case relaycommon.RelayFormatOpenAIResponses:
+		openaiResponse := ResponseClaude2OpenAI(requestMode, &claudeResponse)
+		openaiResponse.Usage = *claudeInfo.Usage
+		responseData, err = json.Marshal(service.ResponseOpenAI2Responses(openaiResponse, info))
+		if err != nil {
+			return types.NewError(err, types.ErrorCodeBadResponseBody)
+		}
 	}
 
 	if claudeResponse.Usage.ServerToolUse != nil && claudeResponse.Usage.ServerToolUse.WebSearchRequests > 0 {
@@ -0,0 +1,207 @@
+package channel
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	common2 "one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+	"one-api/types"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrClaudeRequestNotSupported = errors.New("this channel does not support claude messages request")
+
+// claudeUnsupportedApiTypes 不提供对话能力的渠道，无法处理 Claude Messages 请求
+var claudeUnsupportedApiTypes = map[int]bool{
+	constant.APITypeJina:   true,
+	constant.APITypeMokaAI: true,
+	constant.APITypeJimeng: true,
+}
+
+// SupportClaudeRequest 渠道能否处理 /v1/messages 请求（原生支持或经 OpenAI 通用兜底）
+func SupportClaudeRequest(channelType int) bool {
+	apiType, ok := common2.ChannelType2APIType(channelType)
+	if !ok {
+		return false
+	}
+	return !claudeUnsupportedApiTypes[apiType]
+}
+
+// ConvertClaudeRequestByOpenAI Claude 请求的通用兜底：转换为 OpenAI 请求后走适配器的 OpenAI 路径，
+// 响应由 openai 处理器按 RelayFormatClaude 转换回 Claude 格式，私有处理器的响应经 ClaudeResponseWriter 转换
+func ConvertClaudeRequestByOpenAI(a Adaptor, c *gin.Context, info *common.RelayInfo, request *dto.ClaudeRequest) (any, error) {
+	aiRequest, err := service.ClaudeToOpenAIRequest(*request, info)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsStream && info.SupportStreamOptions {
+		aiRequest.StreamOptions = &dto.StreamOptions{
+			IncludeUsage: true,
+		}
+	}
+	// 适配器按 chat completions 拼接请求地址和选择响应处理器
+	info.RelayMode = relayconstant.RelayModeChatCompletions
+	info.RequestURLPath = "/v1/chat/completions"
+	return a.ConvertOpenAIRequest(c, info, aiRequest)
+}
+
+// ClaudeResponseWriter 将渠道私有处理器输出的 OpenAI 格式响应转换为 Claude 格式，
+// 流式响应逐个分片转换，非流式响应缓冲后整体转换
+type ClaudeResponseWriter struct {
+	gin.ResponseWriter
+	c          *gin.Context
+	info       *common.RelayInfo
+	buffer     bytes.Buffer
+	statusCode int
+}
+
+// NewClaudeResponseWriter 替换 c.Writer，处理器返回后必须调用 Finish 输出剩余内容并恢复 c.Writer
+func NewClaudeResponseWriter(c *gin.Context, info *common.RelayInfo) *ClaudeResponseWriter {
+	w := &ClaudeResponseWriter{
+		ResponseWriter: c.Writer,
+		c:              c,
+		info:           info,
+		statusCode:     http.StatusOK,
+	}
+	c.Writer = w
+	return w
+}
+
+func (w *ClaudeResponseWriter) WriteHeader(code int) {
+	if w.info.IsStream {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.statusCode = code
+}
+
+func (w *ClaudeResponseWriter) WriteHeaderNow() {
+	if w.info.IsStream {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *ClaudeResponseWriter) Flush() {
+	if w.info.IsStream {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *ClaudeResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *ClaudeResponseWriter) Write(data []byte) (int, error) {
+	w.buffer.Write(data)
+	if !w.info.IsStream {
+		return len(data), nil
+	}
+	for {
+		line, err := w.buffer.ReadString('\n')
+		if err != nil {
+			// 不完整的行留待下次写入
+			w.buffer.Reset()
+			w.buffer.WriteString(line)
+			break
+		}
+		w.handleStreamLine(strings.TrimSpace(line))
+	}
+	return len(data), nil
+}
+
+func (w *ClaudeResponseWriter) handleStreamLine(line string) {
+	if !strings.HasPrefix(line, "data:") {
+		return
+	}
+	data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+	if data == "" || data == "[DONE]" {
+		return
+	}
+	var streamResponse dto.ChatCompletionsStreamResponse
+	if err := common2.UnmarshalJsonStr(data, &streamResponse); err != nil {
+		common2.SysError("error unmarshalling stream response: " + err.Error())
+		return
+	}
+	w.sendStreamResponse(&streamResponse)
+}
+
+func (w *ClaudeResponseWriter) sendStreamResponse(streamResponse *dto.ChatCompletionsStreamResponse) {
+	if streamResponse.Usage != nil {
+		w.info.ClaudeConvertInfo.Usage = streamResponse.Usage
+	}
+	w.info.SendResponseCount++
+	if w.info.SendResponseCount == 1 {
+		// 首个分片只生成 message_start，分片本身的内容需要再转换一次
+		w.writeClaudeResponses(service.StreamResponseOpenAI2Claude(streamResponse, w.info))
+		w.info.SendResponseCount++
+	}
+	if len(streamResponse.Choices) > 0 {
+		choice := streamResponse.Choices[0]
+		// 带结束原因的分片不会输出内容，先单独转换其中的内容
+		if choice.FinishReason != nil && (choice.Delta.GetContentString() != "" || choice.Delta.GetReasoningContent() != "" || len(choice.Delta.ToolCalls) > 0) {
+			contentResponse := *streamResponse
+			choice.FinishReason = nil
+			contentResponse.Choices = []dto.ChatCompletionsStreamResponseChoice{choice}
+			w.writeClaudeResponses(service.StreamResponseOpenAI2Claude(&contentResponse, w.info))
+		}
+	}
+	w.writeClaudeResponses(service.StreamResponseOpenAI2Claude(streamResponse, w.info))
+}
+
+func (w *ClaudeResponseWriter) writeClaudeResponses(claudeResponses []*dto.ClaudeResponse) {
+	for _, resp := range claudeResponses {
+		jsonData, err := common2.Marshal(resp)
+		if err != nil {
+			common2.SysError("error marshalling claude response: " + err.Error())
+			continue
+		}
+		_, _ = fmt.Fprintf(w.ResponseWriter, "event: %s\ndata: %s\n\n", resp.Type, jsonData)
+	}
+	w.ResponseWriter.Flush()
+}
+
+// Finish 恢复 c.Writer 并输出转换后的剩余内容，处理器出错时由调用方按 Claude 格式返回错误
+func (w *ClaudeResponseWriter) Finish(usage any, apiErr *types.NewAPIError) {
+	w.c.Writer = w.ResponseWriter
+	if apiErr != nil {
+		return
+	}
+	openaiUsage, _ := usage.(*dto.Usage)
+	if w.info.IsStream {
+		if line := strings.TrimSpace(w.buffer.String()); line != "" {
+			w.handleStreamLine(line)
+		}
+		w.info.ClaudeConvertInfo.Done = true
+		if openaiUsage != nil {
+			w.info.ClaudeConvertInfo.Usage = openaiUsage
+		}
+		w.sendStreamResponse(&dto.ChatCompletionsStreamResponse{
+			Choices: []dto.ChatCompletionsStreamResponseChoice{{}},
+		})
+		return
+	}
+	var openaiResponse dto.OpenAITextResponse
+	body := w.buffer.Bytes()
+	if err := common2.Unmarshal(body, &openaiResponse); err != nil {
+		common2.SysError("error unmarshalling response: " + err.Error())
+	} else {
+		if openaiUsage != nil {
+			openaiResponse.Usage = *openaiUsage
+		}
+		if claudeBody, err := common2.Marshal(service.ResponseOpenAI2Claude(&openaiResponse, w.info)); err == nil {
+			body = claudeBody
+		}
+	}
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "application/json")
+	w.ResponseWriter.WriteHeader(w.statusCode)
+	_, _ = w.ResponseWriter.Write(body)
+}
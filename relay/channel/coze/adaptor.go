@@ -25,7 +25,7 @@ func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *common.RelayInfo, re
 
 // ConvertClaudeRequest implements channel.Adaptor.
 func (a *Adaptor) ConvertClaudeRequest(c *gin.Context, info *common.RelayInfo, request *dto.ClaudeRequest) (any, error) {
-	return nil, errors.New("not implemented")
+	return channel.ConvertClaudeRequestByOpenAI(a, c, info, request)
 }
 
 // ConvertEmbeddingRequest implements channel.Adaptor.
@@ -97,6 +97,12 @@ func (a *Adaptor) DoRequest(c *gin.Context, info *common.RelayInfo, requestBody
 
 // DoResponse implements channel.Adaptor.
 func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *common.RelayInfo) (usage any, err *types.NewAPIError) {
+	if info.RelayFormat == common.RelayFormatClaude {
+		claudeWriter := channel.NewClaudeResponseWriter(c, info)
+		defer func() {
+			claudeWriter.Finish(usage, err)
+		}()
+	}
 	if info.IsStream {
 		usage, err = cozeChatStreamHandler(c, info, resp)
 	} else {
@@ -0,0 +1,32 @@
+package channel
+
+import (
+	common2 "one-api/common"
+	"one-api/constant"
+)
+
+// geminiUnsupportedApiTypes 响应由渠道私有处理器直接输出为 OpenAI 格式，不经过按 RelayFormatGemini 的转换，无法转换回 Gemini 格式
+var geminiUnsupportedApiTypes = map[int]bool{
+	constant.APITypePaLM:       true,
+	constant.APITypeBaidu:      true,
+	constant.APITypeZhipu:      true,
+	constant.APITypeXunfei:     true,
+	constant.APITypeTencent:    true,
+	constant.APITypeCohere:     true,
+	constant.APITypeDify:       true,
+	constant.APITypeJina:       true,
+	constant.APITypeCloudflare: true,
+	constant.APITypeMokaAI:     true,
+	constant.APITypeXai:        true,
+	constant.APITypeCoze:       true,
+	constant.APITypeJimeng:     true,
+}
+
+// SupportGeminiRequest 渠道能否处理 Gemini 原生请求（Gemini、Vertex AI 原生支持，其余渠道转换为 chat completions）
+func SupportGeminiRequest(channelType int) bool {
+	apiType, ok := common2.ChannelType2APIType(channelType)
+	if !ok {
+		return false
+	}
+	return !geminiUnsupportedApiTypes[apiType]
+}
@@ -21,6 +21,10 @@ func handleStreamFormat(c *gin.Context, info *relaycommon.RelayInfo, data string
 		return sendStreamData(c, info, data, forceFormat, thinkToContent)
 	case relaycommon.RelayFormatClaude:
 		return handleClaudeFormat(c, data, info)
+	case relaycommon.RelayFormatGemini:
+		return handleGeminiFormat(c, data, info)
+	case relaycommon.RelayFormatOpenAIResponses:
+		return handleResponsesFormat(c, data, info)
 	}
 	return nil
 }
@@ -41,6 +45,33 @@ func handleClaudeFormat(c *gin.Context, data string, info *relaycommon.RelayInfo
 	return nil
 }
 
+func handleGeminiFormat(c *gin.Context, data string, info *relaycommon.RelayInfo) error {
+	var streamResponse dto.ChatCompletionsStreamResponse
+	if err := json.Unmarshal(common.StringToByteSlice(data), &streamResponse); err != nil {
+		return err
+	}
+
+	geminiResponse := service.StreamResponseOpenAI2Gemini(&streamResponse, info)
+	if geminiResponse != nil {
+		return helper.ObjectData(c, geminiResponse)
+	}
+	return nil
+}
+
+func handleResponsesFormat(c *gin.Context, data string, info *relaycommon.RelayInfo) error {
+	var streamResponse dto.ChatCompletionsStreamResponse
+	if err := json.Unmarshal(common.StringToByteSlice(data), &streamResponse); err != nil {
+		return err
+	}
+
+	for _, event := range service.StreamResponseOpenAI2Responses(&streamResponse, info) {
+		if err := helper.ResponsesData(c, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func ProcessStreamResponse(streamResponse dto.ChatCompletionsStreamResponse, responseTextBuilder *strings.Builder, toolCount *int) error {
 	for _, choice := range streamResponse.Choices {
 		responseTextBuilder.WriteString(choice.Delta.GetContentString())
@@ -185,6 +216,34 @@ func handleFinalResponse(c *gin.Context, info *relaycommon.RelayInfo, lastStream
 		for _, resp := range claudeResponses {
 			helper.ClaudeData(c, *resp)
 		}
+
+	case relaycommon.RelayFormatGemini:
+		info.GeminiConvertInfo.Done = true
+		var streamResponse dto.ChatCompletionsStreamResponse
+		if lastStreamData != "" {
+			if err := json.Unmarshal(common.StringToByteSlice(lastStreamData), &streamResponse); err != nil {
+				common.SysError("error unmarshalling stream response: " + err.Error())
+			}
+		}
+		// 最后一个分片带上最终的 usage
+		streamResponse.Usage = usage
+		geminiResponse := service.StreamResponseOpenAI2Gemini(&streamResponse, info)
+		if geminiResponse != nil {
+			_ = helper.ObjectData(c, geminiResponse)
+		}
+
+	case relaycommon.RelayFormatOpenAIResponses:
+		info.ResponsesConvertInfo.Done = true
+		var streamResponse dto.ChatCompletionsStreamResponse
+		if lastStreamData != "" {
+			if err := json.Unmarshal(common.StringToByteSlice(lastStreamData), &streamResponse); err != nil {
+				common.SysError("error unmarshalling stream response: " + err.Error())
+			}
+		}
+		streamResponse.Usage = usage
+		for _, event := range service.StreamResponseOpenAI2Responses(&streamResponse, info) {
+			_ = helper.ResponsesData(c, event)
+		}
 	}
 }
 
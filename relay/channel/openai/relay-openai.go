@@ -30,6 +30,10 @@ func sendStreamData(c *gin.Context, info *relaycommon.RelayInfo, data string, fo
 		return nil
 	}
 
+	if info.StreamResume != nil {
+		data = stitchResumedStreamData(info, data)
+	}
+
 	if !forceFormat && !thinkToContent {
 		return helper.StringData(c, data)
 	}
@@ -177,7 +181,13 @@ func OaiStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Re
 		}
 	}
 
-	handleFinalResponse(c, info, lastStreamData, responseId, createAt, model, systemFingerprint, usage, containStreamUsage)
+	// 上游中途中断且可以续写时不结束流，等待换渠道续写
+	if info.StreamInterrupted && info.StreamResumable && prepareStreamResume(info, streamItems) {
+		info.StreamResumePending = true
+		return usage, nil
+	}
+
+	handleFinalResponse(c, info, lastStreamData, responseId, createAt, model, systemFingerprint, resumedStreamUsage(info, usage), containStreamUsage)
 
 	return usage, nil
 }
@@ -233,6 +243,20 @@ func OpenaiHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Respo
 			return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
 		}
 		responseBody = claudeRespStr
+	case relaycommon.RelayFormatGemini:
+		geminiResp := service.ResponseOpenAI2Gemini(&simpleResponse, info)
+		geminiRespStr, err := common.Marshal(geminiResp)
+		if err != nil {
+			return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+		}
+		responseBody = geminiRespStr
+	case relaycommon.RelayFormatOpenAIResponses:
+		responsesResp := service.ResponseOpenAI2Responses(&simpleResponse, info)
+		responsesRespStr, err := common.Marshal(responsesResp)
+		if err != nil {
+			return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+		}
+		responseBody = responsesRespStr
 	}
 
 	common.IOCopyBytesGracefully(c, resp, responseBody)
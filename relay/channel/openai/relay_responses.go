@@ -1,6 +1,7 @@
 package openai
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -34,6 +35,7 @@ func OaiResponsesHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http
 
 	// 写入新的 response body
 	common.IOCopyBytesGracefully(c, resp, responseBody)
+	info.ResponsesResult = responseBody
 
 	// compute usage
 	usage := dto.Usage{}
@@ -64,6 +66,12 @@ func OaiResponsesStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 			sendResponsesStreamData(c, streamResponse, data)
 			switch streamResponse.Type {
 			case "response.completed":
+				var completed struct {
+					Response json.RawMessage `json:"response"`
+				}
+				if err := common.UnmarshalJsonStr(data, &completed); err == nil {
+					info.ResponsesResult = completed.Response
+				}
 				usage.PromptTokens = streamResponse.Response.Usage.InputTokens
 				usage.CompletionTokens = streamResponse.Response.Usage.OutputTokens
 				usage.TotalTokens = streamResponse.Response.Usage.TotalTokens
@@ -0,0 +1,67 @@
+package openai
+
+import (
+	"one-api/common"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"strings"
+)
+
+// prepareStreamResume 记录本段已输出的助手内容，返回是否可以续写
+func prepareStreamResume(info *relaycommon.RelayInfo, streamItems []string) bool {
+	var content strings.Builder
+	var responseId string
+	var created int64
+	for _, item := range streamItems {
+		var streamResponse dto.ChatCompletionsStreamResponse
+		if err := common.UnmarshalJsonStr(item, &streamResponse); err != nil {
+			return false
+		}
+		if responseId == "" {
+			responseId = streamResponse.Id
+			created = streamResponse.Created
+		}
+		for _, choice := range streamResponse.Choices {
+			// 工具调用、多个候选或已经正常结束的响应不续写
+			if choice.Index != 0 || len(choice.Delta.ToolCalls) > 0 || (choice.FinishReason != nil && *choice.FinishReason != "") {
+				return false
+			}
+			content.WriteString(choice.Delta.GetContentString())
+		}
+	}
+	if info.StreamResume == nil {
+		info.StreamResume = &relaycommon.StreamResumeInfo{ResponseId: responseId, Created: created}
+	}
+	info.StreamResume.Content += content.String()
+	return true
+}
+
+// resumedStreamUsage 续写段返回给客户端的 usage 合并之前各段的输出
+func resumedStreamUsage(info *relaycommon.RelayInfo, usage *dto.Usage) *dto.Usage {
+	if info.StreamResume == nil || usage == nil {
+		return usage
+	}
+	resumed := *usage
+	resumed.PromptTokens = info.PromptTokens
+	resumed.CompletionTokens += info.StreamResume.CompletionTokens
+	resumed.TotalTokens = resumed.PromptTokens + resumed.CompletionTokens
+	return &resumed
+}
+
+// stitchResumedStreamData 续写段的分片沿用首段的 id 和创建时间，拼接成同一个响应
+func stitchResumedStreamData(info *relaycommon.RelayInfo, data string) string {
+	var streamResponse dto.ChatCompletionsStreamResponse
+	if err := common.UnmarshalJsonStr(data, &streamResponse); err != nil {
+		return data
+	}
+	if info.StreamResume.ResponseId != "" {
+		streamResponse.Id = info.StreamResume.ResponseId
+		streamResponse.Created = info.StreamResume.Created
+	}
+	streamResponse.Usage = resumedStreamUsage(info, streamResponse.Usage)
+	jsonData, err := common.Marshal(streamResponse)
+	if err != nil {
+		return data
+	}
+	return string(jsonData)
+}
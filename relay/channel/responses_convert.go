@@ -0,0 +1,56 @@
+package channel
+
+import (
+	common2 "one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responsesUnsupportedApiTypes 响应由渠道私有处理器直接输出为 OpenAI 格式，无法转换回 Responses 格式
+var responsesUnsupportedApiTypes = map[int]bool{
+	constant.APITypePaLM:       true,
+	constant.APITypeBaidu:      true,
+	constant.APITypeZhipu:      true,
+	constant.APITypeXunfei:     true,
+	constant.APITypeTencent:    true,
+	constant.APITypeCohere:     true,
+	constant.APITypeDify:       true,
+	constant.APITypeJina:       true,
+	constant.APITypeCloudflare: true,
+	constant.APITypeMokaAI:     true,
+	constant.APITypeXai:        true,
+	constant.APITypeCoze:       true,
+	constant.APITypeJimeng:     true,
+}
+
+// SupportResponsesRequest 渠道能否处理 /v1/responses 请求（原生支持或转换为 chat completions）
+func SupportResponsesRequest(channelType int) bool {
+	apiType, ok := common2.ChannelType2APIType(channelType)
+	if !ok {
+		return false
+	}
+	return !responsesUnsupportedApiTypes[apiType]
+}
+
+// ConvertResponsesRequestByOpenAI 非原生渠道的 /v1/responses 请求转换为 chat completions 请求后走适配器的 OpenAI 路径，
+// 响应由各处理器按 RelayFormatOpenAIResponses 转换回 Responses 格式
+func ConvertResponsesRequestByOpenAI(a Adaptor, c *gin.Context, info *common.RelayInfo, request *dto.OpenAIResponsesRequest) (any, error) {
+	aiRequest, err := service.ResponsesToOpenAIRequest(request, info)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsStream && info.SupportStreamOptions {
+		aiRequest.StreamOptions = &dto.StreamOptions{
+			IncludeUsage: true,
+		}
+	}
+	// 适配器按 chat completions 拼接请求地址和选择响应处理器
+	info.RelayMode = relayconstant.RelayModeChatCompletions
+	info.RequestURLPath = "/v1/chat/completions"
+	return a.ConvertOpenAIRequest(c, info, aiRequest)
+}
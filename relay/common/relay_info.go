@@ -33,6 +33,28 @@ type ClaudeConvertInfo struct {
 	Done             bool
 }
 
+// GeminiConvertInfo 非 Gemini 渠道以 Gemini 原生格式响应时的流式转换状态
+type GeminiConvertInfo struct {
+	ToolCalls    []dto.ToolCallResponse // 流式工具调用参数分片累积，结束时一次性输出
+	FinishReason string
+	Done         bool
+}
+
+// ResponsesConvertInfo 非原生渠道处理 /v1/responses 时的转换状态
+type ResponsesConvertInfo struct {
+	Request        *dto.OpenAIResponsesRequest
+	ResponseId     string
+	CreatedAt      int64
+	SequenceNumber int
+	Started        bool
+	Done           bool
+	Output         []dto.ResponsesOutput
+	OpenItem       int         // 当前正在输出的 output 下标，-1 表示没有
+	ToolCallItems  map[int]int // chat 工具调用下标 -> output 下标
+	FinishReason   string
+	Usage          *dto.Usage
+}
+
 const (
 	RelayFormatOpenAI          = "openai"
 	RelayFormatClaude          = "claude"
@@ -59,22 +81,44 @@ type ResponsesUsageInfo struct {
 	BuiltInTools map[string]*BuildInToolInfo
 }
 
+// StreamResumeInfo 流式续写状态，首段中断时创建，跨渠道续写时保存在上下文中
+type StreamResumeInfo struct {
+	ResponseId       string // 首段的响应 id，续写的分片沿用
+	Created          int64
+	Content          string // 已输出给客户端的助手内容
+	CompletionTokens int    // 之前各段已计费的输出 tokens
+	Resumes          int    // 已中断并续写的次数
+}
+
+// PricingTierInfo 本次请求命中的阶梯或时段价格
+type PricingTierInfo struct {
+	MinPromptTokens *int    `json:"min_prompt_tokens,omitempty"` // 命中的提示阶梯起点
+	Schedule        string  `json:"schedule,omitempty"`          // 命中的时段名称
+	Multiplier      float64 `json:"multiplier"`                  // 时段乘数
+}
+
 type RelayInfo struct {
 	ChannelType       int
 	ChannelId         int
+	ChannelKeyIndex   int // 多 Key 渠道使用的 Key 索引，单 Key 渠道为 -1
 	TokenId           int
 	TokenKey          string
 	UserId            int64
+	OrganizationId    int    // 使用组织令牌时的组织，消费从组织额度池扣除
 	UsingGroup        string // 使用的分组
 	UserGroup         string // 用户所在分组
 	TokenUnlimited    bool
 	StartTime         time.Time
 	FirstResponseTime time.Time
+	AttemptStartTime  time.Time // 本次渠道请求的开始时间，重试时重新计时
 	isFirstResponse   bool
 	//SendLastReasoningResponse bool
 	ApiType           int
 	IsStream          bool
 	IsPlayground      bool
+	IsBatch           bool // 来自 /v1/batches 的离线请求，按批处理折扣计费
+	ResponseCacheHit  bool // 命中响应缓存，未请求上游，按命中倍率计费
+	BatchSettle       bool // 上游批处理完成后的离线结算，不计入渠道实时统计
 	UsePrice          bool
 	RelayMode         int
 	UpstreamModelName string
@@ -107,6 +151,14 @@ type RelayInfo struct {
 	ChannelCreateTime    int64
 	ThinkingContentInfo
 	*ClaudeConvertInfo
+	GeminiConvertInfo    *GeminiConvertInfo
+	ResponsesConvertInfo *ResponsesConvertInfo
+	ResponsesResult      []byte // 最终的 Responses 响应对象，用于本地保存会话状态
+	StreamResumable      bool   // 流式输出中断后可换渠道续写
+	StreamInterrupted    bool   // 上游流式响应中途断开或超时
+	StreamResumePending  bool   // 本段已中断且满足续写条件，等待换渠道续写
+	StreamResume         *StreamResumeInfo
+	PricingTier          *PricingTierInfo // 阶梯或时段价格，未命中时为 nil
 	*RerankerInfo
 	*ResponsesUsageInfo
 }
@@ -126,6 +178,12 @@ var streamSupportedChannels = map[int]bool{
 	constant.ChannelTypeBaiduV2:    true,
 }
 
+// 原生支持 /v1/responses 的渠道，其余渠道转换为 chat completions 请求
+var responsesNativeChannels = map[int]bool{
+	constant.ChannelTypeOpenAI: true,
+	constant.ChannelTypeAzure:  true,
+}
+
 func GenRelayInfoWs(c *gin.Context, ws *websocket.Conn) *RelayInfo {
 	info := GenRelayInfo(c)
 	info.ClientWs = ws
@@ -173,7 +231,17 @@ func GenRelayInfoResponses(c *gin.Context, req *dto.OpenAIResponsesRequest) *Rel
 	info.RelayMode = relayconstant.RelayModeResponses
 	info.RelayFormat = RelayFormatOpenAIResponses
 
-	info.SupportStreamOptions = false
+	if responsesNativeChannels[info.ChannelType] {
+		info.SupportStreamOptions = false
+	} else {
+		info.ResponsesConvertInfo = &ResponsesConvertInfo{
+			Request:       req,
+			ResponseId:    "resp_" + common.GetRandomString(32),
+			CreatedAt:     common.GetTimestamp(),
+			OpenItem:      -1,
+			ToolCallItems: make(map[int]int),
+		}
+	}
 
 	info.ResponsesUsageInfo = &ResponsesUsageInfo{
 		BuiltInTools: make(map[string]*BuildInToolInfo),
@@ -203,6 +271,7 @@ func GenRelayInfoGemini(c *gin.Context) *RelayInfo {
 	info := GenRelayInfo(c)
 	info.RelayFormat = RelayFormatGemini
 	info.ShouldIncludeUsage = false
+	info.GeminiConvertInfo = &GeminiConvertInfo{}
 	return info
 }
 
@@ -238,11 +307,14 @@ func GenRelayInfo(c *gin.Context) *RelayInfo {
 		TokenId:           tokenId,
 		TokenKey:          tokenKey,
 		UserId:            userId,
+		OrganizationId:    common.GetContextKeyInt(c, constant.ContextKeyOrganizationId),
 		UsingGroup:        common.GetContextKeyString(c, constant.ContextKeyUsingGroup),
 		UserGroup:         common.GetContextKeyString(c, constant.ContextKeyUserGroup),
 		TokenUnlimited:    tokenUnlimited,
+		IsBatch:           common.GetContextKeyBool(c, constant.ContextKeyBatchRequest),
 		StartTime:         startTime,
 		FirstResponseTime: startTime.Add(-time.Second),
+		AttemptStartTime:  time.Now(),
 		OriginModelName:   common.GetContextKeyString(c, constant.ContextKeyOriginalModel),
 		UpstreamModelName: common.GetContextKeyString(c, constant.ContextKeyOriginalModel),
 		//RecodeModelName:   c.GetString("original_model"),
@@ -260,6 +332,10 @@ func GenRelayInfo(c *gin.Context) *RelayInfo {
 			SendLastThinkingContent: false,
 		},
 	}
+	info.ChannelKeyIndex = -1
+	if common.GetContextKeyBool(c, constant.ContextKeyChannelIsMultiKey) {
+		info.ChannelKeyIndex = common.GetContextKeyInt(c, constant.ContextKeyChannelMultiKeyIndex)
+	}
 	if strings.HasPrefix(c.Request.URL.Path, "/pg") {
 		info.IsPlayground = true
 		info.RequestURLPath = strings.TrimPrefix(info.RequestURLPath, "/pg")
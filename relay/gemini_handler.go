@@ -7,9 +7,12 @@ import (
 	"fmt"
 	"net/http"
 	"one-api/common"
+	"one-api/constant"
 	"one-api/dto"
+	"one-api/relay/channel"
 	"one-api/relay/channel/gemini"
 	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
 	"one-api/relay/helper"
 	"one-api/service"
 	"one-api/setting"
@@ -20,8 +23,8 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func getAndValidateGeminiRequest(c *gin.Context) (*gemini.GeminiChatRequest, error) {
-	request := &gemini.GeminiChatRequest{}
+func getAndValidateGeminiRequest(c *gin.Context) (*dto.GeminiChatRequest, error) {
+	request := &dto.GeminiChatRequest{}
 	err := common.UnmarshalBodyReusable(c, request)
 	if err != nil {
 		return nil, err
@@ -44,7 +47,7 @@ func checkGeminiStreamMode(c *gin.Context, relayInfo *relaycommon.RelayInfo) {
 	// }
 }
 
-func checkGeminiInputSensitive(textRequest *gemini.GeminiChatRequest) ([]string, error) {
+func checkGeminiInputSensitive(textRequest *dto.GeminiChatRequest) ([]string, error) {
 	var inputTexts []string
 	for _, content := range textRequest.Contents {
 		for _, part := range content.Parts {
@@ -61,7 +64,7 @@ func checkGeminiInputSensitive(textRequest *gemini.GeminiChatRequest) ([]string,
 	return sensitiveWords, err
 }
 
-func getGeminiInputTokens(req *gemini.GeminiChatRequest, info *relaycommon.RelayInfo) int {
+func getGeminiInputTokens(req *dto.GeminiChatRequest, info *relaycommon.RelayInfo) int {
 	// 计算输入 token 数量
 	var inputTexts []string
 	for _, content := range req.Contents {
@@ -78,7 +81,7 @@ func getGeminiInputTokens(req *gemini.GeminiChatRequest, info *relaycommon.Relay
 	return inputTokens
 }
 
-func isNoThinkingRequest(req *gemini.GeminiChatRequest) bool {
+func isNoThinkingRequest(req *dto.GeminiChatRequest) bool {
 	if req.GenerationConfig.ThinkingConfig != nil && req.GenerationConfig.ThinkingConfig.ThinkingBudget != nil {
 		return *req.GenerationConfig.ThinkingConfig.ThinkingBudget <= 0
 	}
@@ -105,6 +108,28 @@ func trimModelThinking(modelName string) string {
 	return modelName
 }
 
+func isGeminiNativeApiType(apiType int) bool {
+	return apiType == constant.APITypeGemini || apiType == constant.APITypeVertexAi
+}
+
+// convertGeminiRequest 非 Gemini 渠道先转换为 OpenAI 请求，再交给渠道适配器，响应按 RelayFormatGemini 转回
+func convertGeminiRequest(c *gin.Context, adaptor channel.Adaptor, info *relaycommon.RelayInfo, req *dto.GeminiChatRequest) ([]byte, error) {
+	openAIRequest, err := service.GeminiToOpenAIRequest(req, info)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsStream && info.SupportStreamOptions {
+		openAIRequest.StreamOptions = &dto.StreamOptions{
+			IncludeUsage: true,
+		}
+	}
+	convertedRequest, err := adaptor.ConvertOpenAIRequest(c, info, openAIRequest)
+	if err != nil {
+		return nil, err
+	}
+	return common.Marshal(convertedRequest)
+}
+
 func GeminiHelper(c *gin.Context) (newAPIError *types.NewAPIError) {
 	req, err := getAndValidateGeminiRequest(c)
 	if err != nil {
@@ -113,6 +138,9 @@ func GeminiHelper(c *gin.Context) (newAPIError *types.NewAPIError) {
 	}
 
 	relayInfo := relaycommon.GenRelayInfoGemini(c)
+	if !channel.SupportGeminiRequest(relayInfo.ChannelType) {
+		return types.NewError(fmt.Errorf("channel type %d does not support gemini request", relayInfo.ChannelType), types.ErrorCodeInvalidApiType)
+	}
 
 	// 检查 Gemini 流式模式
 	checkGeminiStreamMode(c, relayInfo)
@@ -138,6 +166,9 @@ func GeminiHelper(c *gin.Context) (newAPIError *types.NewAPIError) {
 		promptTokens := getGeminiInputTokens(req, relayInfo)
 		c.Set("prompt_tokens", promptTokens)
 	}
+	if newAPIError := checkChannelContextWindow(c, relayInfo.PromptTokens); newAPIError != nil {
+		return newAPIError
+	}
 
 	if model_setting.GetGeminiSettings().ThinkingAdapterEnabled {
 		if isNoThinkingRequest(req) {
@@ -178,6 +209,11 @@ func GeminiHelper(c *gin.Context) (newAPIError *types.NewAPIError) {
 		return types.NewError(fmt.Errorf("invalid api type: %d", relayInfo.ApiType), types.ErrorCodeInvalidApiType)
 	}
 
+	if !isGeminiNativeApiType(relayInfo.ApiType) {
+		// 按 chat completions 请求上游
+		relayInfo.RelayMode = relayconstant.RelayModeChatCompletions
+		relayInfo.RequestURLPath = "/v1/chat/completions"
+	}
 	adaptor.Init(relayInfo)
 
 	// Clean up empty system instruction
@@ -194,7 +230,12 @@ func GeminiHelper(c *gin.Context) (newAPIError *types.NewAPIError) {
 		}
 	}
 
-	requestBody, err := json.Marshal(req)
+	var requestBody []byte
+	if isGeminiNativeApiType(relayInfo.ApiType) {
+		requestBody, err = json.Marshal(req)
+	} else {
+		requestBody, err = convertGeminiRequest(c, adaptor, relayInfo, req)
+	}
 	if err != nil {
 		return types.NewError(err, types.ErrorCodeConvertRequestFailed)
 	}
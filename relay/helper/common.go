@@ -61,6 +61,16 @@ func ResponseChunkData(c *gin.Context, resp dto.ResponsesStreamResponse, data st
 	}
 }
 
+// ResponsesData 发送转换得到的 /v1/responses 流式事件
+func ResponsesData(c *gin.Context, resp dto.ResponsesStreamResponse) error {
+	jsonData, err := common.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("error marshalling responses event: %w", err)
+	}
+	ResponseChunkData(c, resp, string(jsonData))
+	return nil
+}
+
 func StringData(c *gin.Context, str string) error {
 	//str = strings.TrimPrefix(str, "data: ")
 	//str = strings.TrimSuffix(str, "\r")
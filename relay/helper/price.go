@@ -3,10 +3,13 @@ package helper
 import (
 	"fmt"
 	"one-api/common"
+	"one-api/constant"
 	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
 	"one-api/setting/ratio_setting"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
 )
 
 type GroupRatioInfo struct {
@@ -25,6 +28,9 @@ type PriceData struct {
 	UsePrice               bool
 	ShouldPreConsumedQuota int
 	GroupRatioInfo         GroupRatioInfo
+	PricingTier            *relaycommon.PricingTierInfo
+	// 精确计费价格，已计入微调和时段倍率，缓存价格已补全，未使用精确计费时为 nil
+	ExactPrice *operation_setting.ExactModelPrice
 }
 
 func (p PriceData) ToSetting() string {
@@ -63,16 +69,55 @@ func HandleGroupRatio(ctx *gin.Context, relayInfo *relaycommon.RelayInfo) GroupR
 }
 
 func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens int, maxTokens int) (PriceData, error) {
-	modelPrice, usePrice := ratio_setting.GetModelPrice(info.OriginModelName, false)
+	priceModelName := info.OriginModelName
+	// 用户私有的微调模型按基础模型定价，再乘以微调倍率
+	fineTuneBaseModel := common.GetContextKeyString(c, constant.ContextKeyFineTuneBase)
+	fineTuneRatio := 1.0
+	if fineTuneBaseModel != "" {
+		priceModelName = fineTuneBaseModel
+		fineTuneRatio = operation_setting.GetFineTuneSetting().ModelRatio
+	}
+	modelPrice, usePrice := ratio_setting.GetModelPrice(priceModelName, false)
+	modelPrice *= fineTuneRatio
+
+	// 阶梯价格按预估的提示 tokens 选档，结算时由 RematchPromptTier 按实际用量重新选档；时段按请求开始时间匹配
+	var promptTier *operation_setting.PromptPriceTier
+	var schedule *operation_setting.SchedulePriceWindow
+	if tiers := operation_setting.GetModelPricingTiers(priceModelName); tiers != nil {
+		promptTier = tiers.MatchPromptTier(promptTokens)
+		schedule = tiers.MatchSchedule(info.StartTime)
+	}
+	if promptTier != nil {
+		if promptTier.ModelPrice != nil {
+			modelPrice = *promptTier.ModelPrice * fineTuneRatio
+			usePrice = true
+		} else if promptTier.ModelRatio != nil {
+			usePrice = false
+		}
+	}
 
 	groupRatioInfo := HandleGroupRatio(c, info)
 
+	var pricingTier *relaycommon.PricingTierInfo
+	if promptTier != nil || schedule != nil {
+		pricingTier = &relaycommon.PricingTierInfo{Multiplier: 1}
+		if promptTier != nil {
+			pricingTier.MinPromptTokens = &promptTier.MinPromptTokens
+		}
+		if schedule != nil {
+			pricingTier.Schedule = schedule.Name
+			pricingTier.Multiplier = schedule.Multiplier
+		}
+	}
+	info.PricingTier = pricingTier
+
 	var preConsumedQuota int
 	var modelRatio float64
 	var completionRatio float64
 	var cacheRatio float64
 	var imageRatio float64
 	var cacheCreationRatio float64
+	var exactPrice *operation_setting.ExactModelPrice
 	if !usePrice {
 		preConsumedTokens := common.PreConsumedQuota
 		if maxTokens != 0 {
@@ -80,7 +125,15 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 		}
 		var success bool
 		var matchName string
-		modelRatio, success, matchName = ratio_setting.GetModelRatio(info.OriginModelName)
+		modelRatio, success, matchName = ratio_setting.GetModelRatio(priceModelName)
+		if promptTier != nil && promptTier.ModelRatio != nil {
+			modelRatio = *promptTier.ModelRatio
+			success = true
+		}
+		if price, ok := operation_setting.GetExactModelPrice(priceModelName); ok && (promptTier == nil || promptTier.ModelRatio == nil) {
+			exactPrice = price
+			success = true
+		}
 		if !success {
 			acceptUnsetRatio := false
 			if info.UserSetting.AcceptUnsetRatioModel {
@@ -90,13 +143,36 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 				return PriceData{}, fmt.Errorf("模型 %s 倍率或价格未配置，请联系管理员设置或开始自用模式；Model %s ratio or price not set, please set or start self-use mode", matchName, matchName)
 			}
 		}
-		completionRatio = ratio_setting.GetCompletionRatio(info.OriginModelName)
-		cacheRatio, _ = ratio_setting.GetCacheRatio(info.OriginModelName)
-		cacheCreationRatio, _ = ratio_setting.GetCreateCacheRatio(info.OriginModelName)
-		imageRatio, _ = ratio_setting.GetImageRatio(info.OriginModelName)
+		modelRatio *= fineTuneRatio
+		if pricingTier != nil {
+			modelRatio *= pricingTier.Multiplier
+		}
+		completionRatio = ratio_setting.GetCompletionRatio(priceModelName)
+		if promptTier != nil && promptTier.CompletionRatio != nil {
+			completionRatio = *promptTier.CompletionRatio
+		}
+		cacheRatio, _ = ratio_setting.GetCacheRatio(priceModelName)
+		cacheCreationRatio, _ = ratio_setting.GetCreateCacheRatio(priceModelName)
+		imageRatio, _ = ratio_setting.GetImageRatio(priceModelName)
+		if exactPrice != nil {
+			// 精确价格换算为倍率，用于预扣费和日志展示
+			priceRatio := fineTuneRatio
+			if pricingTier != nil {
+				priceRatio *= pricingTier.Multiplier
+			}
+			exactPrice = resolveExactPrice(exactPrice, cacheRatio, cacheCreationRatio, priceRatio)
+			if promptTier != nil && promptTier.CompletionRatio != nil {
+				// 阶梯的补全倍率按精确输入价格换算输出价格
+				exactPrice.Output = exactPrice.Input.Mul(decimal.NewFromFloat(*promptTier.CompletionRatio))
+			}
+			modelRatio, completionRatio, cacheRatio, cacheCreationRatio = exactPriceRatios(exactPrice)
+		}
 		ratio := modelRatio * groupRatioInfo.GroupRatio
 		preConsumedQuota = int(float64(preConsumedTokens) * ratio)
 	} else {
+		if pricingTier != nil {
+			modelPrice *= pricingTier.Multiplier
+		}
 		preConsumedQuota = int(modelPrice * common.QuotaPerUnit * groupRatioInfo.GroupRatio)
 	}
 
@@ -110,6 +186,8 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 		ImageRatio:             imageRatio,
 		CacheCreationRatio:     cacheCreationRatio,
 		ShouldPreConsumedQuota: preConsumedQuota,
+		PricingTier:            pricingTier,
+		ExactPrice:             exactPrice,
 	}
 
 	if common.DebugEnabled {
@@ -119,6 +197,67 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 	return priceData, nil
 }
 
+// RematchPromptTier 结算时按实际提示 tokens 重新选择阶梯价格，与预估的档位不同时重新定价
+func RematchPromptTier(c *gin.Context, info *relaycommon.RelayInfo, priceData PriceData, promptTokens int) PriceData {
+	priceModelName := info.OriginModelName
+	if fineTuneBaseModel := common.GetContextKeyString(c, constant.ContextKeyFineTuneBase); fineTuneBaseModel != "" {
+		priceModelName = fineTuneBaseModel
+	}
+	tiers := operation_setting.GetModelPricingTiers(priceModelName)
+	if tiers == nil || len(tiers.PromptTiers) == 0 {
+		return priceData
+	}
+	var estimated *int
+	if priceData.PricingTier != nil {
+		estimated = priceData.PricingTier.MinPromptTokens
+	}
+	matched := tiers.MatchPromptTier(promptTokens)
+	if (matched == nil && estimated == nil) || (matched != nil && estimated != nil && matched.MinPromptTokens == *estimated) {
+		return priceData
+	}
+	newPriceData, err := ModelPriceHelper(c, info, promptTokens, 0)
+	if err != nil {
+		common.SysError(fmt.Sprintf("rematch pricing tier for model %s failed: %s", priceModelName, err.Error()))
+		info.PricingTier = priceData.PricingTier
+		return priceData
+	}
+	newPriceData.ShouldPreConsumedQuota = priceData.ShouldPreConsumedQuota
+	return newPriceData
+}
+
+// resolveExactPrice 补全缓存价格并乘以价格倍率，返回新的价格
+func resolveExactPrice(price *operation_setting.ExactModelPrice, cacheRatio float64, cacheCreationRatio float64, priceRatio float64) *operation_setting.ExactModelPrice {
+	dPriceRatio := decimal.NewFromFloat(priceRatio)
+	cacheRead := price.Input.Mul(decimal.NewFromFloat(cacheRatio))
+	if price.CacheRead != nil {
+		cacheRead = *price.CacheRead
+	}
+	cacheWrite := price.Input.Mul(decimal.NewFromFloat(cacheCreationRatio))
+	if price.CacheWrite != nil {
+		cacheWrite = *price.CacheWrite
+	}
+	cacheRead = cacheRead.Mul(dPriceRatio)
+	cacheWrite = cacheWrite.Mul(dPriceRatio)
+	return &operation_setting.ExactModelPrice{
+		Input:      price.Input.Mul(dPriceRatio),
+		Output:     price.Output.Mul(dPriceRatio),
+		CacheRead:  &cacheRead,
+		CacheWrite: &cacheWrite,
+	}
+}
+
+// exactPriceRatios 将每百万 tokens 的美元价格换算为模型倍率和补全、缓存倍率
+func exactPriceRatios(price *operation_setting.ExactModelPrice) (modelRatio, completionRatio, cacheRatio, cacheCreationRatio float64) {
+	modelRatio = price.Input.Mul(decimal.NewFromFloat(common.QuotaPerUnit)).Div(decimal.NewFromInt(1000000)).InexactFloat64()
+	if price.Input.IsZero() {
+		return modelRatio, 1, 1, 1
+	}
+	completionRatio = price.Output.Div(price.Input).InexactFloat64()
+	cacheRatio = price.CacheRead.Div(price.Input).InexactFloat64()
+	cacheCreationRatio = price.CacheWrite.Div(price.Input).InexactFloat64()
+	return modelRatio, completionRatio, cacheRatio, cacheCreationRatio
+}
+
 type PerCallPriceData struct {
 	ModelPrice     float64
 	Quota          int
@@ -10,8 +10,10 @@ import (
 	"one-api/constant"
 	relaycommon "one-api/relay/common"
 	"one-api/setting/operation_setting"
+	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bytedance/gopkg/util/gopool"
@@ -25,6 +27,10 @@ const (
 	DefaultPingInterval      = 10 * time.Second
 )
 
+// streamFinishPattern 标志流正常结束的数据块：OpenAI 非空的 finish_reason、Gemini 的 finishReason、Claude 的 stop_reason、百度的 is_end，
+// 以及 Claude 的 message_stop、Responses 的 response.completed 和 Dify 的 message_end、workflow_finished 事件
+var streamFinishPattern = regexp.MustCompile(`"(finish_reason|finishReason|stop_reason)"\s*:\s*"|"is_end"\s*:\s*true|"(message_stop|response\.completed|message_end|workflow_finished)"`)
+
 func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo, dataHandler func(data string) bool) {
 
 	if resp == nil || dataHandler == nil {
@@ -51,6 +57,8 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 		pingTicker *time.Ticker
 		writeMutex sync.Mutex     // Mutex to protect concurrent writes
 		wg         sync.WaitGroup // 用于等待所有 goroutine 退出
+		// 扫描协程和主循环都可能标记中断，结束时再写回 info
+		interrupted atomic.Bool
 	)
 
 	generalSettings := operation_setting.GetGeneralSetting()
@@ -71,6 +79,13 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 		println("ping interval seconds:", int64(pingInterval.Seconds()))
 	}
 
+	// 晚于下方的资源清理执行，在 goroutine 退出后写回中断状态，未开启续写的请求不标记中断
+	defer func() {
+		if info.StreamResumable && interrupted.Load() {
+			info.StreamInterrupted = true
+		}
+	}()
+
 	// 改进资源清理，确保所有 goroutine 正确退出
 	defer func() {
 		// 通知所有 goroutine 停止
@@ -183,6 +198,8 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 			}
 		}()
 
+		// 是否收到 [DONE] 或结束原因，没有收到就遇到 EOF 说明上游中途断开；只有可续写的请求才需要判断
+		finished := !info.StreamResumable
 		for scanner.Scan() {
 			// 检查是否需要停止
 			select {
@@ -210,8 +227,13 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 			data = data[5:]
 			data = strings.TrimLeft(data, " ")
 			data = strings.TrimSuffix(data, "\r")
-			if !strings.HasPrefix(data, "[DONE]") {
+			if strings.HasPrefix(data, "[DONE]") {
+				finished = true
+			} else {
 				info.SetFirstResponseTime()
+				if !finished && streamFinishPattern.MatchString(data) {
+					finished = true
+				}
 
 				// 使用超时机制防止写操作阻塞
 				done := make(chan bool, 1)
@@ -237,10 +259,12 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 			}
 		}
 
-		if err := scanner.Err(); err != nil {
-			if err != io.EOF {
-				common.LogError(c, "scanner error: "+err.Error())
-			}
+		if err := scanner.Err(); err != nil && err != io.EOF {
+			common.LogError(c, "scanner error: "+err.Error())
+			interrupted.Store(true)
+		} else if !finished {
+			common.LogError(c, "upstream stream ended without [DONE] or finish reason")
+			interrupted.Store(true)
 		}
 	})
 
@@ -249,6 +273,7 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 	case <-ticker.C:
 		// 超时处理逻辑
 		common.LogError(c, "streaming timeout")
+		interrupted.Store(true)
 	case <-stopChan:
 		// 正常结束
 		common.LogInfo(c, "streaming finished")
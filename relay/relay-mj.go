@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
 )
 
 func RelayMidjourneyImage(c *gin.Context) {
@@ -189,7 +190,7 @@ func RelaySwapFace(c *gin.Context) *dto.MidjourneyResponse {
 
 	priceData := helper.ModelPriceHelperPerCall(c, relayInfo)
 
-	userQuota, err := model.GetUserQuota(userId, false)
+	userQuota, err := model.GetBillingQuota(userId, relayInfo.OrganizationId)
 	if err != nil {
 		return &dto.MidjourneyResponse{
 			Code:        4,
@@ -203,6 +204,12 @@ func RelaySwapFace(c *gin.Context) *dto.MidjourneyResponse {
 			Description: "quota_not_enough",
 		}
 	}
+	if err = service.CheckSpendingBudgets(relayInfo, priceData.Quota); err != nil {
+		return &dto.MidjourneyResponse{
+			Code:        4,
+			Description: err.Error(),
+		}
+	}
 	requestURL := getMjRequestPath(c.Request.URL.String())
 	baseURL := c.GetString("base_url")
 	fullRequestURL := fmt.Sprintf("%s%s", baseURL, requestURL)
@@ -210,8 +217,14 @@ func RelaySwapFace(c *gin.Context) *dto.MidjourneyResponse {
 	if err != nil {
 		return &mjResp.Response
 	}
+	// 提交成功时按实际结算的额度扣费并记录到任务中，任务失败时退还相同的额度
+	consumed := mjResp.StatusCode == 200 && mjResp.Response.Code == 1
+	var cost *decimal.Decimal
+	if consumed {
+		priceData.Quota, cost = service.SettlePerCallQuota(relayInfo, priceData.Quota, priceData.ModelPrice*priceData.GroupRatioInfo.GroupRatio)
+	}
 	defer func() {
-		if mjResp.StatusCode == 200 && mjResp.Response.Code == 1 {
+		if consumed {
 			err := service.PostConsumeQuota(relayInfo, priceData.Quota, 0, true)
 			if err != nil {
 				common.SysError("error consuming token remain quota: " + err.Error())
@@ -230,6 +243,7 @@ func RelaySwapFace(c *gin.Context) *dto.MidjourneyResponse {
 				UserQuota: userQuota,
 				Group:     relayInfo.UsingGroup,
 				Other:     other,
+				Cost:      cost,
 			})
 			model.UpdateUserUsedQuotaAndRequestCount(userId, priceData.Quota)
 			model.UpdateChannelUsedQuota(int64(channelId), priceData.Quota)
@@ -255,6 +269,7 @@ func RelaySwapFace(c *gin.Context) *dto.MidjourneyResponse {
 		ChannelId:   c.GetInt("channel_id"),
 		Quota:       priceData.Quota,
 	}
+	midjourneyTask.OrganizationId = relayInfo.OrganizationId
 	err = midjourneyTask.Insert()
 	if err != nil {
 		return service.MidjourneyErrorWrapper(constant.MjRequestError, "insert_midjourney_task_failed")
@@ -496,7 +511,7 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *dto.MidjourneyRespons
 
 	priceData := helper.ModelPriceHelperPerCall(c, relayInfo)
 
-	userQuota, err := model.GetUserQuota(userId, false)
+	userQuota, err := model.GetBillingQuota(userId, relayInfo.OrganizationId)
 	if err != nil {
 		return &dto.MidjourneyResponse{
 			Code:        4,
@@ -510,6 +525,14 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *dto.MidjourneyRespons
 			Description: "quota_not_enough",
 		}
 	}
+	if consumeQuota {
+		if err = service.CheckSpendingBudgets(relayInfo, priceData.Quota); err != nil {
+			return &dto.MidjourneyResponse{
+				Code:        4,
+				Description: err.Error(),
+			}
+		}
+	}
 
 	midjResponseWithStatus, responseBody, err := service.DoMidjourneyHttpRequest(c, time.Second*60, fullRequestURL)
 	if err != nil {
@@ -517,6 +540,7 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *dto.MidjourneyRespons
 	}
 	midjResponse := &midjResponseWithStatus.Response
 
+	var cost *decimal.Decimal
 	defer func() {
 		if consumeQuota && midjResponseWithStatus.StatusCode == 200 {
 			err := service.PostConsumeQuota(relayInfo, priceData.Quota, 0, true)
@@ -536,6 +560,7 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *dto.MidjourneyRespons
 				UserQuota: userQuota,
 				Group:     group,
 				Other:     other,
+				Cost:      cost,
 			})
 			model.UpdateUserUsedQuotaAndRequestCount(userId, priceData.Quota)
 			model.UpdateChannelUsedQuota(int64(channelId), priceData.Quota)
@@ -568,6 +593,7 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *dto.MidjourneyRespons
 		ChannelId:   c.GetInt("channel_id"),
 		Quota:       priceData.Quota,
 	}
+	midjourneyTask.OrganizationId = relayInfo.OrganizationId
 	if midjResponse.Code == 3 {
 		//无实例账号自动禁用渠道（No available account instance）
 		channel, err := model.GetChannelById(midjourneyTask.ChannelId, true)
@@ -583,6 +609,11 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *dto.MidjourneyRespons
 		midjourneyTask.FailReason = midjResponse.Description
 		consumeQuota = false
 	}
+	if consumeQuota && midjResponseWithStatus.StatusCode == 200 {
+		// 按实际结算的额度扣费并记录到任务中，任务失败时退还相同的额度
+		priceData.Quota, cost = service.SettlePerCallQuota(relayInfo, priceData.Quota, priceData.ModelPrice*priceData.GroupRatioInfo.GroupRatio)
+		midjourneyTask.Quota = priceData.Quota
+	}
 
 	if midjResponse.Code == 21 { //21-任务已存在（处理中或者有结果了）
 		// 将 properties 转换为一个 map
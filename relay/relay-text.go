@@ -126,6 +126,9 @@ func TextHelper(c *gin.Context) (newAPIError *types.NewAPIError) {
 		}
 		c.Set("prompt_tokens", promptTokens)
 	}
+	if newAPIError := checkChannelContextWindow(c, promptTokens); newAPIError != nil {
+		return newAPIError
+	}
 
 	priceData, err := helper.ModelPriceHelper(c, relayInfo, promptTokens, int(math.Max(float64(textRequest.MaxTokens), float64(textRequest.MaxCompletionTokens))))
 	if err != nil {
@@ -164,6 +167,29 @@ func TextHelper(c *gin.Context) (newAPIError *types.NewAPIError) {
 		relayInfo.ShouldIncludeUsage = true
 	}
 
+	// 流式续写：续写段以之前各段已输出的内容作为助手前缀
+	if shouldResumeStream(relayInfo, textRequest) {
+		relayInfo.StreamResumable = true
+		if resume := getStreamResume(c); resume != nil {
+			relayInfo.StreamResume = resume
+			appendStreamResumePrefix(textRequest, resume)
+		}
+	}
+
+	// 响应缓存：命中已在选择渠道前由 ReplayResponseCache 处理，这里记录响应用于写入缓存
+	var cacheKey string
+	var cacheWriter *service.ResponseCacheWriter
+	if relayInfo.StreamResume == nil && shouldUseResponseCache(c, relayInfo, textRequest) {
+		cacheKey, err = service.ResponseCacheKey(relayInfo, textRequest)
+		if err == nil {
+			cacheWriter = service.NewResponseCacheWriter(c.Writer)
+			c.Writer = cacheWriter
+			defer func() {
+				c.Writer = cacheWriter.ResponseWriter
+			}()
+		}
+	}
+
 	adaptor := GetAdaptor(relayInfo.ApiType)
 	if adaptor == nil {
 		return types.NewError(fmt.Errorf("invalid api type: %d", relayInfo.ApiType), types.ErrorCodeInvalidApiType)
@@ -233,11 +259,37 @@ func TextHelper(c *gin.Context) (newAPIError *types.NewAPIError) {
 		return newApiErr
 	}
 
+	if !service.ClaimHedgeWin(c, relayInfo.ChannelId) {
+		// 对冲请求中另一渠道已先返回，本次结果不采用也不计费
+		newApiErr = types.NewError(service.ErrHedgeLost, types.ErrorCodeDoRequestFailed)
+		return newApiErr
+	}
+
+	if cacheWriter != nil && !relayInfo.StreamInterrupted {
+		if body := cacheWriter.Body(); body != nil {
+			cacheUsage := usage.(*dto.Usage)
+			isStream := relayInfo.IsStream
+			gopool.Go(func() {
+				if err := service.SaveResponseCache(cacheKey, body, isStream, cacheUsage); err != nil {
+					common.SysError("save response cache failed: " + err.Error())
+				}
+			})
+		}
+	}
+
 	if strings.HasPrefix(relayInfo.OriginModelName, "gpt-4o-audio") {
 		service.PostAudioConsumeQuota(c, relayInfo, usage.(*dto.Usage), preConsumedQuota, userQuota, priceData, "")
 	} else {
 		postConsumeQuota(c, relayInfo, usage.(*dto.Usage), preConsumedQuota, userQuota, priceData, "")
 	}
+
+	if relayInfo.StreamResumePending {
+		// 已输出部分按本段用量计费，之后由 controller 换渠道续写
+		relayInfo.StreamResume.CompletionTokens += usage.(*dto.Usage).CompletionTokens
+		relayInfo.StreamResume.Resumes++
+		common.SetContextKey(c, constant.ContextKeyStreamResume, relayInfo.StreamResume)
+		return types.NewError(ErrStreamInterrupted, types.ErrorCodeBadResponse)
+	}
 	return nil
 }
 
@@ -261,6 +313,15 @@ func getPromptTokens(textRequest *dto.GeneralOpenAIRequest, info *relaycommon.Re
 	return promptTokens, err
 }
 
+// checkChannelContextWindow 提示 tokens 超过当前渠道的上下文窗口时不请求上游，由 controller 改选其他渠道
+func checkChannelContextWindow(c *gin.Context, promptTokens int) *types.NewAPIError {
+	window := common.GetContextKeyInt(c, constant.ContextKeyChannelContextWindow)
+	if window <= 0 || promptTokens <= window {
+		return nil
+	}
+	return types.NewErrorWithStatusCode(fmt.Errorf("prompt tokens %d exceed the context window %d of the current channel", promptTokens, window), types.ErrorCodeContextWindowExceeded, http.StatusBadRequest)
+}
+
 func checkRequestSensitive(textRequest *dto.GeneralOpenAIRequest, info *relaycommon.RelayInfo) ([]string, error) {
 	var err error
 	var words []string
@@ -279,7 +340,7 @@ func checkRequestSensitive(textRequest *dto.GeneralOpenAIRequest, info *relaycom
 
 // 预扣费并返回用户剩余配额
 func preConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommon.RelayInfo) (int, int, *types.NewAPIError) {
-	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
+	userQuota, err := model.GetBillingQuota(relayInfo.UserId, relayInfo.OrganizationId)
 	if err != nil {
 		return 0, 0, types.NewError(err, types.ErrorCodeQueryDataError)
 	}
@@ -308,12 +369,16 @@ func preConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommo
 		}
 	}
 
-	if preConsumedQuota > 0 {
-		err := service.PreConsumeTokenQuota(relayInfo, preConsumedQuota)
-		if err != nil {
-			return 0, 0, types.NewErrorWithStatusCode(err, types.ErrorCodePreConsumeTokenQuotaFailed, http.StatusForbidden)
+	// 信任额度免预扣时也要检查周期预算
+	err = service.PreConsumeTokenQuota(relayInfo, preConsumedQuota)
+	if err != nil {
+		if errors.Is(err, service.ErrBudgetExceeded) {
+			return 0, 0, types.NewErrorWithStatusCode(err, types.ErrorCodeSpendingBudgetExceeded, http.StatusForbidden)
 		}
-		err = model.DecreaseUserQuota(relayInfo.UserId, preConsumedQuota)
+		return 0, 0, types.NewErrorWithStatusCode(err, types.ErrorCodePreConsumeTokenQuotaFailed, http.StatusForbidden)
+	}
+	if preConsumedQuota > 0 {
+		err = model.DecreaseBillingQuota(relayInfo.UserId, relayInfo.OrganizationId, preConsumedQuota)
 		if err != nil {
 			return 0, 0, types.NewError(err, types.ErrorCodeUpdateDataError)
 		}
@@ -321,6 +386,12 @@ func preConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommo
 	return preConsumedQuota, userQuota, nil
 }
 
+// PreConsumeQuota 按在线请求相同的规则检查额度、令牌额度和周期预算并预扣，返回实际预扣的额度，用于不经过请求链路的上游批处理
+func PreConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommon.RelayInfo) (int, *types.NewAPIError) {
+	preConsumedQuota, _, newAPIError := preConsumeQuota(c, preConsumedQuota, relayInfo)
+	return preConsumedQuota, newAPIError
+}
+
 func returnPreConsumedQuota(c *gin.Context, relayInfo *relaycommon.RelayInfo, userQuota int, preConsumedQuota int) {
 	if preConsumedQuota != 0 {
 		gopool.Go(func() {
@@ -334,8 +405,13 @@ func returnPreConsumedQuota(c *gin.Context, relayInfo *relaycommon.RelayInfo, us
 	}
 }
 
+// SettleUsage 按实际用量走正常的结算流程并返回最终扣除的额度，用于上游批处理等不经过请求链路的结算
+func SettleUsage(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage, priceData helper.PriceData, extraContent string) int {
+	return postConsumeQuota(ctx, relayInfo, usage, 0, relayInfo.UserQuota, priceData, extraContent)
+}
+
 func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
-	usage *dto.Usage, preConsumedQuota int, userQuota int, priceData helper.PriceData, extraContent string) {
+	usage *dto.Usage, preConsumedQuota int, userQuota int, priceData helper.PriceData, extraContent string) int {
 	if usage == nil {
 		usage = &dto.Usage{
 			PromptTokens:     relayInfo.PromptTokens,
@@ -344,6 +420,8 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		}
 		extraContent += "（可能是请求出错）"
 	}
+	priceData = helper.RematchPromptTier(ctx, relayInfo, priceData, usage.PromptTokens)
+	service.RecordChannelRelaySuccess(relayInfo, usage)
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	promptTokens := usage.PromptTokens
 	cacheTokens := usage.PromptTokensDetails.CachedTokens
@@ -457,14 +535,24 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 				extraContent += fmt.Sprintf("Audio Input 花费 %s", audioInputQuota.String())
 			}
 		}
-		promptQuota := baseTokens.Add(cachedTokensWithRatio).Add(imageTokensWithRatio)
+		exactQuota, isExact := service.ExactTokenQuota(priceData, service.ExactTokens{
+			Input:     int(baseTokens.IntPart()),
+			CacheRead: cacheTokens,
+			Image:     imageTokens,
+			Output:    completionTokens,
+		})
+		if isExact {
+			quotaCalculateDecimal = exactQuota
+		} else {
+			promptQuota := baseTokens.Add(cachedTokensWithRatio).Add(imageTokensWithRatio)
 
-		completionQuota := dCompletionTokens.Mul(dCompletionRatio)
+			completionQuota := dCompletionTokens.Mul(dCompletionRatio)
 
-		quotaCalculateDecimal = promptQuota.Add(completionQuota).Mul(ratio)
+			quotaCalculateDecimal = promptQuota.Add(completionQuota).Mul(ratio)
 
-		if !ratio.IsZero() && quotaCalculateDecimal.LessThanOrEqual(decimal.Zero) {
-			quotaCalculateDecimal = decimal.NewFromInt(1)
+			if !ratio.IsZero() && quotaCalculateDecimal.LessThanOrEqual(decimal.Zero) {
+				quotaCalculateDecimal = decimal.NewFromInt(1)
+			}
 		}
 	} else {
 		quotaCalculateDecimal = dModelPrice.Mul(dQuotaPerUnit).Mul(dGroupRatio)
@@ -475,8 +563,18 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	// 添加 audio input 独立计费
 	quotaCalculateDecimal = quotaCalculateDecimal.Add(audioInputQuota)
 
-	quota := int(quotaCalculateDecimal.Round(0).IntPart())
+	if isStreamResumeSegment(relayInfo) && priceData.UsePrice {
+		// 按次计费的模型续写时不再重复收费
+		quotaCalculateDecimal = decimal.Zero
+	}
 	totalTokens := promptTokens + completionTokens
+	if totalTokens == 0 {
+		quotaCalculateDecimal = decimal.Zero
+	}
+	quota := service.SettleQuota(relayInfo, quotaCalculateDecimal)
+	if relayInfo.ResponseCacheHit {
+		quota = service.ResponseCacheHitQuota(quota)
+	}
 
 	var logContent string
 	if !priceData.UsePrice {
@@ -494,17 +592,32 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		common.LogError(ctx, fmt.Sprintf("total tokens is 0, cannot consume quota, userId %d, channelId %d, "+
 			"tokenId %d, model %s， pre-consumed quota %d", relayInfo.UserId, relayInfo.ChannelId, relayInfo.TokenId, modelName, preConsumedQuota))
 	} else {
-		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
-		model.UpdateChannelUsedQuota(int64(relayInfo.ChannelId), quota)
+		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, service.BatchDiscountQuota(relayInfo, quota))
+		if !relayInfo.ResponseCacheHit {
+			model.UpdateChannelUsedQuota(int64(relayInfo.ChannelId), service.BatchDiscountQuota(relayInfo, quota))
+		}
 	}
 
 	quotaDelta := quota - preConsumedQuota
-	if quotaDelta != 0 {
+	if quotaDelta != 0 || relayInfo.IsBatch {
 		err := service.PostConsumeQuota(relayInfo, quotaDelta, preConsumedQuota, true)
 		if err != nil {
 			common.LogError(ctx, "error consuming token remain quota: "+err.Error())
 		}
 	}
+	if relayInfo.IsBatch {
+		// PostConsumeQuota 已按折扣结算，日志同步记录折扣后的额度
+		quota = service.BatchDiscountQuota(relayInfo, quota)
+		logContent += fmt.Sprintf("，批处理折扣 %.2f", operation_setting.GetBatchSetting().DiscountRatio)
+	}
+	if relayInfo.ResponseCacheHit {
+		logContent += fmt.Sprintf("，响应缓存命中倍率 %.2f", operation_setting.GetResponseCacheSetting().HitPriceRatio)
+	}
+	if relayInfo.StreamResumePending {
+		logContent += "，上游流式中断，已输出部分计费"
+	} else if isStreamResumeSegment(relayInfo) {
+		logContent += fmt.Sprintf("，流式续写第 %d 次，提示含已输出前缀，按上游实际用量计费", relayInfo.StreamResume.Resumes)
+	}
 
 	logModel := modelName
 	if strings.HasPrefix(logModel, "gpt-4-gizmo") {
@@ -548,11 +661,29 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 			other["file_search_price"] = fileSearchPrice
 		}
 	}
+	if relayInfo.IsBatch {
+		other["batch"] = true
+		other["batch_discount_ratio"] = operation_setting.GetBatchSetting().DiscountRatio
+	}
+	if relayInfo.ResponseCacheHit {
+		other["cache_hit"] = true
+		other["cache_hit_ratio"] = operation_setting.GetResponseCacheSetting().HitPriceRatio
+	}
+	if relayInfo.StreamResumePending {
+		other["stream_interrupted"] = true
+	}
+	if isStreamResumeSegment(relayInfo) {
+		other["stream_resume"] = true
+		other["stream_resume_count"] = relayInfo.StreamResume.Resumes
+	}
 	if !audioInputQuota.IsZero() {
 		other["audio_input_seperate_price"] = true
 		other["audio_input_token_count"] = audioTokens
 		other["audio_input_price"] = audioInputPrice
 	}
+	if priceData.ExactPrice != nil {
+		other["exact_price"] = priceData.ExactPrice
+	}
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     promptTokens,
@@ -567,5 +698,7 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		IsStream:         relayInfo.IsStream,
 		Group:            relayInfo.UsingGroup,
 		Other:            other,
+		Cost:             service.ExactCost(relayInfo, quotaCalculateDecimal),
 	})
+	return quota
 }
@@ -15,8 +15,10 @@ import (
 	relayconstant "one-api/relay/constant"
 	"one-api/service"
 	"one-api/setting/ratio_setting"
+	"one-api/types"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
 )
 
 /*
@@ -62,7 +64,7 @@ func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 	} else {
 		ratio = modelPrice * groupRatio
 	}
-	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
+	userQuota, err := model.GetBillingQuota(relayInfo.UserId, relayInfo.OrganizationId)
 	if err != nil {
 		taskErr = service.TaskErrorWrapper(err, "get_user_quota_failed", http.StatusInternalServerError)
 		return
@@ -72,6 +74,10 @@ func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 		taskErr = service.TaskErrorWrapperLocal(errors.New("user quota is not enough"), "quota_not_enough", http.StatusForbidden)
 		return
 	}
+	if err = service.CheckSpendingBudgets(relayInfo.RelayInfo, quota); err != nil {
+		taskErr = service.TaskErrorWrapperLocal(err, string(types.ErrorCodeSpendingBudgetExceeded), http.StatusForbidden)
+		return
+	}
 
 	if relayInfo.OriginTaskID != "" {
 		originTask, exist, err := model.GetByTaskId(relayInfo.UserId, relayInfo.OriginTaskID)
@@ -120,6 +126,7 @@ func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 		return
 	}
 
+	var cost *decimal.Decimal
 	defer func() {
 		// release quota
 		if relayInfo.ConsumeQuota && taskErr == nil {
@@ -151,6 +158,7 @@ func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 					UserQuota: userQuota,
 					Group:     relayInfo.UsingGroup,
 					Other:     other,
+					Cost:      cost,
 				})
 				model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
 				model.UpdateChannelUsedQuota(int64(relayInfo.ChannelId), quota)
@@ -163,6 +171,8 @@ func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 		return
 	}
 	relayInfo.ConsumeQuota = true
+	// 按实际结算的额度扣费并记录到任务中，任务失败时退还相同的额度
+	quota, cost = service.SettlePerCallQuota(relayInfo.RelayInfo, quota, ratio)
 	// insert task
 	task := model.InitTask(platform, relayInfo)
 	task.TaskID = taskID
@@ -0,0 +1,130 @@
+package relay
+
+import (
+	"math"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting"
+	"one-api/setting/operation_setting"
+	"one-api/types"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// shouldUseResponseCache 全局开启、分组允许且令牌开启响应缓存时，可缓存的 chat completion 请求才使用缓存
+func shouldUseResponseCache(c *gin.Context, info *relaycommon.RelayInfo, textRequest *dto.GeneralOpenAIRequest) bool {
+	if !operation_setting.GetResponseCacheSetting().Enabled || info.IsBatch || info.IsPlayground {
+		return false
+	}
+	if info.RelayMode != relayconstant.RelayModeChatCompletions || strings.HasPrefix(info.OriginModelName, "gpt-4o-audio") {
+		return false
+	}
+	if !common.GetContextKeyBool(c, constant.ContextKeyTokenResponseCache) || !operation_setting.IsResponseCacheGroupEnabled(info.UsingGroup) {
+		return false
+	}
+	return service.IsResponseCacheableRequest(textRequest)
+}
+
+// ReplayResponseCache 在占用渠道并发和熔断探测名额之前查询响应缓存，命中时直接返回缓存内容并计费；
+// 未命中或请求不可缓存时返回 false，请求校验等错误交给 TextHelper 处理
+func ReplayResponseCache(c *gin.Context, relayMode int) (bool, *types.NewAPIError) {
+	if relayMode != relayconstant.RelayModeChatCompletions || !operation_setting.GetResponseCacheSetting().Enabled {
+		return false, nil
+	}
+	relayInfo := relaycommon.GenRelayInfo(c)
+	textRequest, err := getAndValidateTextRequest(c, relayInfo)
+	if err != nil || !shouldUseResponseCache(c, relayInfo, textRequest) {
+		return false, nil
+	}
+	if setting.ShouldCheckPromptSensitive() {
+		if _, err = checkRequestSensitive(textRequest, relayInfo); err != nil {
+			return false, nil
+		}
+	}
+	cacheKey, err := service.ResponseCacheKey(relayInfo, textRequest)
+	if err != nil {
+		return false, nil
+	}
+	entry := service.GetResponseCache(cacheKey)
+	if entry == nil {
+		return false, nil
+	}
+	if err = helper.ModelMappedHelper(c, relayInfo, textRequest); err != nil {
+		return false, nil
+	}
+	promptTokens, err := getPromptTokens(textRequest, relayInfo)
+	if err != nil {
+		return false, nil
+	}
+	c.Set("prompt_tokens", promptTokens)
+	priceData, err := helper.ModelPriceHelper(c, relayInfo, promptTokens, int(math.Max(float64(textRequest.MaxTokens), float64(textRequest.MaxCompletionTokens))))
+	if err != nil {
+		return false, nil
+	}
+	preConsumedQuota, userQuota, newAPIError := preConsumeQuota(c, priceData.ShouldPreConsumedQuota, relayInfo)
+	if newAPIError != nil {
+		return true, newAPIError
+	}
+	relayInfo.ShouldIncludeUsage = textRequest.Stream && textRequest.StreamOptions != nil && textRequest.StreamOptions.IncludeUsage
+	relayInfo.ResponseCacheHit = true
+	replayCachedResponse(c, relayInfo, entry)
+	postConsumeQuota(c, relayInfo, &entry.Usage, preConsumedQuota, userQuota, priceData, "")
+	return true, nil
+}
+
+// replayCachedResponse 将缓存的响应返回给客户端，流式请求重放为 SSE
+func replayCachedResponse(c *gin.Context, info *relaycommon.RelayInfo, entry *service.ResponseCacheEntry) {
+	response := entry.Response
+	response.Id = helper.GetResponseID(c)
+	response.Created = info.StartTime.Unix()
+	if !info.IsStream {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	helper.SetEventStreamHeaders(c)
+	createdAt := info.StartTime.Unix()
+	for _, choice := range response.Choices {
+		delta := dto.ChatCompletionsStreamResponseChoiceDelta{Role: "assistant"}
+		if choice.ReasoningContent != "" {
+			delta.SetReasoningContent(choice.ReasoningContent)
+		}
+		delta.SetContentString(choice.StringContent())
+		var toolCalls []dto.ToolCallResponse
+		if len(choice.ToolCalls) > 0 {
+			_ = common.Unmarshal(choice.ToolCalls, &toolCalls)
+		}
+		for i := range toolCalls {
+			index := i
+			toolCalls[i].Index = &index
+		}
+		delta.ToolCalls = toolCalls
+		_ = helper.ObjectData(c, &dto.ChatCompletionsStreamResponse{
+			Id:      response.Id,
+			Object:  "chat.completion.chunk",
+			Created: createdAt,
+			Model:   response.Model,
+			Choices: []dto.ChatCompletionsStreamResponseChoice{{Index: choice.Index, Delta: delta}},
+		})
+	}
+	for _, choice := range response.Choices {
+		finishReason := choice.FinishReason
+		if finishReason == "" {
+			finishReason = constant.FinishReasonStop
+		}
+		stop := helper.GenerateStopResponse(response.Id, createdAt, response.Model, finishReason)
+		stop.Choices[0].Index = choice.Index
+		_ = helper.ObjectData(c, stop)
+	}
+	if info.ShouldIncludeUsage {
+		_ = helper.ObjectData(c, helper.GenerateFinalUsageResponse(response.Id, createdAt, response.Model, entry.Usage))
+	}
+	helper.Done(c)
+}
@@ -9,11 +9,14 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/dto"
+	"one-api/model"
+	"one-api/relay/channel"
 	relaycommon "one-api/relay/common"
 	"one-api/relay/helper"
 	"one-api/service"
 	"one-api/setting"
 	"one-api/setting/model_setting"
+	"one-api/setting/operation_setting"
 	"one-api/types"
 	"strings"
 
@@ -47,6 +50,108 @@ func getInputTokens(req *dto.OpenAIResponsesRequest, info *relaycommon.RelayInfo
 	return inputTokens
 }
 
+// expandPreviousResponse 按 previous_response_id 将本地保存的历史输入输出拼接到 input 之前，
+// 转发时不再依赖上游保存的会话状态，重试切换渠道或 key 后同样可用
+func expandPreviousResponse(req *dto.OpenAIResponsesRequest, info *relaycommon.RelayInfo) (bool, error) {
+	if req.PreviousResponseID == "" {
+		return false, nil
+	}
+	convertResponses := info.ResponsesConvertInfo != nil
+	var storedResponse *model.StoredResponse
+	var err error
+	if operation_setting.GetResponsesSetting().StoreEnabled {
+		storedResponse, err = model.GetUserStoredResponse(info.UserId, req.PreviousResponseID)
+	}
+	if storedResponse == nil {
+		// 原生渠道本地没有记录时交给上游处理
+		if convertResponses {
+			return false, fmt.Errorf("previous response with id '%s' not found", req.PreviousResponseID)
+		}
+		return false, nil
+	}
+	var items []json.RawMessage
+	if err = common.UnmarshalJsonStr(storedResponse.Input, &items); err != nil {
+		return false, err
+	}
+	var outputItems []json.RawMessage
+	if err = common.UnmarshalJsonStr(storedResponse.Output, &outputItems); err != nil {
+		return false, err
+	}
+	inputItems, err := req.ParseInputItems()
+	if err != nil {
+		return false, err
+	}
+	items = append(append(items, outputItems...), inputItems...)
+	req.Input, err = common.Marshal(items)
+	if err != nil {
+		return false, err
+	}
+	if !convertResponses {
+		req.PreviousResponseID = ""
+	}
+	return true, nil
+}
+
+// saveStoredResponse 保存本次请求展开后的输入和输出，供后续 previous_response_id 使用
+func saveStoredResponse(c *gin.Context, req *dto.OpenAIResponsesRequest, info *relaycommon.RelayInfo) {
+	responsesSetting := operation_setting.GetResponsesSetting()
+	if len(info.ResponsesResult) == 0 {
+		return
+	}
+	var result struct {
+		Id     string            `json:"id"`
+		Output []json.RawMessage `json:"output"`
+	}
+	if err := common.Unmarshal(info.ResponsesResult, &result); err != nil || result.Id == "" {
+		return
+	}
+	inputItems, err := req.ParseInputItems()
+	if err != nil {
+		return
+	}
+	output, err := common.Marshal(result.Output)
+	if err != nil {
+		return
+	}
+	// 超出大小上限时丢弃最早的输入项
+	size := len(output) + len(info.ResponsesResult)
+	for _, item := range inputItems {
+		size += len(item)
+	}
+	for responsesSetting.MaxStoreBytes > 0 && size > responsesSetting.MaxStoreBytes && len(inputItems) > 0 {
+		size -= len(inputItems[0])
+		inputItems = inputItems[1:]
+	}
+	if responsesSetting.MaxStoreBytes > 0 && size > responsesSetting.MaxStoreBytes {
+		common.LogWarn(c, fmt.Sprintf("response %s is too large to store", result.Id))
+		return
+	}
+	input, err := common.Marshal(inputItems)
+	if err != nil {
+		return
+	}
+	storedResponse := &model.StoredResponse{
+		ResponseId: result.Id,
+		UserId:     info.UserId,
+		Model:      info.OriginModelName,
+		Input:      string(input),
+		Output:     string(output),
+		Data:       string(info.ResponsesResult),
+	}
+	if responsesSetting.StoreTTLSeconds > 0 {
+		storedResponse.ExpiresAt = common.GetTimestamp() + responsesSetting.StoreTTLSeconds
+	}
+	if err = storedResponse.Insert(); err != nil {
+		common.LogError(c, "save stored response failed: "+err.Error())
+		return
+	}
+	if responsesSetting.MaxStoredPerUser > 0 {
+		if err = model.TrimUserStoredResponses(info.UserId, responsesSetting.MaxStoredPerUser); err != nil {
+			common.LogError(c, "trim stored responses failed: "+err.Error())
+		}
+	}
+}
+
 func ResponsesHelper(c *gin.Context) (newAPIError *types.NewAPIError) {
 	req, err := getAndValidateResponsesRequest(c)
 	if err != nil {
@@ -55,6 +160,15 @@ func ResponsesHelper(c *gin.Context) (newAPIError *types.NewAPIError) {
 	}
 
 	relayInfo := relaycommon.GenRelayInfoResponses(c, req)
+	// 非原生渠道转换为 chat completions 请求
+	convertResponses := relayInfo.ResponsesConvertInfo != nil
+	if convertResponses && !channel.SupportResponsesRequest(relayInfo.ChannelType) {
+		return types.NewError(fmt.Errorf("channel type %d does not support responses request", relayInfo.ChannelType), types.ErrorCodeInvalidApiType)
+	}
+	expanded, err := expandPreviousResponse(req, relayInfo)
+	if err != nil {
+		return types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest)
+	}
 
 	if setting.ShouldCheckPromptSensitive() {
 		sensitiveWords, err := checkInputSensitive(req, relayInfo)
@@ -76,6 +190,9 @@ func ResponsesHelper(c *gin.Context) (newAPIError *types.NewAPIError) {
 		promptTokens := getInputTokens(req, relayInfo)
 		c.Set("prompt_tokens", promptTokens)
 	}
+	if newAPIError := checkChannelContextWindow(c, relayInfo.PromptTokens); newAPIError != nil {
+		return newAPIError
+	}
 
 	priceData, err := helper.ModelPriceHelper(c, relayInfo, relayInfo.PromptTokens, int(req.MaxOutputTokens))
 	if err != nil {
@@ -97,14 +214,19 @@ func ResponsesHelper(c *gin.Context) (newAPIError *types.NewAPIError) {
 	}
 	adaptor.Init(relayInfo)
 	var requestBody io.Reader
-	if model_setting.GetGlobalSettings().PassThroughRequestEnabled {
+	if model_setting.GetGlobalSettings().PassThroughRequestEnabled && !convertResponses && !expanded {
 		body, err := common.GetRequestBody(c)
 		if err != nil {
 			return types.NewError(err, types.ErrorCodeReadRequestBodyFailed)
 		}
 		requestBody = bytes.NewBuffer(body)
 	} else {
-		convertedRequest, err := adaptor.ConvertOpenAIResponsesRequest(c, relayInfo, *req)
+		var convertedRequest any
+		if convertResponses {
+			convertedRequest, err = channel.ConvertResponsesRequestByOpenAI(adaptor, c, relayInfo, req)
+		} else {
+			convertedRequest, err = adaptor.ConvertOpenAIResponsesRequest(c, relayInfo, *req)
+		}
 		if err != nil {
 			return types.NewError(err, types.ErrorCodeConvertRequestFailed)
 		}
@@ -160,6 +282,10 @@ func ResponsesHelper(c *gin.Context) (newAPIError *types.NewAPIError) {
 		return newAPIError
 	}
 
+	if req.GetStore() && operation_setting.GetResponsesSetting().StoreEnabled {
+		saveStoredResponse(c, req, relayInfo)
+	}
+
 	if strings.HasPrefix(relayInfo.OriginModelName, "gpt-4o-audio") {
 		service.PostAudioConsumeQuota(c, relayInfo, usage.(*dto.Usage), preConsumedQuota, userQuota, priceData, "")
 	} else {
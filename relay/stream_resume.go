@@ -0,0 +1,58 @@
+package relay
+
+import (
+	"errors"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrStreamInterrupted 上游流式响应中途中断，已输出的内容保存在上下文中等待换渠道续写
+var ErrStreamInterrupted = errors.New("upstream stream interrupted")
+
+// shouldResumeStream 开启续写的分组中，OpenAI 格式的流式对话请求在上游中断后可以续写
+func shouldResumeStream(info *relaycommon.RelayInfo, textRequest *dto.GeneralOpenAIRequest) bool {
+	resumeSetting := operation_setting.GetStreamResumeSetting()
+	if !resumeSetting.Enabled || resumeSetting.MaxResumes <= 0 || !textRequest.Stream {
+		return false
+	}
+	if info.RelayMode != relayconstant.RelayModeChatCompletions || info.RelayFormat != relaycommon.RelayFormatOpenAI {
+		return false
+	}
+	// 多个候选无法拼接
+	if textRequest.N > 1 {
+		return false
+	}
+	return operation_setting.IsStreamResumeGroupEnabled(info.UsingGroup)
+}
+
+// getStreamResume 获取之前各段保存的续写状态，首段返回 nil
+func getStreamResume(c *gin.Context) *relaycommon.StreamResumeInfo {
+	resume, _ := common.GetContextKeyType[*relaycommon.StreamResumeInfo](c, constant.ContextKeyStreamResume)
+	return resume
+}
+
+// appendStreamResumePrefix 将已输出的内容作为助手消息追加到请求末尾，让新渠道接着生成
+func appendStreamResumePrefix(textRequest *dto.GeneralOpenAIRequest, resume *relaycommon.StreamResumeInfo) {
+	if resume.Content == "" {
+		return
+	}
+	message := dto.Message{Role: "assistant"}
+	message.SetStringContent(resume.Content)
+	textRequest.Messages = append(textRequest.Messages, message)
+	if prompt := operation_setting.GetStreamResumeSetting().ContinuePrompt; prompt != "" {
+		continueMessage := dto.Message{Role: "user"}
+		continueMessage.SetStringContent(prompt)
+		textRequest.Messages = append(textRequest.Messages, continueMessage)
+	}
+}
+
+// isStreamResumeSegment 是否为上游中断后换渠道续写的请求
+func isStreamResumeSegment(info *relaycommon.RelayInfo) bool {
+	return info.StreamResume != nil && info.StreamResume.Resumes > 0
+}
@@ -1,6 +1,7 @@
 package router
 
 import (
+	"one-api/common"
 	"one-api/controller"
 	"one-api/middleware"
 
@@ -19,6 +20,7 @@ func SetApiRouter(router *gin.Engine) {
 		apiRouter.GET("/uptime/status", controller.GetUptimeKumaStatus)
 		apiRouter.GET("/models", middleware.UserAuth(), controller.DashboardListModels)
 		apiRouter.GET("/status/test", middleware.AdminAuth(), controller.TestStatus)
+		apiRouter.GET("/status/admission_queue", middleware.AdminAuth(), controller.GetAdmissionQueueStatus)
 		apiRouter.GET("/notice", controller.GetNotice)
 		apiRouter.GET("/about", controller.GetAbout)
 		//apiRouter.GET("/midjourney", controller.GetMidjourney)
@@ -77,6 +79,7 @@ func SetApiRouter(router *gin.Engine) {
 				adminRoute.POST("/", controller.CreateUser)
 				adminRoute.POST("/manage", controller.ManageUser)
 				adminRoute.PUT("/", controller.UpdateUser)
+				adminRoute.PUT("/billing", controller.UpdateUserBillingMode)
 				adminRoute.DELETE("/:id", controller.DeleteUser)
 			}
 		}
@@ -86,6 +89,8 @@ func SetApiRouter(router *gin.Engine) {
 			optionRoute.GET("/", controller.GetOptions)
 			optionRoute.PUT("/", controller.UpdateOption)
 			optionRoute.POST("/rest_model_ratio", controller.ResetModelRatio)
+			optionRoute.POST("/routing_dry_run", controller.DryRunRoutingRules)
+			optionRoute.POST("/migrate_exact_billing", controller.MigrateExactBillingPrices)
 			optionRoute.POST("/migrate_console_setting", controller.MigrateConsoleSetting) // 用于迁移检测的旧键，下个版本会删除
 		}
 		ratioSyncRoute := apiRouter.Group("/ratio_sync")
@@ -101,7 +106,14 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.GET("/search", controller.SearchChannels)
 			channelRoute.GET("/models", controller.ChannelListModels)
 			channelRoute.GET("/models_enabled", controller.EnabledListModels)
+			channelRoute.GET("/breakers", controller.GetChannelBreakers)
+			channelRoute.DELETE("/breakers/:id", controller.ResetChannelBreaker)
+			channelRoute.GET("/stats", controller.GetChannelStats)
 			channelRoute.GET("/:id", controller.GetChannel)
+			channelRoute.GET("/:id/keys", controller.GetChannelKeys)
+			channelRoute.POST("/:id/keys", controller.AppendChannelKeys)
+			channelRoute.PUT("/:id/keys/:index", controller.UpdateChannelKeyStatus)
+			channelRoute.DELETE("/:id/keys/:index", controller.DeleteChannelKey)
 			channelRoute.GET("/test", controller.TestAllChannels)
 			channelRoute.GET("/test/:id", controller.TestChannel)
 			channelRoute.GET("/update_balance", controller.UpdateAllChannelsBalance)
@@ -131,6 +143,65 @@ func SetApiRouter(router *gin.Engine) {
 			tokenRoute.PUT("/", controller.UpdateToken)
 			tokenRoute.DELETE("/:id", controller.DeleteToken)
 			tokenRoute.POST("/batch", controller.DeleteTokenBatch)
+			tokenRoute.POST("/:id/budget", controller.AddTokenBudget)
+			tokenRoute.DELETE("/:id/budget/:budget_id", controller.DeleteTokenBudget)
+		}
+		budgetRoute := apiRouter.Group("/budget")
+		budgetRoute.Use(middleware.AdminAuth())
+		{
+			budgetRoute.GET("/", controller.GetAllBudgets)
+			budgetRoute.POST("/", controller.AddBudget)
+			budgetRoute.PUT("/", controller.UpdateBudget)
+			budgetRoute.DELETE("/:id", controller.DeleteBudget)
+		}
+		invoiceRoute := apiRouter.Group("/invoice")
+		{
+			invoiceRoute.GET("/self", middleware.UserAuth(), controller.GetUserInvoices)
+			invoiceRoute.GET("/self/:id", middleware.UserAuth(), controller.GetUserInvoice)
+			invoiceRoute.GET("/self/:id/export", middleware.UserAuth(), controller.ExportUserInvoice)
+
+			adminRoute := invoiceRoute.Group("/")
+			adminRoute.Use(middleware.AdminAuth())
+			{
+				adminRoute.GET("/", controller.GetAllInvoices)
+				adminRoute.GET("/:id", controller.GetInvoice)
+				adminRoute.GET("/:id/export", controller.ExportInvoice)
+				adminRoute.POST("/generate", controller.GenerateInvoice)
+				adminRoute.PUT("/status", controller.UpdateInvoiceStatus)
+			}
+		}
+		organizationRoute := apiRouter.Group("/organization")
+		{
+			organizationRoute.GET("/", middleware.UserAuth(), controller.GetSelfOrganizations)
+			organizationRoute.POST("/", middleware.UserAuth(), controller.CreateOrganization)
+			organizationRoute.POST("/join", middleware.UserAuth(), controller.JoinOrganization)
+
+			adminRoute := organizationRoute.Group("/")
+			adminRoute.Use(middleware.AdminAuth())
+			{
+				adminRoute.GET("/all", controller.GetAllOrganizations)
+				adminRoute.PUT("/manage", controller.ManageOrganization)
+			}
+
+			memberRoute := organizationRoute.Group("/:id")
+			memberRoute.Use(middleware.UserAuth())
+			{
+				memberRoute.GET("", middleware.OrganizationAuth(common.OrganizationRoleViewer), controller.GetOrganization)
+				memberRoute.PUT("", middleware.OrganizationAuth(common.OrganizationRoleAdmin), controller.UpdateOrganization)
+				memberRoute.DELETE("", middleware.OrganizationAuth(common.OrganizationRoleOwner), controller.DeleteOrganization)
+				memberRoute.POST("/quota", middleware.OrganizationAuth(common.OrganizationRoleAdmin), controller.TransferOrganizationQuota)
+				memberRoute.GET("/members", middleware.OrganizationAuth(common.OrganizationRoleViewer), controller.GetOrganizationMembers)
+				memberRoute.PUT("/members", middleware.OrganizationAuth(common.OrganizationRoleAdmin), controller.UpdateOrganizationMember)
+				memberRoute.POST("/members/:user_id/reset", middleware.OrganizationAuth(common.OrganizationRoleAdmin), controller.ResetOrganizationMemberUsedQuota)
+				memberRoute.DELETE("/members/:user_id", middleware.OrganizationAuth(common.OrganizationRoleViewer), controller.DeleteOrganizationMember)
+				memberRoute.GET("/invitations", middleware.OrganizationAuth(common.OrganizationRoleAdmin), controller.GetOrganizationInvitations)
+				memberRoute.POST("/invitations", middleware.OrganizationAuth(common.OrganizationRoleAdmin), controller.CreateOrganizationInvitation)
+				memberRoute.DELETE("/invitations/:invitation_id", middleware.OrganizationAuth(common.OrganizationRoleAdmin), controller.DeleteOrganizationInvitation)
+				memberRoute.GET("/tokens", middleware.OrganizationAuth(common.OrganizationRoleAdmin), controller.GetOrganizationTokens)
+				memberRoute.DELETE("/tokens/:token_id", middleware.OrganizationAuth(common.OrganizationRoleAdmin), controller.DeleteOrganizationToken)
+				memberRoute.GET("/logs", middleware.OrganizationAuth(common.OrganizationRoleViewer), controller.GetOrganizationLogs)
+				memberRoute.GET("/usage", middleware.OrganizationAuth(common.OrganizationRoleViewer), controller.GetOrganizationUsage)
+			}
 		}
 		redemptionRoute := apiRouter.Group("/redemption")
 		redemptionRoute.Use(middleware.AdminAuth())
@@ -18,6 +18,7 @@ func SetRelayRouter(router *gin.Engine) {
 	{
 		modelsRouter.GET("", controller.ListModels)
 		modelsRouter.GET("/:model", controller.RetrieveModel)
+		modelsRouter.DELETE("/:model", controller.DeleteFineTunedModel)
 	}
 	playgroundRouter := router.Group("/pg")
 	playgroundRouter.Use(middleware.UserAuth(), middleware.Distribute())
@@ -50,21 +51,46 @@ func SetRelayRouter(router *gin.Engine) {
 		httpRouter.POST("/audio/translations", controller.Relay)
 		httpRouter.POST("/audio/speech", controller.Relay)
 		httpRouter.POST("/responses", controller.Relay)
-		httpRouter.GET("/files", controller.RelayNotImplemented)
-		httpRouter.POST("/files", controller.RelayNotImplemented)
-		httpRouter.DELETE("/files/:id", controller.RelayNotImplemented)
-		httpRouter.GET("/files/:id", controller.RelayNotImplemented)
-		httpRouter.GET("/files/:id/content", controller.RelayNotImplemented)
-		httpRouter.POST("/fine-tunes", controller.RelayNotImplemented)
-		httpRouter.GET("/fine-tunes", controller.RelayNotImplemented)
-		httpRouter.GET("/fine-tunes/:id", controller.RelayNotImplemented)
-		httpRouter.POST("/fine-tunes/:id/cancel", controller.RelayNotImplemented)
-		httpRouter.GET("/fine-tunes/:id/events", controller.RelayNotImplemented)
-		httpRouter.DELETE("/models/:model", controller.RelayNotImplemented)
 		httpRouter.POST("/moderations", controller.Relay)
 		httpRouter.POST("/rerank", controller.Relay)
 		httpRouter.POST("/models/*path", controller.Relay)
 	}
+	{
+		// 文件、批处理等接口不携带 model，不经过 Distribute
+		fileRouter := relayV1Router.Group("/files")
+		fileRouter.GET("", controller.ListFiles)
+		fileRouter.POST("", controller.UploadFile)
+		fileRouter.GET("/:id", controller.RetrieveFile)
+		fileRouter.DELETE("/:id", controller.DeleteFile)
+		fileRouter.GET("/:id/content", controller.RetrieveFileContent)
+
+		batchRouter := relayV1Router.Group("/batches")
+		batchRouter.GET("", controller.ListBatches)
+		batchRouter.POST("", controller.CreateBatch)
+		batchRouter.GET("/:id", controller.RetrieveBatch)
+		batchRouter.POST("/:id/cancel", controller.CancelBatch)
+
+		// 读取和删除本地保存的 Responses 会话状态
+		responsesRouter := relayV1Router.Group("/responses")
+		responsesRouter.GET("/:id", controller.RetrieveResponse)
+		responsesRouter.DELETE("/:id", controller.DeleteResponse)
+
+		// 微调任务绑定到创建时的渠道，旧版 /fine-tunes 与 /fine_tuning/jobs 共用处理逻辑
+		legacyFineTuneRouter := relayV1Router.Group("/fine-tunes")
+		legacyFineTuneRouter.POST("", controller.CreateFineTuneJob)
+		legacyFineTuneRouter.GET("", controller.ListFineTuneJobs)
+		legacyFineTuneRouter.GET("/:id", controller.RetrieveFineTuneJob)
+		legacyFineTuneRouter.POST("/:id/cancel", controller.CancelFineTuneJob)
+		legacyFineTuneRouter.GET("/:id/events", controller.ListFineTuneEvents)
+
+		fineTuneRouter := relayV1Router.Group("/fine_tuning/jobs")
+		fineTuneRouter.POST("", controller.CreateFineTuneJob)
+		fineTuneRouter.GET("", controller.ListFineTuneJobs)
+		fineTuneRouter.GET("/:id", controller.RetrieveFineTuneJob)
+		fineTuneRouter.POST("/:id/cancel", controller.CancelFineTuneJob)
+		fineTuneRouter.GET("/:id/events", controller.ListFineTuneEvents)
+		fineTuneRouter.GET("/:id/checkpoints", controller.ListFineTuneCheckpoints)
+	}
 
 	relayMjRouter := router.Group("/mj")
 	registerMjRouterGroup(relayMjRouter)
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"container/heap"
+	"context"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/setting/operation_setting"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// admissionWaiter 排队中的请求，优先级高的先服务，同优先级按入队顺序
+type admissionWaiter struct {
+	priority int
+	seq      int64
+	enqueued time.Time
+	ready    chan struct{}
+	index    int
+}
+
+type admissionHeap []*admissionWaiter
+
+func (h admissionHeap) Len() int { return len(h) }
+
+func (h admissionHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h admissionHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *admissionHeap) Push(x any) {
+	waiter := x.(*admissionWaiter)
+	waiter.index = len(*h)
+	*h = append(*h, waiter)
+}
+
+func (h *admissionHeap) Pop() any {
+	old := *h
+	waiter := old[len(old)-1]
+	old[len(old)-1] = nil
+	waiter.index = -1
+	*h = old[:len(old)-1]
+	return waiter
+}
+
+// AdmissionQueueStats 排队统计，各节点分别统计
+type AdmissionQueueStats struct {
+	Depth         int            `json:"depth"`
+	Groups        map[string]int `json:"groups"`     // 各分组排队中的请求数
+	Priorities    map[int]int    `json:"priorities"` // 各优先级排队中的请求数
+	OldestWaitMs  int64          `json:"oldest_wait_ms"`
+	QueuedTotal   int64          `json:"queued_total"`
+	AdmittedTotal int64          `json:"admitted_total"` // 排队后成功完成的请求数
+	RejectedTotal int64          `json:"rejected_total"` // 队列已满被拒绝的请求数
+	TimeoutTotal  int64          `json:"timeout_total"`  // 等待超时的请求数
+}
+
+var (
+	admissionLock     sync.Mutex
+	admissionQueues   = make(map[string]*admissionHeap)
+	admissionDepth    int
+	admissionSeq      int64
+	admissionDispatch sync.Once
+
+	admissionQueuedTotal   atomic.Int64
+	admissionAdmittedTotal atomic.Int64
+	admissionRejectedTotal atomic.Int64
+	admissionTimeoutTotal  atomic.Int64
+)
+
+// AdmissionTicket 请求在排队期间的凭证，重新入队时保持原有的顺序
+type AdmissionTicket struct {
+	group    string
+	waiter   *admissionWaiter
+	deadline time.Time
+}
+
+// NewAdmissionTicket 为请求创建排队凭证，队列已满时返回 false
+func NewAdmissionTicket(c *gin.Context, group string) (*AdmissionTicket, bool) {
+	queueSetting := operation_setting.GetAdmissionQueueSetting()
+	admissionLock.Lock()
+	defer admissionLock.Unlock()
+	if queueSetting.MaxQueueLength > 0 && admissionDepth >= queueSetting.MaxQueueLength {
+		admissionRejectedTotal.Add(1)
+		return nil, false
+	}
+	admissionSeq++
+	admissionQueuedTotal.Add(1)
+	admissionDispatch.Do(func() {
+		go admissionDispatcher()
+	})
+	return &AdmissionTicket{
+		group: group,
+		waiter: &admissionWaiter{
+			priority: operation_setting.GetAdmissionPriority(c.GetInt("token_id"), common.GetContextKeyString(c, constant.ContextKeyUserGroup)),
+			seq:      admissionSeq,
+			enqueued: time.Now(),
+			index:    -1,
+		},
+		deadline: time.Now().Add(time.Duration(queueSetting.MaxWaitSeconds) * time.Second),
+	}, true
+}
+
+// Wait 排队直到轮到该请求，等待超时或客户端断开时返回 false
+func (t *AdmissionTicket) Wait(ctx context.Context) bool {
+	remaining := time.Until(t.deadline)
+	if remaining <= 0 {
+		admissionTimeoutTotal.Add(1)
+		return false
+	}
+	admissionLock.Lock()
+	queue, ok := admissionQueues[t.group]
+	if !ok {
+		queue = &admissionHeap{}
+		admissionQueues[t.group] = queue
+	}
+	t.waiter.ready = make(chan struct{})
+	heap.Push(queue, t.waiter)
+	admissionDepth++
+	admissionLock.Unlock()
+
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+	select {
+	case <-t.waiter.ready:
+		return true
+	case <-timer.C:
+		admissionTimeoutTotal.Add(1)
+	case <-ctx.Done():
+	}
+	admissionLock.Lock()
+	defer admissionLock.Unlock()
+	if t.waiter.index < 0 {
+		// 超时的同时已被唤醒，仍然让它尝试
+		return true
+	}
+	heap.Remove(queue, t.waiter.index)
+	admissionDepth--
+	return false
+}
+
+// Admitted 排队的请求最终成功
+func (t *AdmissionTicket) Admitted() {
+	admissionAdmittedTotal.Add(1)
+}
+
+// wakeAdmissionHead 唤醒分组队首的请求，调用方需持有锁
+func wakeAdmissionHead(group string) {
+	queue, ok := admissionQueues[group]
+	if !ok || queue.Len() == 0 {
+		return
+	}
+	waiter := heap.Pop(queue).(*admissionWaiter)
+	admissionDepth--
+	close(waiter.ready)
+}
+
+// NotifyAdmissionQueue 分组中有请求成功完成，说明上游有余量，让队首的请求尝试
+func NotifyAdmissionQueue(group string) {
+	admissionLock.Lock()
+	defer admissionLock.Unlock()
+	wakeAdmissionHead(group)
+}
+
+// admissionDispatcher 没有请求完成时定期让各分组队首的请求重新尝试
+func admissionDispatcher() {
+	for {
+		interval := operation_setting.GetAdmissionQueueSetting().RetryIntervalMs
+		if interval <= 0 {
+			interval = 1000
+		}
+		time.Sleep(time.Duration(interval) * time.Millisecond)
+		admissionLock.Lock()
+		for group := range admissionQueues {
+			wakeAdmissionHead(group)
+		}
+		admissionLock.Unlock()
+	}
+}
+
+// GetAdmissionQueueStats 获取当前节点的排队情况
+func GetAdmissionQueueStats() AdmissionQueueStats {
+	admissionLock.Lock()
+	defer admissionLock.Unlock()
+	stats := AdmissionQueueStats{
+		Depth:         admissionDepth,
+		Groups:        make(map[string]int),
+		Priorities:    make(map[int]int),
+		QueuedTotal:   admissionQueuedTotal.Load(),
+		AdmittedTotal: admissionAdmittedTotal.Load(),
+		RejectedTotal: admissionRejectedTotal.Load(),
+		TimeoutTotal:  admissionTimeoutTotal.Load(),
+	}
+	now := time.Now()
+	for group, queue := range admissionQueues {
+		if queue.Len() == 0 {
+			continue
+		}
+		stats.Groups[group] = queue.Len()
+		for _, waiter := range *queue {
+			stats.Priorities[waiter.priority]++
+			if wait := now.Sub(waiter.enqueued).Milliseconds(); wait > stats.OldestWaitMs {
+				stats.OldestWaitMs = wait
+			}
+		}
+	}
+	return stats
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+// ErrBudgetExceeded 周期预算已用尽
+var ErrBudgetExceeded = errors.New("spending budget exceeded")
+
+func budgetName(budget *model.Budget) string {
+	switch budget.Scope {
+	case model.BudgetScopeToken:
+		return fmt.Sprintf("令牌 #%d 的%s预算", budget.TokenId, budgetPeriodName(budget.Period))
+	case model.BudgetScopeGroup:
+		return fmt.Sprintf("分组 %s 的%s预算", budget.Group, budgetPeriodName(budget.Period))
+	default:
+		return fmt.Sprintf("用户%s预算", budgetPeriodName(budget.Period))
+	}
+}
+
+func budgetPeriodName(period string) string {
+	switch period {
+	case model.BudgetPeriodWeekly:
+		return "每周"
+	case model.BudgetPeriodMonthly:
+		return "每月"
+	default:
+		return "每日"
+	}
+}
+
+// CheckSpendingBudgets 检查请求匹配的周期预算，已用额度加上预扣额度超过上限时拒绝，超过提醒比例时通知一次
+func CheckSpendingBudgets(relayInfo *relaycommon.RelayInfo, quota int) error {
+	budgets, err := model.GetMatchingBudgets(relayInfo.UserId, relayInfo.TokenId, relayInfo.UsingGroup)
+	if err != nil {
+		return err
+	}
+	for _, budget := range budgets {
+		if budget.Used >= budget.QuotaLimit || budget.Used+quota > budget.QuotaLimit {
+			return fmt.Errorf("%w: %s已用 %s / %s，将于 %s 重置", ErrBudgetExceeded, budgetName(budget),
+				common.FormatQuota(budget.Used), common.FormatQuota(budget.QuotaLimit),
+				time.Unix(budget.ResetTime, 0).Format("2006-01-02 15:04"))
+		}
+		if softLimit := budget.SoftLimit(); softLimit > 0 && !budget.SoftNotified && budget.Used >= softLimit {
+			notifyBudgetSoftLimit(relayInfo, budget)
+		}
+	}
+	return nil
+}
+
+func notifyBudgetSoftLimit(relayInfo *relaycommon.RelayInfo, budget *model.Budget) {
+	gopool.Go(func() {
+		if !model.MarkBudgetSoftNotified(budget) {
+			return
+		}
+		prompt := "消费预算即将用尽"
+		name := budgetName(budget)
+		if budget.Scope == model.BudgetScopeGroup {
+			NotifyRootUser(dto.NotifyTypeBudgetSoftLimit, prompt, fmt.Sprintf("%s已用 %s / %s", name,
+				common.FormatQuota(budget.Used), common.FormatQuota(budget.QuotaLimit)))
+			return
+		}
+		content := "{{value}}，{{value}}已用 {{value}} / {{value}}，用尽后请求将被拒绝，直到 {{value}} 重置。"
+		err := NotifyUser(relayInfo.UserId, relayInfo.UserEmail, relayInfo.UserSetting, dto.NewNotify(dto.NotifyTypeBudgetSoftLimit, prompt, content,
+			[]interface{}{prompt, name, common.FormatQuota(budget.Used), common.FormatQuota(budget.QuotaLimit), time.Unix(budget.ResetTime, 0).Format("2006-01-02 15:04")}))
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to send budget notify to user %d: %s", relayInfo.UserId, err.Error()))
+		}
+	})
+}
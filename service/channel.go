@@ -7,9 +7,13 @@ import (
 	"one-api/constant"
 	"one-api/dto"
 	"one-api/model"
+	relaycommon "one-api/relay/common"
 	"one-api/setting/operation_setting"
 	"one-api/types"
 	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
 )
 
 func formatNotifyType(channelId int, status int) string {
@@ -99,3 +103,92 @@ func ShouldEnableChannel(newAPIError *types.NewAPIError, status int) bool {
 	}
 	return true
 }
+
+// RecordChannelRelaySuccess 记录在线请求的首字时间和输出速度，用于自适应渠道选择，并扣除所用 Key 的 TPM 额度
+func RecordChannelRelaySuccess(relayInfo *relaycommon.RelayInfo, usage *dto.Usage) {
+	if relayInfo.ResponseCacheHit || relayInfo.BatchSettle {
+		return
+	}
+	completionTokens := usage.CompletionTokens
+	model.RecordChannelKeyTokens(relayInfo.ChannelId, relayInfo.ChannelKeyIndex, usage.PromptTokens+usage.CompletionTokens)
+	now := time.Now()
+	attemptStart := relayInfo.AttemptStartTime
+	if attemptStart.IsZero() {
+		attemptStart = relayInfo.StartTime
+	}
+	ttft := now.Sub(attemptStart)
+	generateStart := attemptStart
+	if relayInfo.HasSendResponse() && relayInfo.FirstResponseTime.After(attemptStart) {
+		ttft = relayInfo.FirstResponseTime.Sub(attemptStart)
+		generateStart = relayInfo.FirstResponseTime
+	}
+	var tokensPerSecond float64
+	if seconds := now.Sub(generateStart).Seconds(); completionTokens > 0 && seconds > 0 {
+		tokensPerSecond = float64(completionTokens) / seconds
+	}
+	model.RecordChannelStats(relayInfo.ChannelId, relayInfo.OriginModelName, true, ttft, tokensPerSecond)
+}
+
+// isChannelFailure 是否为渠道自身的失败，本地错误和请求参数错误不计入，多 Key 渠道的 429 由 Key 冷却处理，也不计入
+func isChannelFailure(err *types.NewAPIError, isMultiKey bool) bool {
+	if err == nil || types.IsLocalError(err) {
+		return false
+	}
+	if isMultiKey && err.StatusCode == http.StatusTooManyRequests {
+		return false
+	}
+	if !types.IsChannelError(err) && err.StatusCode/100 == 4 {
+		switch err.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// RecordChannelRelayError 记录渠道失败，用于自适应渠道选择
+func RecordChannelRelayError(channel *model.Channel, modelName string, err *types.NewAPIError) {
+	if !isChannelFailure(err, channel.ChannelInfo.IsMultiKey) {
+		return
+	}
+	model.RecordChannelStats(channel.Id, modelName, false, 0, 0)
+}
+
+// RecordChannelBreakerFailure 渠道失败时更新渠道及所用 Key 的熔断状态
+func RecordChannelBreakerFailure(channelError types.ChannelError, err *types.NewAPIError) {
+	if !isChannelFailure(err, channelError.IsMultiKey) {
+		return
+	}
+	keyIndex := model.GetChannelKeyIndex(channelError.ChannelId, channelError.UsingKey)
+	model.RecordChannelBreakerResult(channelError.ChannelId, keyIndex, false, err.Error())
+}
+
+// CooldownChannelKey 多 Key 渠道的 Key 被上游限流时让该 Key 暂时冷却
+func CooldownChannelKey(channelError types.ChannelError, err *types.NewAPIError) {
+	if err.StatusCode != http.StatusTooManyRequests || types.IsLocalError(err) {
+		return
+	}
+	keyIndex := model.GetChannelKeyIndex(channelError.ChannelId, channelError.UsingKey)
+	if keyIndex >= 0 && model.CooldownChannelKey(channelError.ChannelId, keyIndex) {
+		common.SysLog(fmt.Sprintf("channel #%d key #%d is rate limited, cooling down", channelError.ChannelId, keyIndex))
+	}
+}
+
+// RecordChannelKeyError 记录多 Key 渠道所用 Key 的失败次数和最近一次错误
+func RecordChannelKeyError(channelError types.ChannelError, err *types.NewAPIError) {
+	// 单个 Key 的统计同样记录限流
+	if !isChannelFailure(err, false) {
+		return
+	}
+	model.RecordChannelKeyError(channelError.ChannelId, model.GetChannelKeyIndex(channelError.ChannelId, channelError.UsingKey), err.Error())
+}
+
+// RecordChannelBreakerSuccess 请求成功时更新当前渠道及所用 Key 的熔断状态
+func RecordChannelBreakerSuccess(c *gin.Context) {
+	keyIndex := -1
+	if common.GetContextKeyBool(c, constant.ContextKeyChannelIsMultiKey) {
+		keyIndex = common.GetContextKeyInt(c, constant.ContextKeyChannelMultiKeyIndex)
+	}
+	model.RecordChannelBreakerResult(common.GetContextKeyInt(c, constant.ContextKeyChannelId), keyIndex, true, "")
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/types"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 等待并发名额时的检查间隔
+const channelConcurrencyPollInterval = 50 * time.Millisecond
+
+// AcquireChannelConcurrency 占用当前所选渠道的并发名额，已满时最多等待渠道设置的时间，返回请求结束时调用的释放函数
+func AcquireChannelConcurrency(c *gin.Context, channel *model.Channel) (func(), *types.NewAPIError) {
+	setting, _ := common.GetContextKeyType[dto.ChannelSettings](c, constant.ContextKeyChannelSetting)
+	if setting.MaxConcurrency <= 0 {
+		return func() {}, nil
+	}
+	deadline := time.Now().Add(time.Duration(setting.ConcurrencyWaitMs) * time.Millisecond)
+	for {
+		if member, ok := model.TryAcquireChannelConcurrency(channel.Id, setting.MaxConcurrency); ok {
+			stopLease := model.KeepChannelConcurrencyLease(channel.Id, member)
+			return func() {
+				stopLease()
+				model.ReleaseChannelConcurrency(channel.Id, member)
+			}, nil
+		}
+		if !time.Now().Before(deadline) {
+			break
+		}
+		select {
+		case <-c.Request.Context().Done():
+			return nil, types.NewErrorWithStatusCode(c.Request.Context().Err(), types.ErrorCodeChannelSaturated, http.StatusTooManyRequests)
+		case <-time.After(channelConcurrencyPollInterval):
+		}
+	}
+	return nil, types.NewErrorWithStatusCode(fmt.Errorf("channel #%d reached max concurrency %d", channel.Id, setting.MaxConcurrency), types.ErrorCodeChannelSaturated, http.StatusTooManyRequests)
+}
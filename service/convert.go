@@ -438,3 +438,922 @@ func toJSONString(v interface{}) string {
 	}
 	return string(b)
 }
+
+// GeminiToOpenAIRequest 将 Gemini 原生请求转换为 OpenAI 请求，用于非 Gemini 渠道
+func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycommon.RelayInfo) (*dto.GeneralOpenAIRequest, error) {
+	generationConfig := geminiRequest.GenerationConfig
+	openAIRequest := dto.GeneralOpenAIRequest{
+		Model:       info.UpstreamModelName,
+		Stream:      info.IsStream,
+		MaxTokens:   generationConfig.MaxOutputTokens,
+		Temperature: generationConfig.Temperature,
+		TopP:        generationConfig.TopP,
+		TopK:        int(generationConfig.TopK),
+		N:           generationConfig.CandidateCount,
+		Seed:        float64(generationConfig.Seed),
+	}
+	if len(generationConfig.StopSequences) == 1 {
+		openAIRequest.Stop = generationConfig.StopSequences[0]
+	} else if len(generationConfig.StopSequences) > 1 {
+		openAIRequest.Stop = generationConfig.StopSequences
+	}
+	if generationConfig.ResponseMimeType == "application/json" {
+		if generationConfig.ResponseSchema != nil {
+			openAIRequest.ResponseFormat = &dto.ResponseFormat{
+				Type: "json_schema",
+				JsonSchema: &dto.FormatJsonSchema{
+					Name:   "response",
+					Schema: normalizeGeminiSchema(generationConfig.ResponseSchema),
+				},
+			}
+		} else {
+			openAIRequest.ResponseFormat = &dto.ResponseFormat{Type: "json_object"}
+		}
+	}
+
+	// Convert tools, googleSearch 等内置工具在 OpenAI 格式下没有对应，忽略
+	openAITools := make([]dto.ToolCallRequest, 0)
+	for _, tool := range geminiRequest.Tools {
+		if tool.FunctionDeclarations == nil {
+			continue
+		}
+		functions, err := common.Any2Type[[]dto.FunctionRequest](tool.FunctionDeclarations)
+		if err != nil {
+			return nil, fmt.Errorf("invalid functionDeclarations: %w", err)
+		}
+		for _, function := range functions {
+			function.Parameters = normalizeGeminiSchema(function.Parameters)
+			openAITools = append(openAITools, dto.ToolCallRequest{
+				Type:     "function",
+				Function: function,
+			})
+		}
+	}
+	if len(openAITools) > 0 {
+		openAIRequest.Tools = openAITools
+	}
+
+	openAIMessages := make([]dto.Message, 0, len(geminiRequest.Contents)+1)
+	if geminiRequest.SystemInstructions != nil {
+		var systemTexts []string
+		for _, part := range geminiRequest.SystemInstructions.Parts {
+			if part.Text != "" {
+				systemTexts = append(systemTexts, part.Text)
+			}
+		}
+		if len(systemTexts) > 0 {
+			openAIMessage := dto.Message{
+				Role: "system",
+			}
+			openAIMessage.SetStringContent(strings.Join(systemTexts, "\n"))
+			openAIMessages = append(openAIMessages, openAIMessage)
+		}
+	}
+
+	// Gemini 的函数调用没有 id，按函数名依次生成并与 functionResponse 配对
+	pendingCallIds := make(map[string][]string)
+	callCount := 0
+	for _, content := range geminiRequest.Contents {
+		role := content.Role
+		if role == "model" {
+			role = "assistant"
+		} else if role == "" || role == "function" {
+			role = "user"
+		}
+		openAIMessage := dto.Message{
+			Role: role,
+		}
+		var toolCalls []dto.ToolCallRequest
+		var toolMessages []dto.Message
+		mediaMessages := make([]dto.MediaContent, 0, len(content.Parts))
+		for _, part := range content.Parts {
+			switch {
+			case part.FunctionCall != nil:
+				callCount++
+				callId := fmt.Sprintf("call_%d_%s", callCount, part.FunctionCall.FunctionName)
+				pendingCallIds[part.FunctionCall.FunctionName] = append(pendingCallIds[part.FunctionCall.FunctionName], callId)
+				toolCalls = append(toolCalls, dto.ToolCallRequest{
+					ID:   callId,
+					Type: "function",
+					Function: dto.FunctionRequest{
+						Name:      part.FunctionCall.FunctionName,
+						Arguments: toJSONString(part.FunctionCall.Arguments),
+					},
+				})
+			case part.FunctionResponse != nil:
+				name := part.FunctionResponse.Name
+				callId := ""
+				if ids := pendingCallIds[name]; len(ids) > 0 {
+					callId = ids[0]
+					pendingCallIds[name] = ids[1:]
+				}
+				toolMessage := dto.Message{
+					Role:       "tool",
+					Name:       common.GetPointer[string](name),
+					ToolCallId: callId,
+				}
+				toolMessage.SetStringContent(toJSONString(part.FunctionResponse.Response))
+				toolMessages = append(toolMessages, toolMessage)
+			case part.InlineData != nil:
+				mediaMessages = append(mediaMessages, geminiInlineDataToMediaContent(part.InlineData))
+			case part.FileData != nil:
+				mediaMessages = append(mediaMessages, geminiFileDataToMediaContent(part.FileData))
+			case part.ExecutableCode != nil:
+				mediaMessages = append(mediaMessages, dto.MediaContent{
+					Type: dto.ContentTypeText,
+					Text: "```" + part.ExecutableCode.Language + "\n" + part.ExecutableCode.Code + "\n```",
+				})
+			case part.CodeExecutionResult != nil:
+				mediaMessages = append(mediaMessages, dto.MediaContent{
+					Type: dto.ContentTypeText,
+					Text: "```output\n" + part.CodeExecutionResult.Output + "\n```",
+				})
+			case part.Thought:
+				// 思考内容不回传给上游
+			case part.Text != "":
+				mediaMessages = append(mediaMessages, dto.MediaContent{
+					Type: dto.ContentTypeText,
+					Text: part.Text,
+				})
+			}
+		}
+		// 工具结果需紧跟在对应的 assistant 消息之后
+		openAIMessages = append(openAIMessages, toolMessages...)
+		if len(toolCalls) > 0 {
+			openAIMessage.SetToolCalls(toolCalls)
+		}
+		if len(mediaMessages) == 1 && mediaMessages[0].Type == dto.ContentTypeText {
+			openAIMessage.SetStringContent(mediaMessages[0].Text)
+		} else if len(mediaMessages) > 0 {
+			openAIMessage.SetMediaContent(mediaMessages)
+		}
+		if len(mediaMessages) > 0 || len(toolCalls) > 0 {
+			openAIMessages = append(openAIMessages, openAIMessage)
+		}
+	}
+	openAIRequest.Messages = openAIMessages
+
+	return &openAIRequest, nil
+}
+
+func geminiInlineDataToMediaContent(inlineData *dto.GeminiInlineData) dto.MediaContent {
+	dataUrl := fmt.Sprintf("data:%s;base64,%s", inlineData.MimeType, inlineData.Data)
+	switch {
+	case strings.HasPrefix(inlineData.MimeType, "image/"):
+		return dto.MediaContent{
+			Type:     dto.ContentTypeImageURL,
+			ImageUrl: &dto.MessageImageUrl{Url: dataUrl},
+		}
+	case strings.HasPrefix(inlineData.MimeType, "audio/"):
+		format := strings.TrimPrefix(inlineData.MimeType, "audio/")
+		if format == "mpeg" {
+			format = "mp3"
+		}
+		return dto.MediaContent{
+			Type: dto.ContentTypeInputAudio,
+			InputAudio: &dto.MessageInputAudio{
+				Data:   inlineData.Data,
+				Format: format,
+			},
+		}
+	default:
+		return dto.MediaContent{
+			Type: dto.ContentTypeFile,
+			File: &dto.MessageFile{FileData: dataUrl},
+		}
+	}
+}
+
+func geminiFileDataToMediaContent(fileData *dto.GeminiFileData) dto.MediaContent {
+	switch {
+	case strings.HasPrefix(fileData.MimeType, "image/"):
+		return dto.MediaContent{
+			Type:     dto.ContentTypeImageURL,
+			ImageUrl: &dto.MessageImageUrl{Url: fileData.FileUri},
+		}
+	case strings.HasPrefix(fileData.MimeType, "video/"):
+		return dto.MediaContent{
+			Type:     dto.ContentTypeVideoUrl,
+			VideoUrl: &dto.MessageVideoUrl{Url: fileData.FileUri},
+		}
+	default:
+		return dto.MediaContent{
+			Type: dto.ContentTypeFile,
+			File: &dto.MessageFile{FileData: fileData.FileUri},
+		}
+	}
+}
+
+// normalizeGeminiSchema Gemini 的 schema 类型为大写（如 OBJECT），OpenAI 要求小写
+func normalizeGeminiSchema(schema any) any {
+	switch v := schema.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(v))
+		for key, value := range v {
+			if typeName, ok := value.(string); ok && key == "type" {
+				result[key] = strings.ToLower(typeName)
+			} else {
+				result[key] = normalizeGeminiSchema(value)
+			}
+		}
+		return result
+	case []any:
+		result := make([]any, len(v))
+		for i, value := range v {
+			result[i] = normalizeGeminiSchema(value)
+		}
+		return result
+	default:
+		return schema
+	}
+}
+
+func finishReasonOpenAI2Gemini(reason string) string {
+	switch reason {
+	case "length":
+		return "MAX_TOKENS"
+	case "content_filter":
+		return "SAFETY"
+	default:
+		return "STOP"
+	}
+}
+
+func usageOpenAI2Gemini(usage *dto.Usage) dto.GeminiUsageMetadata {
+	reasoningTokens := usage.CompletionTokenDetails.ReasoningTokens
+	return dto.GeminiUsageMetadata{
+		PromptTokenCount:     usage.PromptTokens,
+		CandidatesTokenCount: usage.CompletionTokens - reasoningTokens,
+		ThoughtsTokenCount:   reasoningTokens,
+		TotalTokenCount:      usage.PromptTokens + usage.CompletionTokens,
+	}
+}
+
+func toolCallToGeminiPart(name string, arguments string) dto.GeminiPart {
+	var args any
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil || args == nil {
+		args = map[string]any{}
+	}
+	return dto.GeminiPart{
+		FunctionCall: &dto.GeminiFunctionCall{
+			FunctionName: name,
+			Arguments:    args,
+		},
+	}
+}
+
+func ResponseOpenAI2Gemini(openAIResponse *dto.OpenAITextResponse, info *relaycommon.RelayInfo) *dto.GeminiChatResponse {
+	geminiResponse := &dto.GeminiChatResponse{
+		Candidates:    make([]dto.GeminiChatCandidate, 0, len(openAIResponse.Choices)),
+		UsageMetadata: usageOpenAI2Gemini(&openAIResponse.Usage),
+	}
+	for _, choice := range openAIResponse.Choices {
+		parts := make([]dto.GeminiPart, 0)
+		reasoning := choice.Message.ReasoningContent
+		if reasoning == "" {
+			reasoning = choice.Message.Reasoning
+		}
+		if reasoning != "" {
+			parts = append(parts, dto.GeminiPart{Text: reasoning, Thought: true})
+		}
+		if text := choice.Message.StringContent(); text != "" {
+			parts = append(parts, dto.GeminiPart{Text: text})
+		}
+		for _, toolCall := range choice.Message.ParseToolCalls() {
+			parts = append(parts, toolCallToGeminiPart(toolCall.Function.Name, toolCall.Function.Arguments))
+		}
+		geminiResponse.Candidates = append(geminiResponse.Candidates, dto.GeminiChatCandidate{
+			Content: dto.GeminiChatContent{
+				Role:  "model",
+				Parts: parts,
+			},
+			FinishReason: common.GetPointer[string](finishReasonOpenAI2Gemini(choice.FinishReason)),
+			Index:        int64(choice.Index),
+		})
+	}
+	return geminiResponse
+}
+
+// StreamResponseOpenAI2Gemini 转换单个 OpenAI 流式分片，无内容可发送时返回 nil
+func StreamResponseOpenAI2Gemini(openAIResponse *dto.ChatCompletionsStreamResponse, info *relaycommon.RelayInfo) *dto.GeminiChatResponse {
+	convertInfo := info.GeminiConvertInfo
+	candidates := make([]dto.GeminiChatCandidate, 0, len(openAIResponse.Choices))
+	for _, choice := range openAIResponse.Choices {
+		parts := make([]dto.GeminiPart, 0)
+		if reasoning := choice.Delta.GetReasoningContent(); reasoning != "" {
+			parts = append(parts, dto.GeminiPart{Text: reasoning, Thought: true})
+		}
+		if text := choice.Delta.GetContentString(); text != "" {
+			parts = append(parts, dto.GeminiPart{Text: text})
+		}
+		for _, toolCall := range choice.Delta.ToolCalls {
+			index := len(convertInfo.ToolCalls)
+			if toolCall.Index != nil {
+				index = *toolCall.Index
+			} else if toolCall.ID == "" && index > 0 {
+				index--
+			}
+			if index < 0 {
+				index = 0
+			}
+			for len(convertInfo.ToolCalls) <= index {
+				convertInfo.ToolCalls = append(convertInfo.ToolCalls, dto.ToolCallResponse{})
+			}
+			if toolCall.Function.Name != "" {
+				convertInfo.ToolCalls[index].Function.Name = toolCall.Function.Name
+			}
+			convertInfo.ToolCalls[index].Function.Arguments += toolCall.Function.Arguments
+		}
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			convertInfo.FinishReason = *choice.FinishReason
+		}
+		if len(parts) > 0 {
+			candidates = append(candidates, dto.GeminiChatCandidate{
+				Content: dto.GeminiChatContent{
+					Role:  "model",
+					Parts: parts,
+				},
+				Index: int64(choice.Index),
+			})
+		}
+	}
+
+	if convertInfo.Done {
+		// 结束时输出累积的工具调用和结束原因
+		parts := make([]dto.GeminiPart, 0, len(convertInfo.ToolCalls))
+		for _, toolCall := range convertInfo.ToolCalls {
+			if toolCall.Function.Name != "" {
+				parts = append(parts, toolCallToGeminiPart(toolCall.Function.Name, toolCall.Function.Arguments))
+			}
+		}
+		convertInfo.ToolCalls = nil
+		finishReason := common.GetPointer[string](finishReasonOpenAI2Gemini(convertInfo.FinishReason))
+		if len(candidates) > 0 {
+			candidates[0].Content.Parts = append(candidates[0].Content.Parts, parts...)
+			candidates[0].FinishReason = finishReason
+		} else {
+			candidates = append(candidates, dto.GeminiChatCandidate{
+				Content: dto.GeminiChatContent{
+					Role:  "model",
+					Parts: parts,
+				},
+				FinishReason: finishReason,
+			})
+		}
+	}
+
+	if len(candidates) == 0 && openAIResponse.Usage == nil {
+		return nil
+	}
+	geminiResponse := &dto.GeminiChatResponse{
+		Candidates: candidates,
+	}
+	if openAIResponse.Usage != nil {
+		geminiResponse.UsageMetadata = usageOpenAI2Gemini(openAIResponse.Usage)
+	}
+	return geminiResponse
+}
+
+// ResponsesToOpenAIRequest 将 /v1/responses 请求转换为 chat completions 请求，用于不支持 Responses API 的渠道
+func ResponsesToOpenAIRequest(responsesRequest *dto.OpenAIResponsesRequest, info *relaycommon.RelayInfo) (*dto.GeneralOpenAIRequest, error) {
+	openAIRequest := dto.GeneralOpenAIRequest{
+		Model:     info.UpstreamModelName,
+		Stream:    info.IsStream,
+		MaxTokens: responsesRequest.MaxOutputTokens,
+		TopP:      responsesRequest.TopP,
+		User:      responsesRequest.User,
+	}
+	if responsesRequest.Temperature != 0 {
+		openAIRequest.Temperature = common.GetPointer[float64](responsesRequest.Temperature)
+	}
+	if responsesRequest.Reasoning != nil {
+		openAIRequest.ReasoningEffort = responsesRequest.Reasoning.Effort
+	}
+
+	if len(responsesRequest.Text) > 0 {
+		var text struct {
+			Format *struct {
+				Type        string `json:"type"`
+				Name        string `json:"name"`
+				Description string `json:"description"`
+				Schema      any    `json:"schema"`
+				Strict      any    `json:"strict"`
+			} `json:"format"`
+		}
+		if err := common.Unmarshal(responsesRequest.Text, &text); err != nil {
+			return nil, fmt.Errorf("invalid text: %w", err)
+		}
+		if text.Format != nil {
+			switch text.Format.Type {
+			case "json_schema":
+				openAIRequest.ResponseFormat = &dto.ResponseFormat{
+					Type: "json_schema",
+					JsonSchema: &dto.FormatJsonSchema{
+						Name:        text.Format.Name,
+						Description: text.Format.Description,
+						Schema:      text.Format.Schema,
+						Strict:      text.Format.Strict,
+					},
+				}
+			case "json_object":
+				openAIRequest.ResponseFormat = &dto.ResponseFormat{Type: "json_object"}
+			}
+		}
+	}
+
+	// 内置工具（web_search_preview、file_search 等）只有 OpenAI 上游能执行
+	for _, tool := range responsesRequest.Tools {
+		toolType := common.Interface2String(tool["type"])
+		if toolType != "function" {
+			return nil, fmt.Errorf("tool type %s is not supported by this channel", toolType)
+		}
+		openAIRequest.Tools = append(openAIRequest.Tools, dto.ToolCallRequest{
+			Type: "function",
+			Function: dto.FunctionRequest{
+				Name:        common.Interface2String(tool["name"]),
+				Description: common.Interface2String(tool["description"]),
+				Parameters:  tool["parameters"],
+			},
+		})
+	}
+	if len(responsesRequest.ToolChoice) > 0 {
+		var toolChoice any
+		if err := common.Unmarshal(responsesRequest.ToolChoice, &toolChoice); err != nil {
+			return nil, fmt.Errorf("invalid tool_choice: %w", err)
+		}
+		switch v := toolChoice.(type) {
+		case string:
+			openAIRequest.ToolChoice = v
+		case map[string]any:
+			if common.Interface2String(v["type"]) == "function" {
+				openAIRequest.ToolChoice = map[string]any{
+					"type":     "function",
+					"function": map[string]any{"name": v["name"]},
+				}
+			}
+		}
+	}
+
+	openAIMessages := make([]dto.Message, 0)
+	if instructions := responsesInstructions(responsesRequest); instructions != "" {
+		openAIMessage := dto.Message{
+			Role: "system",
+		}
+		openAIMessage.SetStringContent(instructions)
+		openAIMessages = append(openAIMessages, openAIMessage)
+	}
+	items, err := responsesRequest.ParseInputItems()
+	if err != nil {
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+	for _, rawItem := range items {
+		var item dto.ResponsesInputItem
+		if err = common.Unmarshal(rawItem, &item); err != nil {
+			return nil, fmt.Errorf("invalid input item: %w", err)
+		}
+		switch item.Type {
+		case "", dto.ResponsesItemTypeMessage:
+			openAIMessage, err := responsesMessageToOpenAI(&item)
+			if err != nil {
+				return nil, err
+			}
+			openAIMessages = append(openAIMessages, openAIMessage)
+		case dto.ResponsesItemTypeFunctionCall:
+			toolCall := dto.ToolCallRequest{
+				ID:   item.CallId,
+				Type: "function",
+				Function: dto.FunctionRequest{
+					Name:      item.Name,
+					Arguments: item.Arguments,
+				},
+			}
+			// 连续的函数调用合并到前一条 assistant 消息
+			if n := len(openAIMessages); n > 0 && openAIMessages[n-1].Role == "assistant" {
+				lastMessage := &openAIMessages[n-1]
+				lastMessage.SetToolCalls(append(lastMessage.ParseToolCalls(), toolCall))
+			} else {
+				openAIMessage := dto.Message{
+					Role: "assistant",
+				}
+				openAIMessage.SetToolCalls([]dto.ToolCallRequest{toolCall})
+				openAIMessages = append(openAIMessages, openAIMessage)
+			}
+		case dto.ResponsesItemTypeFunctionCallOutput:
+			toolMessage := dto.Message{
+				Role:       "tool",
+				ToolCallId: item.CallId,
+			}
+			toolMessage.SetStringContent(responsesToolOutput(item.Output))
+			openAIMessages = append(openAIMessages, toolMessage)
+		case dto.ResponsesItemTypeReasoning, "item_reference":
+			// 推理内容不回传给上游
+		default:
+			return nil, fmt.Errorf("input item type %s is not supported by this channel", item.Type)
+		}
+	}
+	openAIRequest.Messages = openAIMessages
+
+	return &openAIRequest, nil
+}
+
+func responsesInstructions(responsesRequest *dto.OpenAIResponsesRequest) string {
+	var instructions string
+	if len(responsesRequest.Instructions) > 0 {
+		_ = common.Unmarshal(responsesRequest.Instructions, &instructions)
+	}
+	return instructions
+}
+
+func responsesMessageToOpenAI(item *dto.ResponsesInputItem) (dto.Message, error) {
+	role := item.Role
+	if role == "developer" {
+		role = "system"
+	}
+	openAIMessage := dto.Message{
+		Role: role,
+	}
+	if len(item.Content) > 0 && item.Content[0] == '"' {
+		var text string
+		if err := common.Unmarshal(item.Content, &text); err != nil {
+			return openAIMessage, fmt.Errorf("invalid message content: %w", err)
+		}
+		openAIMessage.SetStringContent(text)
+		return openAIMessage, nil
+	}
+	var contents []dto.ResponsesInputContent
+	if len(item.Content) > 0 {
+		if err := common.Unmarshal(item.Content, &contents); err != nil {
+			return openAIMessage, fmt.Errorf("invalid message content: %w", err)
+		}
+	}
+	mediaMessages := make([]dto.MediaContent, 0, len(contents))
+	for _, content := range contents {
+		switch content.Type {
+		case "input_text", "output_text":
+			mediaMessages = append(mediaMessages, dto.MediaContent{
+				Type: dto.ContentTypeText,
+				Text: content.Text,
+			})
+		case "input_image":
+			if content.ImageUrl == "" {
+				return openAIMessage, fmt.Errorf("input_image with file_id is not supported by this channel")
+			}
+			mediaMessages = append(mediaMessages, dto.MediaContent{
+				Type: dto.ContentTypeImageURL,
+				ImageUrl: &dto.MessageImageUrl{
+					Url:    content.ImageUrl,
+					Detail: common.GetStringIfEmpty(content.Detail, "auto"),
+				},
+			})
+		case "input_file":
+			mediaMessages = append(mediaMessages, dto.MediaContent{
+				Type: dto.ContentTypeFile,
+				File: &dto.MessageFile{
+					FileName: content.Filename,
+					FileData: content.FileData,
+					FileId:   content.FileId,
+				},
+			})
+		case "input_audio":
+			mediaMessages = append(mediaMessages, dto.MediaContent{
+				Type:       dto.ContentTypeInputAudio,
+				InputAudio: content.InputAudio,
+			})
+		}
+	}
+	if len(mediaMessages) == 1 && mediaMessages[0].Type == dto.ContentTypeText {
+		openAIMessage.SetStringContent(mediaMessages[0].Text)
+	} else {
+		openAIMessage.SetMediaContent(mediaMessages)
+	}
+	return openAIMessage, nil
+}
+
+// responsesToolOutput function_call_output 的 output 可以是字符串或内容数组
+func responsesToolOutput(output json.RawMessage) string {
+	var text string
+	if err := common.Unmarshal(output, &text); err == nil {
+		return text
+	}
+	return string(output)
+}
+
+func newResponsesResponse(info *relaycommon.RelayInfo) *dto.OpenAIResponsesResponse {
+	convertInfo := info.ResponsesConvertInfo
+	request := convertInfo.Request
+	response := &dto.OpenAIResponsesResponse{
+		ID:                 convertInfo.ResponseId,
+		Object:             "response",
+		CreatedAt:          int(convertInfo.CreatedAt),
+		Status:             "in_progress",
+		Instructions:       responsesInstructions(request),
+		MaxOutputTokens:    int(request.MaxOutputTokens),
+		Model:              info.OriginModelName,
+		Output:             []dto.ResponsesOutput{},
+		ParallelToolCalls:  request.ParallelToolCalls,
+		PreviousResponseID: request.PreviousResponseID,
+		Reasoning:          request.Reasoning,
+		Store:              request.GetStore(),
+		Temperature:        request.Temperature,
+		ToolChoice:         "auto",
+		Tools:              request.Tools,
+		TopP:               request.TopP,
+		Truncation:         common.GetStringIfEmpty(request.Truncation, "disabled"),
+		Metadata:           request.Metadata,
+	}
+	if len(request.ToolChoice) > 0 {
+		response.ToolChoice = request.ToolChoice
+	}
+	if response.Tools == nil {
+		response.Tools = []map[string]any{}
+	}
+	if request.User != "" {
+		response.User, _ = common.Marshal(request.User)
+	}
+	return response
+}
+
+func usageOpenAI2Responses(usage *dto.Usage) *dto.Usage {
+	responsesUsage := *usage
+	responsesUsage.InputTokens = usage.PromptTokens
+	responsesUsage.OutputTokens = usage.CompletionTokens
+	responsesUsage.InputTokensDetails = &dto.InputTokenDetails{
+		CachedTokens: usage.PromptTokensDetails.CachedTokens,
+	}
+	return &responsesUsage
+}
+
+func setResponsesStatus(response *dto.OpenAIResponsesResponse, finishReason string) {
+	switch finishReason {
+	case "length":
+		response.Status = "incomplete"
+		response.IncompleteDetails = &dto.IncompleteDetails{Reasoning: "max_output_tokens"}
+	case "content_filter":
+		response.Status = "incomplete"
+		response.IncompleteDetails = &dto.IncompleteDetails{Reasoning: "content_filter"}
+	default:
+		response.Status = "completed"
+	}
+}
+
+func newResponsesOutputItem(itemType string) dto.ResponsesOutput {
+	item := dto.ResponsesOutput{
+		Type:   itemType,
+		Status: "in_progress",
+	}
+	switch itemType {
+	case dto.ResponsesItemTypeMessage:
+		item.ID = "msg_" + common.GetRandomString(32)
+		item.Role = "assistant"
+	case dto.ResponsesItemTypeReasoning:
+		item.ID = "rs_" + common.GetRandomString(32)
+	case dto.ResponsesItemTypeFunctionCall:
+		item.ID = "fc_" + common.GetRandomString(32)
+	}
+	return item
+}
+
+func ResponseOpenAI2Responses(openAIResponse *dto.OpenAITextResponse, info *relaycommon.RelayInfo) *dto.OpenAIResponsesResponse {
+	response := newResponsesResponse(info)
+	finishReason := ""
+	if len(openAIResponse.Choices) > 0 {
+		choice := openAIResponse.Choices[0]
+		finishReason = choice.FinishReason
+		reasoning := choice.Message.ReasoningContent
+		if reasoning == "" {
+			reasoning = choice.Message.Reasoning
+		}
+		if reasoning != "" {
+			item := newResponsesOutputItem(dto.ResponsesItemTypeReasoning)
+			item.Status = "completed"
+			item.Summary = []dto.ResponsesOutputContent{{Type: "summary_text", Text: reasoning}}
+			response.Output = append(response.Output, item)
+		}
+		if text := choice.Message.StringContent(); text != "" {
+			item := newResponsesOutputItem(dto.ResponsesItemTypeMessage)
+			item.Status = "completed"
+			item.Content = []dto.ResponsesOutputContent{{Type: "output_text", Text: text, Annotations: []interface{}{}}}
+			response.Output = append(response.Output, item)
+		}
+		for _, toolCall := range choice.Message.ParseToolCalls() {
+			item := newResponsesOutputItem(dto.ResponsesItemTypeFunctionCall)
+			item.Status = "completed"
+			item.CallId = toolCall.ID
+			item.Name = toolCall.Function.Name
+			item.Arguments = toolCall.Function.Arguments
+			response.Output = append(response.Output, item)
+		}
+	}
+	info.ResponsesConvertInfo.Output = response.Output
+	response.Usage = usageOpenAI2Responses(&openAIResponse.Usage)
+	setResponsesStatus(response, finishReason)
+	info.ResponsesResult, _ = common.Marshal(response)
+	return response
+}
+
+// openResponsesItem 开始新的输出项，同一时刻只有一个输出项处于输出中
+func openResponsesItem(convertInfo *relaycommon.ResponsesConvertInfo, item dto.ResponsesOutput) []dto.ResponsesStreamResponse {
+	events := closeResponsesItem(convertInfo)
+	convertInfo.Output = append(convertInfo.Output, item)
+	convertInfo.OpenItem = len(convertInfo.Output) - 1
+	events = append(events, dto.ResponsesStreamResponse{
+		Type:        dto.ResponsesOutputTypeItemAdded,
+		OutputIndex: common.GetPointer[int](convertInfo.OpenItem),
+		Item:        &item,
+	})
+	part := dto.ResponsesOutputContent{Annotations: []interface{}{}}
+	switch item.Type {
+	case dto.ResponsesItemTypeMessage:
+		part.Type = "output_text"
+		convertInfo.Output[convertInfo.OpenItem].Content = []dto.ResponsesOutputContent{part}
+		events = append(events, dto.ResponsesStreamResponse{
+			Type:         "response.content_part.added",
+			ItemId:       item.ID,
+			OutputIndex:  common.GetPointer[int](convertInfo.OpenItem),
+			ContentIndex: common.GetPointer[int](0),
+			Part:         &part,
+		})
+	case dto.ResponsesItemTypeReasoning:
+		part.Type = "summary_text"
+		convertInfo.Output[convertInfo.OpenItem].Summary = []dto.ResponsesOutputContent{part}
+		events = append(events, dto.ResponsesStreamResponse{
+			Type:         "response.reasoning_summary_part.added",
+			ItemId:       item.ID,
+			OutputIndex:  common.GetPointer[int](convertInfo.OpenItem),
+			SummaryIndex: common.GetPointer[int](0),
+			Part:         &part,
+		})
+	}
+	return events
+}
+
+// closeResponsesItem 结束当前输出项并发送对应的 done 事件
+func closeResponsesItem(convertInfo *relaycommon.ResponsesConvertInfo) []dto.ResponsesStreamResponse {
+	if convertInfo.OpenItem < 0 {
+		return nil
+	}
+	outputIndex := convertInfo.OpenItem
+	convertInfo.OpenItem = -1
+	item := &convertInfo.Output[outputIndex]
+	item.Status = "completed"
+	events := make([]dto.ResponsesStreamResponse, 0, 3)
+	switch item.Type {
+	case dto.ResponsesItemTypeMessage:
+		part := item.Content[0]
+		events = append(events, dto.ResponsesStreamResponse{
+			Type:         "response.output_text.done",
+			ItemId:       item.ID,
+			OutputIndex:  common.GetPointer[int](outputIndex),
+			ContentIndex: common.GetPointer[int](0),
+			Text:         part.Text,
+		}, dto.ResponsesStreamResponse{
+			Type:         "response.content_part.done",
+			ItemId:       item.ID,
+			OutputIndex:  common.GetPointer[int](outputIndex),
+			ContentIndex: common.GetPointer[int](0),
+			Part:         &part,
+		})
+	case dto.ResponsesItemTypeReasoning:
+		part := item.Summary[0]
+		events = append(events, dto.ResponsesStreamResponse{
+			Type:         "response.reasoning_summary_text.done",
+			ItemId:       item.ID,
+			OutputIndex:  common.GetPointer[int](outputIndex),
+			SummaryIndex: common.GetPointer[int](0),
+			Text:         part.Text,
+		}, dto.ResponsesStreamResponse{
+			Type:         "response.reasoning_summary_part.done",
+			ItemId:       item.ID,
+			OutputIndex:  common.GetPointer[int](outputIndex),
+			SummaryIndex: common.GetPointer[int](0),
+			Part:         &part,
+		})
+	case dto.ResponsesItemTypeFunctionCall:
+		events = append(events, dto.ResponsesStreamResponse{
+			Type:        "response.function_call_arguments.done",
+			ItemId:      item.ID,
+			OutputIndex: common.GetPointer[int](outputIndex),
+			Arguments:   item.Arguments,
+		})
+	}
+	doneItem := *item
+	events = append(events, dto.ResponsesStreamResponse{
+		Type:        dto.ResponsesOutputTypeItemDone,
+		OutputIndex: common.GetPointer[int](outputIndex),
+		Item:        &doneItem,
+	})
+	return events
+}
+
+// responsesTextDelta 输出文本或推理摘要增量，类型变化时切换到新的输出项
+func responsesTextDelta(convertInfo *relaycommon.ResponsesConvertInfo, itemType string, delta string) []dto.ResponsesStreamResponse {
+	var events []dto.ResponsesStreamResponse
+	if convertInfo.OpenItem < 0 || convertInfo.Output[convertInfo.OpenItem].Type != itemType {
+		events = openResponsesItem(convertInfo, newResponsesOutputItem(itemType))
+	}
+	outputIndex := convertInfo.OpenItem
+	item := &convertInfo.Output[outputIndex]
+	if itemType == dto.ResponsesItemTypeMessage {
+		item.Content[0].Text += delta
+		return append(events, dto.ResponsesStreamResponse{
+			Type:         "response.output_text.delta",
+			ItemId:       item.ID,
+			OutputIndex:  common.GetPointer[int](outputIndex),
+			ContentIndex: common.GetPointer[int](0),
+			Delta:        delta,
+		})
+	}
+	item.Summary[0].Text += delta
+	return append(events, dto.ResponsesStreamResponse{
+		Type:         "response.reasoning_summary_text.delta",
+		ItemId:       item.ID,
+		OutputIndex:  common.GetPointer[int](outputIndex),
+		SummaryIndex: common.GetPointer[int](0),
+		Delta:        delta,
+	})
+}
+
+// StreamResponseOpenAI2Responses 转换单个 OpenAI 流式分片为 Responses 流式事件，Done 时结束所有输出项并发送 response.completed
+func StreamResponseOpenAI2Responses(openAIResponse *dto.ChatCompletionsStreamResponse, info *relaycommon.RelayInfo) []dto.ResponsesStreamResponse {
+	convertInfo := info.ResponsesConvertInfo
+	events := make([]dto.ResponsesStreamResponse, 0)
+	if !convertInfo.Started {
+		convertInfo.Started = true
+		response := newResponsesResponse(info)
+		events = append(events, dto.ResponsesStreamResponse{
+			Type:     "response.created",
+			Response: response,
+		}, dto.ResponsesStreamResponse{
+			Type:     "response.in_progress",
+			Response: response,
+		})
+	}
+	for _, choice := range openAIResponse.Choices {
+		if reasoning := choice.Delta.GetReasoningContent(); reasoning != "" {
+			events = append(events, responsesTextDelta(convertInfo, dto.ResponsesItemTypeReasoning, reasoning)...)
+		}
+		if text := choice.Delta.GetContentString(); text != "" {
+			events = append(events, responsesTextDelta(convertInfo, dto.ResponsesItemTypeMessage, text)...)
+		}
+		for _, toolCall := range choice.Delta.ToolCalls {
+			index := len(convertInfo.ToolCallItems)
+			if toolCall.Index != nil {
+				index = *toolCall.Index
+			} else if toolCall.ID == "" && index > 0 {
+				index--
+			}
+			outputIndex, ok := convertInfo.ToolCallItems[index]
+			if !ok {
+				item := newResponsesOutputItem(dto.ResponsesItemTypeFunctionCall)
+				item.CallId = toolCall.ID
+				item.Name = toolCall.Function.Name
+				events = append(events, openResponsesItem(convertInfo, item)...)
+				outputIndex = convertInfo.OpenItem
+				convertInfo.ToolCallItems[index] = outputIndex
+			}
+			if toolCall.Function.Arguments != "" {
+				item := &convertInfo.Output[outputIndex]
+				item.Arguments += toolCall.Function.Arguments
+				events = append(events, dto.ResponsesStreamResponse{
+					Type:        "response.function_call_arguments.delta",
+					ItemId:      item.ID,
+					OutputIndex: common.GetPointer[int](outputIndex),
+					Delta:       toolCall.Function.Arguments,
+				})
+			}
+		}
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			convertInfo.FinishReason = *choice.FinishReason
+		}
+	}
+	if openAIResponse.Usage != nil {
+		convertInfo.Usage = openAIResponse.Usage
+	}
+
+	if convertInfo.Done {
+		events = append(events, closeResponsesItem(convertInfo)...)
+		response := newResponsesResponse(info)
+		if len(convertInfo.Output) > 0 {
+			response.Output = convertInfo.Output
+		}
+		if convertInfo.Usage != nil {
+			response.Usage = usageOpenAI2Responses(convertInfo.Usage)
+		}
+		setResponsesStatus(response, convertInfo.FinishReason)
+		info.ResponsesResult, _ = common.Marshal(response)
+		eventType := "response.completed"
+		if response.Status == "incomplete" {
+			eventType = "response.incomplete"
+		}
+		events = append(events, dto.ResponsesStreamResponse{
+			Type:     eventType,
+			Response: response,
+		})
+	}
+
+	for i := range events {
+		events[i].SequenceNumber = convertInfo.SequenceNumber
+		convertInfo.SequenceNumber++
+	}
+	return events
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"one-api/common"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
+	"one-api/setting/operation_setting"
+	"one-api/setting/ratio_setting"
+
+	"github.com/shopspring/decimal"
+)
+
+var decimalMillion = decimal.NewFromInt(1000000)
+
+// ExactTokens 精确计费的各类 tokens，Input 不含缓存读写部分
+type ExactTokens struct {
+	Input      int
+	CacheRead  int
+	CacheWrite int
+	Image      int
+	Output     int
+}
+
+// ExactTokenQuota 按精确价格计算 tokens 费用并换算为额度，未使用精确计费时返回 false
+func ExactTokenQuota(priceData helper.PriceData, tokens ExactTokens) (decimal.Decimal, bool) {
+	price := priceData.ExactPrice
+	if price == nil {
+		return decimal.Zero, false
+	}
+	cost := price.Input.Mul(decimal.NewFromInt(int64(tokens.Input)))
+	cost = cost.Add(price.CacheRead.Mul(decimal.NewFromInt(int64(tokens.CacheRead))))
+	cost = cost.Add(price.CacheWrite.Mul(decimal.NewFromInt(int64(tokens.CacheWrite))))
+	cost = cost.Add(price.Input.Mul(decimal.NewFromFloat(priceData.ImageRatio)).Mul(decimal.NewFromInt(int64(tokens.Image))))
+	cost = cost.Add(price.Output.Mul(decimal.NewFromInt(int64(tokens.Output))))
+	cost = cost.Div(decimalMillion).Mul(decimal.NewFromFloat(priceData.GroupRatioInfo.GroupRatio))
+	return cost.Mul(decimal.NewFromFloat(common.QuotaPerUnit)), true
+}
+
+// ExactAudioQuota 按精确价格计算音频和实时模型的费用并换算为额度，音频 tokens 按输入价格乘以音频倍率计算，未使用精确计费时返回 false
+func ExactAudioQuota(price *operation_setting.ExactModelPrice, info QuotaInfo) (decimal.Decimal, bool) {
+	if price == nil {
+		return decimal.Zero, false
+	}
+	audioRatio := decimal.NewFromFloat(ratio_setting.GetAudioRatio(info.ModelName))
+	audioCompletionRatio := decimal.NewFromFloat(ratio_setting.GetAudioCompletionRatio(info.ModelName))
+	cost := price.Input.Mul(decimal.NewFromInt(int64(info.InputDetails.TextTokens)))
+	cost = cost.Add(price.Output.Mul(decimal.NewFromInt(int64(info.OutputDetails.TextTokens))))
+	cost = cost.Add(price.Input.Mul(audioRatio).Mul(decimal.NewFromInt(int64(info.InputDetails.AudioTokens))))
+	cost = cost.Add(price.Input.Mul(audioRatio).Mul(audioCompletionRatio).Mul(decimal.NewFromInt(int64(info.OutputDetails.AudioTokens))))
+	cost = cost.Div(decimalMillion).Mul(decimal.NewFromFloat(info.GroupRatio))
+	return cost.Mul(decimal.NewFromFloat(common.QuotaPerUnit)), true
+}
+
+// SettleQuota 将额度结算为整数，开启精确计费时小数部分累计到用户的额度余数中，否则四舍五入
+func SettleQuota(relayInfo *relaycommon.RelayInfo, quota decimal.Decimal) int {
+	if !operation_setting.GetExactBillingSetting().Enabled || !quota.IsPositive() {
+		return int(quota.Round(0).IntPart())
+	}
+	whole := quota.Floor()
+	return int(whole.IntPart()) + model.CarryUserQuotaRemainder(relayInfo.UserId, quota.Sub(whole))
+}
+
+// SettlePerCallQuota 按次计费（MJ、Task）的额度结算，price 为已乘分组倍率的美元价格，
+// 开启精确计费时按价格结算并累计不足 1 的额度，同时返回日志记录的美元金额
+func SettlePerCallQuota(relayInfo *relaycommon.RelayInfo, quota int, price float64) (int, *decimal.Decimal) {
+	if !operation_setting.GetExactBillingSetting().Enabled {
+		return quota, nil
+	}
+	quotaDecimal := decimal.NewFromFloat(price).Mul(decimal.NewFromFloat(common.QuotaPerUnit))
+	return SettleQuota(relayInfo, quotaDecimal), ExactCost(relayInfo, quotaDecimal)
+}
+
+// QuotaCost 将额度换算为美元，保留 10 位小数
+func QuotaCost(quota decimal.Decimal) *decimal.Decimal {
+	cost := quota.Div(decimal.NewFromFloat(common.QuotaPerUnit)).Round(10)
+	return &cost
+}
+
+// ExactCost 精确计费时按响应缓存命中倍率和批处理折扣调整后的美元金额，未开启时返回 nil，由日志按额度换算
+func ExactCost(relayInfo *relaycommon.RelayInfo, quota decimal.Decimal) *decimal.Decimal {
+	if !operation_setting.GetExactBillingSetting().Enabled {
+		return nil
+	}
+	if relayInfo.ResponseCacheHit {
+		if hitRatio := operation_setting.GetResponseCacheSetting().HitPriceRatio; hitRatio >= 0 && hitRatio < 1 {
+			quota = quota.Mul(decimal.NewFromFloat(hitRatio))
+		}
+	}
+	if relayInfo.IsBatch {
+		if discountRatio := operation_setting.GetBatchSetting().DiscountRatio; discountRatio >= 0 && discountRatio < 1 {
+			quota = quota.Mul(decimal.NewFromFloat(discountRatio))
+		}
+	}
+	return QuotaCost(quota)
+}
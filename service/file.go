@@ -0,0 +1,219 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"strings"
+)
+
+func GenerateFileId() string {
+	return "file-" + common.GetRandomString(24)
+}
+
+// CheckUserFileQuota 校验用户存储空间，size 为即将写入的字节数
+func CheckUserFileQuota(userId int64, size int64) error {
+	fileSetting := operation_setting.GetFileSetting()
+	if fileSetting.MaxFileSizeMB > 0 && size > int64(fileSetting.MaxFileSizeMB)<<20 {
+		return fmt.Errorf("文件大小超过限制 %d MB", fileSetting.MaxFileSizeMB)
+	}
+	if fileSetting.UserQuotaBytes <= 0 {
+		return nil
+	}
+	used, err := model.GetUserFileBytes(userId)
+	if err != nil {
+		return err
+	}
+	if used+size > fileSetting.UserQuotaBytes {
+		return fmt.Errorf("存储空间不足，已使用 %s，上限 %s", common.Bytes2Size(used), common.Bytes2Size(fileSetting.UserQuotaBytes))
+	}
+	return nil
+}
+
+// SaveLocalFile 将文件写入本地存储并落库
+func SaveLocalFile(file *model.File, reader io.Reader) error {
+	storage, err := GetFileStorage()
+	if err != nil {
+		return err
+	}
+	if file.FileId == "" {
+		file.FileId = GenerateFileId()
+	}
+	file.Storage = model.FileStorageLocal
+	file.StoragePath = fmt.Sprintf("%d/%s", file.UserId, file.FileId)
+	size, err := storage.Save(file.StoragePath, reader)
+	if err != nil {
+		return err
+	}
+	file.Bytes = size
+	if file.Status == "" {
+		file.Status = model.FileStatusProcessed
+	}
+	if err = file.Insert(); err != nil {
+		_ = storage.Delete(file.StoragePath)
+		return err
+	}
+	return nil
+}
+
+// DoChannelFileRequest 使用渠道的 key 直接请求上游的文件类接口，path 形如 /v1/files
+func DoChannelFileRequest(channel *model.Channel, keyIndex int, method string, path string, body io.Reader, contentType string) (*http.Response, error) {
+	key, err := channel.GetKeyByIndex(keyIndex)
+	if err != nil {
+		return nil, err
+	}
+	baseURL := channel.GetBaseURL()
+	if baseURL == "" {
+		baseURL = constant.ChannelBaseURLs[channel.Type]
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(baseURL, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if channel.OpenAIOrganization != nil && *channel.OpenAIOrganization != "" {
+		req.Header.Set("OpenAI-Organization", *channel.OpenAIOrganization)
+	}
+	client := GetHttpClient()
+	if proxy := channel.GetSetting().Proxy; proxy != "" {
+		client, err = NewProxyHttpClient(proxy)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return client.Do(req)
+}
+
+// SupportFilePassThrough 仅 OpenAI 类型渠道支持文件透传
+func SupportFilePassThrough(channel *model.Channel) bool {
+	return channel != nil && channel.Type == constant.ChannelTypeOpenAI
+}
+
+// UploadFileToChannel 使用指定 key 将文件上传到上游渠道，返回的 file id 即为上游 id
+func UploadFileToChannel(channel *model.Channel, keyIndex int, file *model.File, reader io.Reader) error {
+	// 边读边写入上游，避免将整个文件缓存在内存中
+	body, pipeWriter := io.Pipe()
+	writer := multipart.NewWriter(pipeWriter)
+	copyDone := make(chan error, 1)
+	go func() {
+		err := writer.WriteField("purpose", file.Purpose)
+		if err == nil {
+			var part io.Writer
+			part, err = writer.CreateFormFile("file", file.Filename)
+			if err == nil {
+				_, err = io.Copy(part, reader)
+			}
+		}
+		if err == nil {
+			err = writer.Close()
+		}
+		_ = pipeWriter.CloseWithError(err)
+		copyDone <- err
+	}()
+	resp, err := DoChannelFileRequest(channel, keyIndex, http.MethodPost, "/v1/files", body, writer.FormDataContentType())
+	// 请求结束后关闭读端，并等待写入协程退出，调用方随后会关闭 reader
+	_ = body.Close()
+	copyErr := <-copyDone
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if copyErr != nil {
+		return copyErr
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("upstream upload file failed, status code: %d, body: %s", resp.StatusCode, string(respBody))
+	}
+	var upstreamFile dto.OpenAIFile
+	if err = common.Unmarshal(respBody, &upstreamFile); err != nil {
+		return err
+	}
+	if upstreamFile.Id == "" {
+		return errors.New("upstream returned empty file id")
+	}
+	file.FileId = upstreamFile.Id
+	file.Bytes = upstreamFile.Bytes
+	file.ChannelId = channel.Id
+	file.KeyIndex = keyIndex
+	file.Storage = model.FileStorageUpstream
+	file.Status = common.GetStringIfEmpty(upstreamFile.Status, model.FileStatusUploaded)
+	if upstreamFile.CreatedAt != 0 {
+		file.CreatedAt = upstreamFile.CreatedAt
+	}
+	return file.Insert()
+}
+
+// OpenFileContent 打开文件内容，调用方负责关闭
+func OpenFileContent(file *model.File) (io.ReadCloser, error) {
+	if !file.IsUpstream() {
+		storage, err := GetFileStorage()
+		if err != nil {
+			return nil, err
+		}
+		return storage.Open(file.StoragePath)
+	}
+	channel, err := model.CacheGetChannel(file.ChannelId)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := DoChannelFileRequest(channel, file.KeyIndex, http.MethodGet, "/v1/files/"+file.FileId+"/content", nil, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("upstream get file content failed, status code: %d, body: %s", resp.StatusCode, string(respBody))
+	}
+	return resp.Body, nil
+}
+
+// DeleteFile 删除存储内容及记录，上游删除失败时仅记录日志
+func DeleteFile(file *model.File) error {
+	if file.IsUpstream() {
+		channel, err := model.CacheGetChannel(file.ChannelId)
+		if err == nil {
+			resp, err := DoChannelFileRequest(channel, file.KeyIndex, http.MethodDelete, "/v1/files/"+file.FileId, nil, "")
+			if err != nil {
+				common.SysError(fmt.Sprintf("delete upstream file %s failed: %s", file.FileId, err.Error()))
+			} else {
+				_ = resp.Body.Close()
+			}
+		}
+	} else {
+		storage, err := GetFileStorage()
+		if err != nil {
+			return err
+		}
+		if err = storage.Delete(file.StoragePath); err != nil {
+			return err
+		}
+	}
+	return file.Delete()
+}
+
+func FileToOpenAIFile(file *model.File) dto.OpenAIFile {
+	return dto.OpenAIFile{
+		Id:        file.FileId,
+		Object:    "file",
+		Bytes:     file.Bytes,
+		CreatedAt: file.CreatedAt,
+		Filename:  file.Filename,
+		Purpose:   file.Purpose,
+		Status:    file.Status,
+	}
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"one-api/setting/operation_setting"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileStorage 文件存储后端，path 为后端内部的相对路径
+type FileStorage interface {
+	Save(path string, reader io.Reader) (int64, error)
+	Open(path string) (io.ReadCloser, error)
+	Delete(path string) error
+}
+
+type LocalFileStorage struct {
+	BaseDir string
+}
+
+func (s *LocalFileStorage) fullPath(path string) (string, error) {
+	cleaned := filepath.Clean("/" + path)
+	if strings.Contains(cleaned, "..") {
+		return "", errors.New("invalid file path")
+	}
+	return filepath.Join(s.BaseDir, cleaned), nil
+}
+
+func (s *LocalFileStorage) Save(path string, reader io.Reader) (int64, error) {
+	fullPath, err := s.fullPath(path)
+	if err != nil {
+		return 0, err
+	}
+	if err = os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return 0, err
+	}
+	f, err := os.Create(fullPath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	n, err := io.Copy(f, reader)
+	if err != nil {
+		_ = os.Remove(fullPath)
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *LocalFileStorage) Open(path string) (io.ReadCloser, error) {
+	fullPath, err := s.fullPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(fullPath)
+}
+
+func (s *LocalFileStorage) Delete(path string) error {
+	fullPath, err := s.fullPath(path)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(fullPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func GetFileStorage() (FileStorage, error) {
+	fileSetting := operation_setting.GetFileSetting()
+	switch fileSetting.StorageType {
+	case "", "local":
+		return &LocalFileStorage{BaseDir: fileSetting.LocalPath}, nil
+	default:
+		return nil, fmt.Errorf("unsupported file storage type: %s", fileSetting.StorageType)
+	}
+}
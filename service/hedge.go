@@ -0,0 +1,83 @@
+package service
+
+import (
+	"errors"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/setting/operation_setting"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrHedgeLost 对冲请求中未被采用的一方
+var ErrHedgeLost = errors.New("hedged request lost the race")
+
+// HedgeRace 同一请求的各个对冲尝试共享的竞速状态
+type HedgeRace struct {
+	sync.Mutex
+	channels    []int
+	winner      int
+	responded   chan struct{}
+	respondOnce sync.Once
+}
+
+func NewHedgeRace() *HedgeRace {
+	return &HedgeRace{responded: make(chan struct{})}
+}
+
+// MarkResponded 上游开始返回响应时调用
+func (r *HedgeRace) MarkResponded() {
+	r.respondOnce.Do(func() {
+		close(r.responded)
+	})
+}
+
+// Responded 任一尝试收到上游响应后关闭
+func (r *HedgeRace) Responded() <-chan struct{} {
+	return r.responded
+}
+
+func (r *HedgeRace) AddChannel(channelId int) {
+	r.Lock()
+	defer r.Unlock()
+	r.channels = append(r.channels, channelId)
+}
+
+func (r *HedgeRace) Channels() []int {
+	r.Lock()
+	defer r.Unlock()
+	return append([]int(nil), r.channels...)
+}
+
+// Claim 第一个完成的渠道获胜，之后的调用返回 false
+func (r *HedgeRace) Claim(channelId int) bool {
+	r.Lock()
+	defer r.Unlock()
+	if r.winner != 0 {
+		return r.winner == channelId
+	}
+	r.winner = channelId
+	return true
+}
+
+func GetHedgeRace(c *gin.Context) *HedgeRace {
+	if value, ok := common.GetContextKey(c, constant.ContextKeyHedgeRace); ok {
+		if race, ok := value.(*HedgeRace); ok {
+			return race
+		}
+	}
+	return nil
+}
+
+// ClaimHedgeWin 计费前调用，对冲请求中未获胜的一方不计费（除非设置为全部计费）
+func ClaimHedgeWin(c *gin.Context, channelId int) bool {
+	race := GetHedgeRace(c)
+	if race == nil {
+		return true
+	}
+	if race.Claim(channelId) {
+		return true
+	}
+	return operation_setting.GetHedgeSetting().ChargeAllRaced
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func invoiceStatusText(status int) string {
+	switch status {
+	case model.InvoiceStatusPaid:
+		return "paid"
+	case model.InvoiceStatusVoid:
+		return "void"
+	default:
+		return "unpaid"
+	}
+}
+
+// ExportInvoiceCSV 导出账单明细为 CSV，最后一行为合计
+func ExportInvoiceCSV(invoice *model.Invoice) ([]byte, error) {
+	var buf bytes.Buffer
+	// 写入 BOM，方便 Excel 识别 UTF-8
+	buf.WriteString("\xEF\xBB\xBF")
+	w := csv.NewWriter(&buf)
+	_ = w.Write([]string{"invoice_id", "user", "period", "status", "model", "token", "requests", "prompt_tokens", "completion_tokens", "quota", "amount_usd"})
+	for _, item := range invoice.Items {
+		_ = w.Write([]string{
+			strconv.Itoa(invoice.Id),
+			invoice.Username,
+			invoice.Period,
+			invoiceStatusText(invoice.Status),
+			item.ModelName,
+			item.TokenName,
+			strconv.Itoa(item.RequestCount),
+			strconv.Itoa(item.PromptTokens),
+			strconv.Itoa(item.CompletionTokens),
+			strconv.Itoa(item.Quota),
+			item.Amount.StringFixed(6),
+		})
+	}
+	_ = w.Write([]string{
+		strconv.Itoa(invoice.Id), invoice.Username, invoice.Period, invoiceStatusText(invoice.Status),
+		"TOTAL", "", strconv.Itoa(invoice.RequestCount), "", "", strconv.Itoa(invoice.Quota), invoice.Amount.StringFixed(6),
+	})
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// pdfText 转义 PDF 字符串，内置字体只支持 ASCII，其余字符替换为 ?
+func pdfText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 32 || r > 126:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func pdfTruncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) > n {
+		return string(r[:n-1]) + "~"
+	}
+	return s
+}
+
+// ExportInvoicePDF 使用内置等宽字体生成简单的文本 PDF，不依赖外部库
+func ExportInvoicePDF(invoice *model.Invoice) ([]byte, error) {
+	lines := []string{
+		"INVOICE #" + strconv.Itoa(invoice.Id),
+		"",
+		fmt.Sprintf("User:    %s (#%d)", invoice.Username, invoice.UserId),
+		fmt.Sprintf("Period:  %s (%s - %s)", invoice.Period,
+			time.Unix(invoice.PeriodStart, 0).Format("2006-01-02"), time.Unix(invoice.PeriodEnd-1, 0).Format("2006-01-02")),
+		"Status:  " + invoiceStatusText(invoice.Status),
+		"Created: " + time.Unix(invoice.CreatedTime, 0).Format("2006-01-02 15:04:05"),
+		"",
+		fmt.Sprintf("%-28s %-16s %9s %12s %14s", "Model", "Token", "Requests", "Tokens", "Amount (USD)"),
+		strings.Repeat("-", 83),
+	}
+	for _, item := range invoice.Items {
+		lines = append(lines, fmt.Sprintf("%-28s %-16s %9d %12d %14s",
+			pdfTruncate(item.ModelName, 28), pdfTruncate(item.TokenName, 16), item.RequestCount,
+			item.PromptTokens+item.CompletionTokens, item.Amount.StringFixed(6)))
+	}
+	lines = append(lines,
+		strings.Repeat("-", 83),
+		fmt.Sprintf("%-28s %-16s %9d %12s %14s", "Total", "", invoice.RequestCount, "", invoice.Amount.StringFixed(6)),
+		fmt.Sprintf("Quota: %s", common.LogQuota(invoice.Quota)),
+	)
+
+	const linesPerPage = 60
+	var pages [][]string
+	for len(lines) > linesPerPage {
+		pages = append(pages, lines[:linesPerPage])
+		lines = lines[linesPerPage:]
+	}
+	pages = append(pages, lines)
+
+	// 对象编号：1 目录，2 页面树，3 字体，之后每页依次为页面和内容流
+	var objects []string
+	kids := make([]string, 0, len(pages))
+	for i := range pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 4+i*2))
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>",
+	)
+	for i, pageLines := range pages {
+		var content strings.Builder
+		content.WriteString("BT /F1 9 Tf 11 TL 40 800 Td\n")
+		for _, line := range pageLines {
+			content.WriteString("(" + pdfText(line) + ") Tj T*\n")
+		}
+		content.WriteString("ET")
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", 5+i*2),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()),
+		)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes(), nil
+}
@@ -28,12 +28,29 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 		other["is_model_mapped"] = true
 		other["upstream_model_name"] = relayInfo.UpstreamModelName
 	}
+	if fallbackFrom := common.GetContextKeyString(ctx, constant.ContextKeyModelFallback); fallbackFrom != "" {
+		// 原始请求的模型，日志中的模型为实际使用的备用模型
+		other["fallback_from"] = fallbackFrom
+	}
+	if relayInfo.PricingTier != nil {
+		other["pricing_tier"] = relayInfo.PricingTier
+	}
 	adminInfo := make(map[string]interface{})
 	adminInfo["use_channel"] = ctx.GetStringSlice("use_channel")
+	if routingRule := common.GetContextKeyString(ctx, constant.ContextKeyRoutingRule); routingRule != "" {
+		adminInfo["routing_rule"] = routingRule
+	}
 	isMultiKey := common.GetContextKeyBool(ctx, constant.ContextKeyChannelIsMultiKey)
 	if isMultiKey {
 		adminInfo["is_multi_key"] = true
 		adminInfo["multi_key_index"] = common.GetContextKeyInt(ctx, constant.ContextKeyChannelMultiKeyIndex)
+		adminInfo["multi_key_fingerprint"] = common.KeyFingerprint(common.GetContextKeyString(ctx, constant.ContextKeyChannelKey))
+	}
+	if race := GetHedgeRace(ctx); race != nil {
+		if channels := race.Channels(); len(channels) > 1 {
+			other["hedge"] = true
+			adminInfo["hedge_channels"] = channels
+		}
 	}
 	other["admin_info"] = adminInfo
 	return other
@@ -12,6 +12,7 @@ import (
 	relaycommon "one-api/relay/common"
 	"one-api/relay/helper"
 	"one-api/setting"
+	"one-api/setting/operation_setting"
 	"one-api/setting/ratio_setting"
 	"strings"
 	"time"
@@ -38,13 +39,21 @@ type QuotaInfo struct {
 }
 
 func calculateAudioQuota(info QuotaInfo) int {
+	quota := calculateAudioQuotaDecimal(info)
+	if info.UsePrice {
+		return int(quota.IntPart())
+	}
+	return int(quota.Round(0).IntPart())
+}
+
+// calculateAudioQuotaDecimal 按倍率或价格计算音频模型的额度，不取整
+func calculateAudioQuotaDecimal(info QuotaInfo) decimal.Decimal {
 	if info.UsePrice {
 		modelPrice := decimal.NewFromFloat(info.ModelPrice)
 		quotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
 		groupRatio := decimal.NewFromFloat(info.GroupRatio)
 
-		quota := modelPrice.Mul(quotaPerUnit).Mul(groupRatio)
-		return int(quota.IntPart())
+		return modelPrice.Mul(quotaPerUnit).Mul(groupRatio)
 	}
 
 	completionRatio := decimal.NewFromFloat(ratio_setting.GetCompletionRatio(info.ModelName))
@@ -73,14 +82,14 @@ func calculateAudioQuota(info QuotaInfo) int {
 		quota = decimal.NewFromInt(1)
 	}
 
-	return int(quota.Round(0).IntPart())
+	return quota
 }
 
 func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.RealtimeUsage) error {
 	if relayInfo.UsePrice {
 		return nil
 	}
-	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
+	userQuota, err := model.GetBillingQuota(relayInfo.UserId, relayInfo.OrganizationId)
 	if err != nil {
 		return err
 	}
@@ -127,6 +136,17 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 	}
 
 	quota := calculateAudioQuota(quotaInfo)
+	// 与其他请求使用同样的精确价格解析，计入微调基础模型和时段倍率
+	var exactPrice *operation_setting.ExactModelPrice
+	if priceData, err := helper.ModelPriceHelper(ctx, relayInfo, usage.InputTokens, 0); err == nil {
+		exactPrice = priceData.ExactPrice
+	}
+	quotaDecimal, isExact := ExactAudioQuota(exactPrice, quotaInfo)
+	if isExact {
+		quota = int(quotaDecimal.Ceil().IntPart())
+	} else {
+		quotaDecimal = calculateAudioQuotaDecimal(quotaInfo)
+	}
 
 	if userQuota < quota {
 		return fmt.Errorf("user quota is not enough, user quota: %s, need quota: %s", common.FormatQuota(userQuota), common.FormatQuota(quota))
@@ -136,6 +156,9 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 		return fmt.Errorf("token quota is not enough, token remain quota: %s, need quota: %s", common.FormatQuota(token.RemainQuota), common.FormatQuota(quota))
 	}
 
+	if operation_setting.GetExactBillingSetting().Enabled {
+		quota = SettleQuota(relayInfo, quotaDecimal)
+	}
 	err = PostConsumeQuota(relayInfo, quota, 0, false)
 	if err != nil {
 		return err
@@ -180,6 +203,13 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 	}
 
 	quota := calculateAudioQuota(quotaInfo)
+	// 实时会话的额度已在每次响应时按精确价格扣除，这里只汇总记录
+	quotaDecimal, isExact := ExactAudioQuota(priceData.ExactPrice, quotaInfo)
+	if isExact {
+		quota = int(quotaDecimal.Round(0).IntPart())
+	} else {
+		quotaDecimal = calculateAudioQuotaDecimal(quotaInfo)
+	}
 
 	totalTokens := usage.TotalTokens
 	var logContent string
@@ -195,6 +225,7 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 		// in this case, must be some error happened
 		// we cannot just return, because we may have to return the pre-consumed quota
 		quota = 0
+		quotaDecimal = decimal.Zero
 		logContent += fmt.Sprintf("（可能是上游超时）")
 		common.LogError(ctx, fmt.Sprintf("total tokens is 0, cannot consume quota, userId %d, channelId %d, "+
 			"tokenId %d, model %s， pre-consumed quota %d", relayInfo.UserId, relayInfo.ChannelId, relayInfo.TokenId, modelName, preConsumedQuota))
@@ -209,6 +240,9 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 	}
 	other := GenerateWssOtherInfo(ctx, relayInfo, usage, modelRatio, groupRatio,
 		completionRatio.InexactFloat64(), audioRatio.InexactFloat64(), audioCompletionRatio.InexactFloat64(), modelPrice, priceData.GroupRatioInfo.GroupSpecialRatio)
+	if isExact {
+		other["exact_price"] = priceData.ExactPrice
+	}
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     usage.InputTokens,
@@ -223,16 +257,24 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 		IsStream:         relayInfo.IsStream,
 		Group:            relayInfo.UsingGroup,
 		Other:            other,
+		Cost:             ExactCost(relayInfo, quotaDecimal),
 	})
 }
 
 func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	usage *dto.Usage, preConsumedQuota int, userQuota int, priceData helper.PriceData, extraContent string) {
 
+	// 阶梯价格按包含缓存的全部提示 tokens 选档
+	tierPromptTokens := usage.PromptTokens
+	if relayInfo.ChannelType != constant.ChannelTypeOpenRouter {
+		tierPromptTokens += usage.PromptTokensDetails.CachedTokens + usage.PromptTokensDetails.CachedCreationTokens
+	}
+	priceData = helper.RematchPromptTier(ctx, relayInfo, priceData, tierPromptTokens)
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	promptTokens := usage.PromptTokens
 	completionTokens := usage.CompletionTokens
 	modelName := relayInfo.OriginModelName
+	RecordChannelRelaySuccess(relayInfo, usage)
 
 	tokenName := ctx.GetString("token_name")
 	completionRatio := priceData.CompletionRatio
@@ -256,24 +298,39 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		promptTokens -= cacheCreationTokens
 	}
 
-	calculateQuota := 0.0
-	if !priceData.UsePrice {
-		calculateQuota = float64(promptTokens)
-		calculateQuota += float64(cacheTokens) * cacheRatio
-		calculateQuota += float64(cacheCreationTokens) * cacheCreationRatio
-		calculateQuota += float64(completionTokens) * completionRatio
-		calculateQuota = calculateQuota * groupRatio * modelRatio
-	} else {
-		calculateQuota = modelPrice * common.QuotaPerUnit * groupRatio
-	}
+	totalTokens := promptTokens + completionTokens
 
-	if modelRatio != 0 && calculateQuota <= 0 {
-		calculateQuota = 1
-	}
+	quotaDecimal, isExact := ExactTokenQuota(priceData, ExactTokens{
+		Input:      promptTokens,
+		CacheRead:  cacheTokens,
+		CacheWrite: cacheCreationTokens,
+		Output:     completionTokens,
+	})
+	if !isExact {
+		calculateQuota := 0.0
+		if !priceData.UsePrice {
+			calculateQuota = float64(promptTokens)
+			calculateQuota += float64(cacheTokens) * cacheRatio
+			calculateQuota += float64(cacheCreationTokens) * cacheCreationRatio
+			calculateQuota += float64(completionTokens) * completionRatio
+			calculateQuota = calculateQuota * groupRatio * modelRatio
+		} else {
+			calculateQuota = modelPrice * common.QuotaPerUnit * groupRatio
+		}
 
-	quota := int(calculateQuota)
+		if modelRatio != 0 && calculateQuota <= 0 {
+			calculateQuota = 1
+		}
+		quotaDecimal = decimal.NewFromFloat(calculateQuota)
+	}
+	if totalTokens == 0 {
+		quotaDecimal = decimal.Zero
+	}
 
-	totalTokens := promptTokens + completionTokens
+	quota := int(quotaDecimal.IntPart())
+	if operation_setting.GetExactBillingSetting().Enabled {
+		quota = SettleQuota(relayInfo, quotaDecimal)
+	}
 
 	var logContent string
 	// record all the consume log even if quota is 0
@@ -284,28 +341,37 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		logContent += fmt.Sprintf("（可能是上游出错）")
 		common.LogError(ctx, fmt.Sprintf("total tokens is 0, cannot consume quota, userId %d, channelId %d, "+
 			"tokenId %d, model %s， pre-consumed quota %d", relayInfo.UserId, relayInfo.ChannelId, relayInfo.TokenId, modelName, preConsumedQuota))
-	} else {
-		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
-		model.UpdateChannelUsedQuota(int64(relayInfo.ChannelId), quota)
+	}
+	// 批处理请求按折扣结算，用户、渠道统计和日志都记录折扣后的额度
+	discountedQuota := BatchDiscountQuota(relayInfo, quota)
+	if totalTokens != 0 {
+		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, discountedQuota)
+		model.UpdateChannelUsedQuota(int64(relayInfo.ChannelId), discountedQuota)
 	}
 
 	quotaDelta := quota - preConsumedQuota
-	if quotaDelta != 0 {
+	if quotaDelta != 0 || relayInfo.IsBatch {
 		err := PostConsumeQuota(relayInfo, quotaDelta, preConsumedQuota, true)
 		if err != nil {
 			common.LogError(ctx, "error consuming token remain quota: "+err.Error())
 		}
 	}
+	if relayInfo.IsBatch {
+		logContent += fmt.Sprintf("批处理折扣 %.2f", operation_setting.GetBatchSetting().DiscountRatio)
+	}
 
 	other := GenerateClaudeOtherInfo(ctx, relayInfo, modelRatio, groupRatio, completionRatio,
 		cacheTokens, cacheRatio, cacheCreationTokens, cacheCreationRatio, modelPrice, priceData.GroupRatioInfo.GroupSpecialRatio)
+	if isExact {
+		other["exact_price"] = priceData.ExactPrice
+	}
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     promptTokens,
 		CompletionTokens: completionTokens,
 		ModelName:        modelName,
 		TokenName:        tokenName,
-		Quota:            quota,
+		Quota:            discountedQuota,
 		Content:          logContent,
 		TokenId:          relayInfo.TokenId,
 		UserQuota:        userQuota,
@@ -313,6 +379,7 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		IsStream:         relayInfo.IsStream,
 		Group:            relayInfo.UsingGroup,
 		Other:            other,
+		Cost:             ExactCost(relayInfo, quotaDecimal),
 	})
 
 }
@@ -341,6 +408,8 @@ func CalcOpenRouterCacheCreateTokens(usage dto.Usage, priceData helper.PriceData
 func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	usage *dto.Usage, preConsumedQuota int, userQuota int, priceData helper.PriceData, extraContent string) {
 
+	priceData = helper.RematchPromptTier(ctx, relayInfo, priceData, usage.PromptTokens)
+	RecordChannelRelaySuccess(relayInfo, usage)
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	textInputTokens := usage.PromptTokensDetails.TextTokens
 	textOutTokens := usage.CompletionTokenDetails.TextTokens
@@ -373,9 +442,19 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		GroupRatio: groupRatio,
 	}
 
+	quotaDecimal, isExact := ExactAudioQuota(priceData.ExactPrice, quotaInfo)
+	if !isExact {
+		quotaDecimal = calculateAudioQuotaDecimal(quotaInfo)
+	}
+	totalTokens := usage.TotalTokens
+	if totalTokens == 0 {
+		quotaDecimal = decimal.Zero
+	}
 	quota := calculateAudioQuota(quotaInfo)
+	if operation_setting.GetExactBillingSetting().Enabled {
+		quota = SettleQuota(relayInfo, quotaDecimal)
+	}
 
-	totalTokens := usage.TotalTokens
 	var logContent string
 	if !usePrice {
 		logContent = fmt.Sprintf("模型倍率 %.2f，补全倍率 %.2f，音频倍率 %.2f，音频补全倍率 %.2f，分组倍率 %.2f",
@@ -392,18 +471,24 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		logContent += fmt.Sprintf("（可能是上游超时）")
 		common.LogError(ctx, fmt.Sprintf("total tokens is 0, cannot consume quota, userId %d, channelId %d, "+
 			"tokenId %d, model %s， pre-consumed quota %d", relayInfo.UserId, relayInfo.ChannelId, relayInfo.TokenId, relayInfo.OriginModelName, preConsumedQuota))
-	} else {
-		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
-		model.UpdateChannelUsedQuota(int64(relayInfo.ChannelId), quota)
+	}
+	// 批处理请求按折扣结算，用户、渠道统计和日志都记录折扣后的额度
+	discountedQuota := BatchDiscountQuota(relayInfo, quota)
+	if totalTokens != 0 {
+		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, discountedQuota)
+		model.UpdateChannelUsedQuota(int64(relayInfo.ChannelId), discountedQuota)
 	}
 
 	quotaDelta := quota - preConsumedQuota
-	if quotaDelta != 0 {
+	if quotaDelta != 0 || relayInfo.IsBatch {
 		err := PostConsumeQuota(relayInfo, quotaDelta, preConsumedQuota, true)
 		if err != nil {
 			common.LogError(ctx, "error consuming token remain quota: "+err.Error())
 		}
 	}
+	if relayInfo.IsBatch {
+		logContent += fmt.Sprintf("，批处理折扣 %.2f", operation_setting.GetBatchSetting().DiscountRatio)
+	}
 
 	logModel := relayInfo.OriginModelName
 	if extraContent != "" {
@@ -411,13 +496,16 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	}
 	other := GenerateAudioOtherInfo(ctx, relayInfo, usage, modelRatio, groupRatio,
 		completionRatio.InexactFloat64(), audioRatio.InexactFloat64(), audioCompletionRatio.InexactFloat64(), modelPrice, priceData.GroupRatioInfo.GroupSpecialRatio)
+	if isExact {
+		other["exact_price"] = priceData.ExactPrice
+	}
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     usage.PromptTokens,
 		CompletionTokens: usage.CompletionTokens,
 		ModelName:        logModel,
 		TokenName:        tokenName,
-		Quota:            quota,
+		Quota:            discountedQuota,
 		Content:          logContent,
 		TokenId:          relayInfo.TokenId,
 		UserQuota:        userQuota,
@@ -425,6 +513,7 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		IsStream:         relayInfo.IsStream,
 		Group:            relayInfo.UsingGroup,
 		Other:            other,
+		Cost:             ExactCost(relayInfo, quotaDecimal),
 	})
 }
 
@@ -432,7 +521,10 @@ func PreConsumeTokenQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
 	}
-	if relayInfo.IsPlayground {
+	if err := CheckSpendingBudgets(relayInfo, quota); err != nil {
+		return err
+	}
+	if relayInfo.IsPlayground || quota == 0 {
 		return nil
 	}
 	//if relayInfo.TokenUnlimited {
@@ -452,12 +544,28 @@ func PreConsumeTokenQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
 	return nil
 }
 
+// BatchDiscountQuota 计算批处理请求折扣后的额度，非批处理请求原样返回
+func BatchDiscountQuota(relayInfo *relaycommon.RelayInfo, quota int) int {
+	if relayInfo == nil || !relayInfo.IsBatch || quota <= 0 {
+		return quota
+	}
+	discountRatio := operation_setting.GetBatchSetting().DiscountRatio
+	if discountRatio < 0 || discountRatio >= 1 {
+		return quota
+	}
+	return int(decimal.NewFromInt(int64(quota)).Mul(decimal.NewFromFloat(discountRatio)).Round(0).IntPart())
+}
+
 func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQuota int, sendEmail bool) (err error) {
+	if relayInfo.IsBatch {
+		// 批处理请求按折扣结算，quota 为相对预扣额度的差值
+		quota = BatchDiscountQuota(relayInfo, quota+preConsumedQuota) - preConsumedQuota
+	}
 
 	if quota > 0 {
-		err = model.DecreaseUserQuota(relayInfo.UserId, quota)
+		err = model.SettleBillingQuota(relayInfo.UserId, relayInfo.OrganizationId, quota)
 	} else {
-		err = model.IncreaseUserQuota(relayInfo.UserId, -quota, false)
+		err = model.IncreaseBillingQuota(relayInfo.UserId, relayInfo.OrganizationId, -quota)
 	}
 	if err != nil {
 		return err
@@ -474,7 +582,8 @@ func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQu
 		}
 	}
 
-	if sendEmail {
+	// 组织额度池的余额不是用户个人额度，不发送额度提醒
+	if sendEmail && relayInfo.OrganizationId == 0 {
 		if (quota + preConsumedQuota) != 0 {
 			checkAndSendQuotaNotify(relayInfo, quota, preConsumedQuota)
 		}
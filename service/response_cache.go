@@ -0,0 +1,335 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+const responseCacheKeyPrefix = "response_cache:"
+
+// ResponseCacheEntry 缓存的非流式 chat completion 响应及其用量，流式请求命中时由此重放为 SSE
+type ResponseCacheEntry struct {
+	Response  dto.OpenAITextResponse `json:"response"`
+	Usage     dto.Usage              `json:"usage"`
+	CreatedAt int64                  `json:"created_at"`
+}
+
+type responseCacheBackend interface {
+	Get(key string) (string, bool)
+	Set(key string, value string, ttl time.Duration) error
+}
+
+type redisResponseCache struct{}
+
+func (redisResponseCache) Get(key string) (string, bool) {
+	value, err := common.RedisGet(key)
+	if err != nil {
+		return "", false
+	}
+	return value, true
+}
+
+func (redisResponseCache) Set(key string, value string, ttl time.Duration) error {
+	return common.RedisSet(key, value, ttl)
+}
+
+type memoryResponseCacheItem struct {
+	value    string
+	expireAt time.Time
+}
+
+type memoryResponseCache struct {
+	sync.Mutex
+	items map[string]memoryResponseCacheItem
+}
+
+func (m *memoryResponseCache) Get(key string) (string, bool) {
+	m.Lock()
+	defer m.Unlock()
+	item, ok := m.items[key]
+	if !ok {
+		return "", false
+	}
+	if time.Now().After(item.expireAt) {
+		delete(m.items, key)
+		return "", false
+	}
+	return item.value, true
+}
+
+func (m *memoryResponseCache) Set(key string, value string, ttl time.Duration) error {
+	m.Lock()
+	defer m.Unlock()
+	maxEntries := operation_setting.GetResponseCacheSetting().MaxMemoryEntries
+	if maxEntries > 0 && len(m.items) >= maxEntries {
+		// 先清理过期条目，仍然已满时随机淘汰
+		now := time.Now()
+		for k, item := range m.items {
+			if now.After(item.expireAt) {
+				delete(m.items, k)
+			}
+		}
+		for k := range m.items {
+			if len(m.items) < maxEntries {
+				break
+			}
+			delete(m.items, k)
+		}
+	}
+	m.items[key] = memoryResponseCacheItem{value: value, expireAt: time.Now().Add(ttl)}
+	return nil
+}
+
+var memoryResponseCacheBackend = &memoryResponseCache{items: make(map[string]memoryResponseCacheItem)}
+
+func getResponseCacheBackend() responseCacheBackend {
+	switch operation_setting.GetResponseCacheSetting().Backend {
+	case operation_setting.ResponseCacheBackendMemory:
+		return memoryResponseCacheBackend
+	case operation_setting.ResponseCacheBackendRedis:
+		if common.RedisEnabled {
+			return redisResponseCache{}
+		}
+		return memoryResponseCacheBackend
+	default:
+		if common.RedisEnabled {
+			return redisResponseCache{}
+		}
+		return memoryResponseCacheBackend
+	}
+}
+
+// IsResponseCacheableRequest 请求是否可缓存：单个候选且（按配置）temperature 为 0
+func IsResponseCacheableRequest(request *dto.GeneralOpenAIRequest) bool {
+	if request.N > 1 || len(request.Modalities) > 0 || len(request.Audio) > 0 {
+		return false
+	}
+	if operation_setting.GetResponseCacheSetting().RequireZeroTemperature {
+		return request.Temperature != nil && *request.Temperature == 0
+	}
+	return true
+}
+
+// ResponseCacheKey 根据规范化后的请求体生成缓存 key，去除 stream 等不影响结果的字段
+func ResponseCacheKey(info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) (string, error) {
+	keyRequest := *request
+	keyRequest.Model = info.OriginModelName
+	keyRequest.Stream = false
+	keyRequest.StreamOptions = nil
+	keyRequest.User = ""
+	data, err := common.Marshal(keyRequest)
+	if err != nil {
+		return "", err
+	}
+	scope := "shared"
+	if !operation_setting.GetResponseCacheSetting().ShareAcrossUsers {
+		scope = fmt.Sprintf("user:%d", info.UserId)
+	}
+	sum := sha256.Sum256(append([]byte(scope+"\n"), data...))
+	return responseCacheKeyPrefix + hex.EncodeToString(sum[:]), nil
+}
+
+func GetResponseCache(key string) *ResponseCacheEntry {
+	value, ok := getResponseCacheBackend().Get(key)
+	if !ok {
+		return nil
+	}
+	var entry ResponseCacheEntry
+	if err := common.UnmarshalJsonStr(value, &entry); err != nil || len(entry.Response.Choices) == 0 {
+		return nil
+	}
+	return &entry
+}
+
+// SaveResponseCache 解析上游返回给客户端的响应（JSON 或 SSE）并写入缓存
+func SaveResponseCache(key string, body []byte, isStream bool, usage *dto.Usage) error {
+	var response *dto.OpenAITextResponse
+	var err error
+	if isStream {
+		response, err = aggregateStreamResponse(body)
+	} else {
+		response = &dto.OpenAITextResponse{}
+		err = common.Unmarshal(body, response)
+	}
+	if err != nil {
+		return err
+	}
+	if response.Error != nil || len(response.Choices) == 0 {
+		return fmt.Errorf("response is not cacheable")
+	}
+	entry := ResponseCacheEntry{
+		Response:  *response,
+		CreatedAt: common.GetTimestamp(),
+	}
+	if usage != nil {
+		entry.Usage = *usage
+	}
+	entry.Response.Usage = entry.Usage
+	data, err := common.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	cacheSetting := operation_setting.GetResponseCacheSetting()
+	if cacheSetting.MaxEntryBytes > 0 && len(data) > cacheSetting.MaxEntryBytes {
+		return fmt.Errorf("response size %d exceeds max entry bytes", len(data))
+	}
+	return getResponseCacheBackend().Set(key, string(data), time.Duration(cacheSetting.TTLSeconds)*time.Second)
+}
+
+// aggregateStreamResponse 将 chat completion chunk 合并为完整响应
+func aggregateStreamResponse(body []byte) (*dto.OpenAITextResponse, error) {
+	type choiceBuilder struct {
+		content      strings.Builder
+		reasoning    strings.Builder
+		toolCalls    []dto.ToolCallResponse
+		finishReason string
+	}
+	response := &dto.OpenAITextResponse{Object: "chat.completion"}
+	builders := make(map[int]*choiceBuilder)
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), len(body)+1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "" || data == "[DONE]" {
+			continue
+		}
+		var chunk dto.ChatCompletionsStreamResponse
+		if err := common.UnmarshalJsonStr(data, &chunk); err != nil {
+			return nil, err
+		}
+		if response.Id == "" {
+			response.Id = chunk.Id
+			response.Model = chunk.Model
+			response.Created = chunk.Created
+		}
+		for _, choice := range chunk.Choices {
+			builder, ok := builders[choice.Index]
+			if !ok {
+				builder = &choiceBuilder{}
+				builders[choice.Index] = builder
+			}
+			builder.content.WriteString(choice.Delta.GetContentString())
+			builder.reasoning.WriteString(choice.Delta.GetReasoningContent())
+			for _, toolCall := range choice.Delta.ToolCalls {
+				index := len(builder.toolCalls)
+				if toolCall.Index != nil {
+					index = *toolCall.Index
+				}
+				for len(builder.toolCalls) <= index {
+					builder.toolCalls = append(builder.toolCalls, dto.ToolCallResponse{})
+				}
+				call := &builder.toolCalls[index]
+				if toolCall.ID != "" {
+					call.ID = toolCall.ID
+				}
+				if toolCall.Type != nil {
+					call.Type = toolCall.Type
+				}
+				if toolCall.Function.Name != "" {
+					call.Function.Name = toolCall.Function.Name
+				}
+				call.Function.Arguments += toolCall.Function.Arguments
+			}
+			if choice.FinishReason != nil && *choice.FinishReason != "" {
+				builder.finishReason = *choice.FinishReason
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	indexes := make([]int, 0, len(builders))
+	for index := range builders {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	for _, index := range indexes {
+		builder := builders[index]
+		message := dto.Message{
+			Role:             "assistant",
+			ReasoningContent: builder.reasoning.String(),
+		}
+		message.SetStringContent(builder.content.String())
+		if len(builder.toolCalls) > 0 {
+			message.SetToolCalls(builder.toolCalls)
+		}
+		response.Choices = append(response.Choices, dto.OpenAITextResponseChoice{
+			Index:        index,
+			Message:      message,
+			FinishReason: builder.finishReason,
+		})
+	}
+	return response, nil
+}
+
+// ResponseCacheHitQuota 计算命中响应缓存后的额度
+func ResponseCacheHitQuota(quota int) int {
+	hitRatio := operation_setting.GetResponseCacheSetting().HitPriceRatio
+	if quota <= 0 || hitRatio < 0 || hitRatio >= 1 {
+		return quota
+	}
+	return int(decimal.NewFromInt(int64(quota)).Mul(decimal.NewFromFloat(hitRatio)).Round(0).IntPart())
+}
+
+// ResponseCacheWriter 在写给客户端的同时记录响应内容，超过上限后停止记录
+type ResponseCacheWriter struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func NewResponseCacheWriter(writer gin.ResponseWriter) *ResponseCacheWriter {
+	return &ResponseCacheWriter{
+		ResponseWriter: writer,
+		limit:          operation_setting.GetResponseCacheSetting().MaxEntryBytes,
+	}
+}
+
+func (w *ResponseCacheWriter) record(data []byte) {
+	if w.overflow {
+		return
+	}
+	if w.limit > 0 && w.body.Len()+len(data) > w.limit {
+		w.overflow = true
+		w.body.Reset()
+		return
+	}
+	w.body.Write(data)
+}
+
+func (w *ResponseCacheWriter) Write(data []byte) (int, error) {
+	w.record(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *ResponseCacheWriter) WriteString(s string) (int, error) {
+	w.record([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Body 返回记录的响应内容，超过上限时返回 nil
+func (w *ResponseCacheWriter) Body() []byte {
+	if w.overflow {
+		return nil
+	}
+	return bytes.Clone(w.body.Bytes())
+}
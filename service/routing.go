@@ -0,0 +1,222 @@
+package service
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoutingRequest 路由规则匹配使用的请求特征
+type RoutingRequest struct {
+	TokenId      int               `json:"token_id"`
+	UserId       int64             `json:"user_id"`
+	Group        string            `json:"group"`
+	Model        string            `json:"model"`
+	Headers      map[string]string `json:"headers"`
+	PromptLength int               `json:"prompt_length"`
+	HasTools     bool              `json:"has_tools"`
+	HasImages    bool              `json:"has_images"`
+	Stream       bool              `json:"stream"`
+	// 请求时间，格式 HH:MM，为空表示当前时间
+	Time string `json:"time,omitempty"`
+}
+
+// RoutingRuleResult 单条规则的匹配结果，用于试运行时解释命中情况
+type RoutingRuleResult struct {
+	Name    string `json:"name"`
+	Matched bool   `json:"matched"`
+	Reason  string `json:"reason"`
+}
+
+// NewRoutingRequest 从请求中提取路由规则需要的特征
+func NewRoutingRequest(c *gin.Context, group string, modelName string) *RoutingRequest {
+	request := &RoutingRequest{
+		TokenId: c.GetInt("token_id"),
+		UserId:  c.GetInt64("id"),
+		Group:   group,
+		Model:   modelName,
+		Headers: make(map[string]string, len(c.Request.Header)),
+	}
+	for key := range c.Request.Header {
+		request.Headers[strings.ToLower(key)] = c.Request.Header.Get(key)
+	}
+	var body struct {
+		Stream    bool          `json:"stream"`
+		Tools     []any         `json:"tools"`
+		Functions []any         `json:"functions"`
+		Messages  []dto.Message `json:"messages"`
+		System    any           `json:"system"`
+		Prompt    any           `json:"prompt"`
+		Input     any           `json:"input"`
+	}
+	if err := common.UnmarshalBodyReusable(c, &body); err != nil {
+		return request
+	}
+	request.Stream = body.Stream
+	request.HasTools = len(body.Tools) > 0 || len(body.Functions) > 0
+	for _, value := range []any{body.System, body.Prompt, body.Input} {
+		if text, ok := value.(string); ok {
+			request.PromptLength += utf8.RuneCountInString(text)
+		}
+	}
+	for i := range body.Messages {
+		for _, content := range body.Messages[i].ParseContent() {
+			switch content.Type {
+			case dto.ContentTypeText:
+				request.PromptLength += utf8.RuneCountInString(content.Text)
+			case dto.ContentTypeImageURL, "image":
+				request.HasImages = true
+			}
+		}
+	}
+	return request
+}
+
+// routingGlobMatch 通配符匹配，* 匹配任意字符串，? 匹配单个字符
+func routingGlobMatch(pattern string, value string) bool {
+	p, v := []rune(pattern), []rune(value)
+	pi, vi := 0, 0
+	star, match := -1, 0
+	for vi < len(v) {
+		if pi < len(p) && (p[pi] == '?' || p[pi] == v[vi]) {
+			pi++
+			vi++
+		} else if pi < len(p) && p[pi] == '*' {
+			star, match = pi, vi
+			pi++
+		} else if star >= 0 {
+			pi = star + 1
+			match++
+			vi = match
+		} else {
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
+
+func inTimeRange(timeRange string, minute int) bool {
+	start, end, err := operation_setting.ParseTimeRange(timeRange)
+	if err != nil {
+		return false
+	}
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	// 跨天的时间段
+	return minute >= start || minute < end
+}
+
+func containsValue[T comparable](values []T, value T) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRoutingRule 判断请求是否满足规则的所有条件，不满足时返回原因
+func matchRoutingRule(rule *operation_setting.RoutingRule, request *RoutingRequest, minute int) (bool, string) {
+	match := rule.Match
+	if rule.Disabled {
+		return false, "rule disabled"
+	}
+	if len(match.TokenIds) > 0 && !containsValue(match.TokenIds, request.TokenId) {
+		return false, fmt.Sprintf("token %d not in token_ids", request.TokenId)
+	}
+	if len(match.UserIds) > 0 && !containsValue(match.UserIds, request.UserId) {
+		return false, fmt.Sprintf("user %d not in user_ids", request.UserId)
+	}
+	if len(match.Groups) > 0 && !containsValue(match.Groups, request.Group) {
+		return false, fmt.Sprintf("group %s not in groups", request.Group)
+	}
+	if len(match.Models) > 0 {
+		matched := false
+		for _, pattern := range match.Models {
+			if routingGlobMatch(pattern, request.Model) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, fmt.Sprintf("model %s does not match models", request.Model)
+		}
+	}
+	for name, pattern := range match.Headers {
+		value := request.Headers[strings.ToLower(name)]
+		if !routingGlobMatch(pattern, value) {
+			return false, fmt.Sprintf("header %s=%q does not match %q", name, value, pattern)
+		}
+	}
+	if match.MinPromptLength > 0 && request.PromptLength < match.MinPromptLength {
+		return false, fmt.Sprintf("prompt length %d < %d", request.PromptLength, match.MinPromptLength)
+	}
+	if match.MaxPromptLength > 0 && request.PromptLength > match.MaxPromptLength {
+		return false, fmt.Sprintf("prompt length %d > %d", request.PromptLength, match.MaxPromptLength)
+	}
+	if match.HasTools != nil && *match.HasTools != request.HasTools {
+		return false, fmt.Sprintf("has_tools is %t", request.HasTools)
+	}
+	if match.HasImages != nil && *match.HasImages != request.HasImages {
+		return false, fmt.Sprintf("has_images is %t", request.HasImages)
+	}
+	if match.Stream != nil && *match.Stream != request.Stream {
+		return false, fmt.Sprintf("stream is %t", request.Stream)
+	}
+	if len(match.TimeRanges) > 0 {
+		matched := false
+		for _, timeRange := range match.TimeRanges {
+			if inTimeRange(timeRange, minute) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, fmt.Sprintf("time %02d:%02d not in time_ranges", minute/60, minute%60)
+		}
+	}
+	return true, "all conditions matched"
+}
+
+// MatchRoutingRule 按顺序匹配路由规则，返回第一条命中的规则以及到该规则为止每条规则的匹配结果
+func MatchRoutingRule(request *RoutingRequest) (*operation_setting.RoutingRule, []RoutingRuleResult) {
+	now := time.Now()
+	minute := now.Hour()*60 + now.Minute()
+	if request.Time != "" {
+		if t, err := time.Parse("15:04", request.Time); err == nil {
+			minute = t.Hour()*60 + t.Minute()
+		}
+	}
+	rules := operation_setting.GetRoutingSetting().Rules
+	results := make([]RoutingRuleResult, 0, len(rules))
+	for i := range rules {
+		matched, reason := matchRoutingRule(&rules[i], request, minute)
+		results = append(results, RoutingRuleResult{Name: rules[i].Name, Matched: matched, Reason: reason})
+		if matched {
+			return &rules[i], results
+		}
+	}
+	return nil, results
+}
+
+// RoutingChannelAllowed 渠道是否在规则限定的范围内
+func RoutingChannelAllowed(action *operation_setting.RoutingAction, channel *model.Channel) bool {
+	if action.ChannelTag != "" && channel.GetTag() != action.ChannelTag {
+		return false
+	}
+	if len(action.ChannelIds) > 0 && !containsValue(action.ChannelIds, channel.Id) {
+		return false
+	}
+	return true
+}
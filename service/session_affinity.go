@@ -0,0 +1,151 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/setting/operation_setting"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const sessionAffinityKeyPrefix = "session_affinity:"
+
+// 内存中最多保留的绑定数，超过时先清理过期绑定
+const sessionAffinityMaxMemoryEntries = 100000
+
+// SessionAffinityBinding 会话绑定的渠道和多 Key 渠道中的 Key 索引
+type SessionAffinityBinding struct {
+	ChannelId int
+	KeyIndex  int
+}
+
+type sessionAffinityItem struct {
+	binding  SessionAffinityBinding
+	expireAt time.Time
+}
+
+var sessionAffinityLock sync.Mutex
+var sessionAffinityMemory = make(map[string]sessionAffinityItem)
+
+// GetSessionAffinityKey 计算请求的会话标识，优先使用会话请求头，其次使用系统提示和前几条消息的哈希，无法识别时返回空
+func GetSessionAffinityKey(c *gin.Context, group string, modelName string) string {
+	affinitySetting := operation_setting.GetSessionAffinitySetting()
+	if !affinitySetting.Enabled || !operation_setting.IsSessionAffinityGroupEnabled(group) {
+		return ""
+	}
+	if affinitySetting.Header != "" {
+		if session := c.GetHeader(affinitySetting.Header); session != "" {
+			// 客户端传入的标识按用户隔离
+			return fmt.Sprintf("%s%s:%s:h:%d:%s", sessionAffinityKeyPrefix, group, modelName, c.GetInt64("id"), session)
+		}
+	}
+	if affinitySetting.HashMessages <= 0 {
+		return ""
+	}
+	var body struct {
+		System       json.RawMessage   `json:"system"`
+		Instructions string            `json:"instructions"`
+		Messages     []json.RawMessage `json:"messages"`
+	}
+	if err := common.UnmarshalBodyReusable(c, &body); err != nil {
+		return ""
+	}
+	messages := body.Messages
+	if len(messages) > affinitySetting.HashMessages {
+		messages = messages[:affinitySetting.HashMessages]
+	}
+	if len(body.System) == 0 && body.Instructions == "" && len(messages) == 0 {
+		return ""
+	}
+	hash := sha256.New()
+	hash.Write(body.System)
+	hash.Write([]byte("\n" + body.Instructions))
+	for _, message := range messages {
+		hash.Write([]byte("\n"))
+		hash.Write(message)
+	}
+	return fmt.Sprintf("%s%s:%s:p:%s", sessionAffinityKeyPrefix, group, modelName, hex.EncodeToString(hash.Sum(nil)))
+}
+
+// GetSessionAffinity 查询会话绑定的渠道
+func GetSessionAffinity(key string) (*SessionAffinityBinding, bool) {
+	if common.RedisEnabled {
+		value, err := common.RedisGet(key)
+		if err != nil {
+			return nil, false
+		}
+		parts := strings.Split(value, ":")
+		if len(parts) != 2 {
+			return nil, false
+		}
+		channelId, err1 := strconv.Atoi(parts[0])
+		keyIndex, err2 := strconv.Atoi(parts[1])
+		if err1 != nil || err2 != nil {
+			return nil, false
+		}
+		return &SessionAffinityBinding{ChannelId: channelId, KeyIndex: keyIndex}, true
+	}
+	sessionAffinityLock.Lock()
+	defer sessionAffinityLock.Unlock()
+	item, ok := sessionAffinityMemory[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(item.expireAt) {
+		delete(sessionAffinityMemory, key)
+		return nil, false
+	}
+	binding := item.binding
+	return &binding, true
+}
+
+func setSessionAffinity(key string, binding SessionAffinityBinding, ttl time.Duration) error {
+	if common.RedisEnabled {
+		return common.RedisSet(key, fmt.Sprintf("%d:%d", binding.ChannelId, binding.KeyIndex), ttl)
+	}
+	sessionAffinityLock.Lock()
+	defer sessionAffinityLock.Unlock()
+	if len(sessionAffinityMemory) >= sessionAffinityMaxMemoryEntries {
+		now := time.Now()
+		for k, item := range sessionAffinityMemory {
+			if now.After(item.expireAt) {
+				delete(sessionAffinityMemory, k)
+			}
+		}
+		if len(sessionAffinityMemory) >= sessionAffinityMaxMemoryEntries {
+			return fmt.Errorf("too many session affinity bindings")
+		}
+	}
+	sessionAffinityMemory[key] = sessionAffinityItem{binding: binding, expireAt: time.Now().Add(ttl)}
+	return nil
+}
+
+// BindSessionAffinity 请求成功后将会话绑定到本次使用的渠道和 Key，并刷新有效期
+func BindSessionAffinity(c *gin.Context) {
+	key := common.GetContextKeyString(c, constant.ContextKeySessionAffinity)
+	if key == "" {
+		return
+	}
+	binding := SessionAffinityBinding{
+		ChannelId: common.GetContextKeyInt(c, constant.ContextKeyChannelId),
+		KeyIndex:  -1,
+	}
+	if common.GetContextKeyBool(c, constant.ContextKeyChannelIsMultiKey) {
+		binding.KeyIndex = common.GetContextKeyInt(c, constant.ContextKeyChannelMultiKeyIndex)
+	}
+	ttl := time.Duration(operation_setting.GetSessionAffinitySetting().TTLSeconds) * time.Second
+	if ttl <= 0 {
+		return
+	}
+	if err := setSessionAffinity(key, binding, ttl); err != nil {
+		common.LogError(c, "failed to save session affinity: "+err.Error())
+	}
+}
@@ -214,7 +214,15 @@ func updateConfigFromMap(config interface{}, configMap map[string]string) error
 				continue
 			}
 			field.SetFloat(floatValue)
-		case reflect.Map, reflect.Slice, reflect.Struct:
+		case reflect.Map, reflect.Slice:
+			// 解析到新值后整体替换，避免与旧的元素或键合并
+			newValue := reflect.New(field.Type())
+			err := json.Unmarshal([]byte(strValue), newValue.Interface())
+			if err != nil {
+				continue
+			}
+			field.Set(newValue.Elem())
+		case reflect.Struct:
 			// 复杂类型使用JSON反序列化
 			err := json.Unmarshal([]byte(strValue), field.Addr().Interface())
 			if err != nil {
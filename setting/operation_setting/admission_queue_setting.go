@@ -0,0 +1,67 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type AdmissionQueueSetting struct {
+	// 分组内渠道全部限流时不立即报错，请求排队等待后按优先级重新尝试
+	Enabled bool `json:"enabled"`
+	// 单个请求最长排队时间，超过后返回 429
+	MaxWaitSeconds int `json:"max_wait_seconds"`
+	// 每个节点最多排队的请求数，已满时直接返回 429
+	MaxQueueLength int `json:"max_queue_length"`
+	// 没有请求完成时，每隔该时间让队首请求重新尝试一次
+	RetryIntervalMs int `json:"retry_interval_ms"`
+	// 排队超时后返回给客户端的 Retry-After 秒数
+	RetryAfterSeconds int `json:"retry_after_seconds"`
+	// 启用排队的分组，为空表示所有分组
+	Groups []string `json:"groups"`
+	// 分组优先级，数值越大越先被服务，未设置为 0
+	GroupPriorities map[string]int `json:"group_priorities"`
+	// 令牌优先级，令牌 id -> 优先级，优先于分组优先级
+	TokenPriorities map[int]int `json:"token_priorities"`
+}
+
+// 默认配置
+var admissionQueueSetting = AdmissionQueueSetting{
+	Enabled:           false,
+	MaxWaitSeconds:    30,
+	MaxQueueLength:    500,
+	RetryIntervalMs:   1000,
+	RetryAfterSeconds: 10,
+	Groups:            []string{},
+	GroupPriorities:   map[string]int{},
+	TokenPriorities:   map[int]int{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("admission_queue_setting", &admissionQueueSetting)
+}
+
+func GetAdmissionQueueSetting() *AdmissionQueueSetting {
+	return &admissionQueueSetting
+}
+
+// IsAdmissionQueueGroupEnabled 分组是否启用排队
+func IsAdmissionQueueGroupEnabled(group string) bool {
+	if !admissionQueueSetting.Enabled || admissionQueueSetting.MaxWaitSeconds <= 0 {
+		return false
+	}
+	if len(admissionQueueSetting.Groups) == 0 {
+		return true
+	}
+	for _, g := range admissionQueueSetting.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAdmissionPriority 获取请求的排队优先级，令牌设置优先于分组设置
+func GetAdmissionPriority(tokenId int, group string) int {
+	if priority, ok := admissionQueueSetting.TokenPriorities[tokenId]; ok {
+		return priority
+	}
+	return admissionQueueSetting.GroupPriorities[group]
+}
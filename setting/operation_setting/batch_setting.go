@@ -0,0 +1,39 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type BatchSetting struct {
+	Enabled bool `json:"enabled"`
+	// 批处理请求计费折扣，最终扣费 = 正常扣费 * DiscountRatio
+	DiscountRatio float64 `json:"discount_ratio"`
+	// 同时执行的请求数
+	WorkerCount int `json:"worker_count"`
+	// 单个渠道同时执行的批处理请求数上限，0 表示不限制
+	ChannelConcurrency int `json:"channel_concurrency"`
+	// 单个批处理任务的最大请求数
+	MaxRequests int `json:"max_requests"`
+	// 输入文件已透传到上游时，直接转发到上游的 /v1/batches
+	UpstreamEnabled bool `json:"upstream_enabled"`
+	// 轮询间隔（秒）
+	PollIntervalSeconds int `json:"poll_interval_seconds"`
+}
+
+// 默认配置
+var batchSetting = BatchSetting{
+	Enabled:             true,
+	DiscountRatio:       0.5,
+	WorkerCount:         8,
+	ChannelConcurrency:  2,
+	MaxRequests:         50000,
+	UpstreamEnabled:     true,
+	PollIntervalSeconds: 10,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("batch_setting", &batchSetting)
+}
+
+func GetBatchSetting() *BatchSetting {
+	return &batchSetting
+}
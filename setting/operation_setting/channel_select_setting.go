@@ -0,0 +1,57 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+const (
+	// ChannelSelectWeightedRandom 按优先级和静态权重随机选择（默认）
+	ChannelSelectWeightedRandom = "weighted_random"
+	// ChannelSelectAdaptive 按成功率、首字时间和输出速度调整权重后随机选择
+	ChannelSelectAdaptive = "adaptive"
+	// ChannelSelectPowerOfTwo 按静态权重随机抽取两个渠道，选择表现更好的一个
+	ChannelSelectPowerOfTwo = "p2c"
+)
+
+type ChannelSelectSetting struct {
+	// 默认选择策略
+	DefaultStrategy string `json:"default_strategy"`
+	// 分组单独设置的选择策略，key 为分组
+	GroupStrategies map[string]string `json:"group_strategies"`
+	// EWMA 平滑系数，越大越偏向最近的请求
+	EWMAAlpha float64 `json:"ewma_alpha"`
+	// 样本数少于该值时视为没有统计，按静态权重选择
+	MinSamples int `json:"min_samples"`
+	// 统计超过该时长（秒）没有更新则失效
+	StatsTTLSeconds int64 `json:"stats_ttl_seconds"`
+	// 成功率的指数，越大对失败越敏感
+	SuccessRateExponent float64 `json:"success_rate_exponent"`
+}
+
+// 默认配置
+var channelSelectSetting = ChannelSelectSetting{
+	DefaultStrategy:     ChannelSelectWeightedRandom,
+	GroupStrategies:     map[string]string{},
+	EWMAAlpha:           0.2,
+	MinSamples:          5,
+	StatsTTLSeconds:     600,
+	SuccessRateExponent: 2,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("channel_select_setting", &channelSelectSetting)
+}
+
+func GetChannelSelectSetting() *ChannelSelectSetting {
+	return &channelSelectSetting
+}
+
+// GetGroupChannelSelectStrategy 获取分组使用的渠道选择策略
+func GetGroupChannelSelectStrategy(group string) string {
+	if strategy, ok := channelSelectSetting.GroupStrategies[group]; ok && strategy != "" {
+		return strategy
+	}
+	if channelSelectSetting.DefaultStrategy == "" {
+		return ChannelSelectWeightedRandom
+	}
+	return channelSelectSetting.DefaultStrategy
+}
@@ -0,0 +1,37 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type CircuitBreakerSetting struct {
+	// 开启后连续失败的渠道（及多 Key 渠道的单个 Key）会被暂时移出选择，冷却后自动探测恢复
+	Enabled bool `json:"enabled"`
+	// 连续失败多少次后熔断
+	FailureThreshold int `json:"failure_threshold"`
+	// 首次熔断的冷却时间（秒），再次熔断时翻倍
+	CooldownSeconds int64 `json:"cooldown_seconds"`
+	// 冷却时间上限（秒）
+	MaxCooldownSeconds int64 `json:"max_cooldown_seconds"`
+	// 半开状态下同时放行的探测请求数
+	HalfOpenMaxRequests int `json:"half_open_max_requests"`
+	// 半开状态下连续成功多少次后恢复
+	HalfOpenSuccessThreshold int `json:"half_open_success_threshold"`
+}
+
+// 默认配置
+var circuitBreakerSetting = CircuitBreakerSetting{
+	Enabled:                  false,
+	FailureThreshold:         5,
+	CooldownSeconds:          30,
+	MaxCooldownSeconds:       600,
+	HalfOpenMaxRequests:      1,
+	HalfOpenSuccessThreshold: 2,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("circuit_breaker_setting", &circuitBreakerSetting)
+}
+
+func GetCircuitBreakerSetting() *CircuitBreakerSetting {
+	return &circuitBreakerSetting
+}
@@ -0,0 +1,67 @@
+package operation_setting
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-api/setting/config"
+
+	"github.com/shopspring/decimal"
+)
+
+// ExactModelPrice 每百万 tokens 的美元价格，缓存价格未设置时按输入价格乘以缓存倍率计算
+type ExactModelPrice struct {
+	Input      decimal.Decimal  `json:"input"`
+	Output     decimal.Decimal  `json:"output"`
+	CacheRead  *decimal.Decimal `json:"cache_read,omitempty"`
+	CacheWrite *decimal.Decimal `json:"cache_write,omitempty"`
+}
+
+type ExactBillingSetting struct {
+	// 开启后按美元精确计费，不足 1 额度的部分累计到用户的额度余数中，不再被舍入
+	Enabled bool `json:"enabled"`
+	// 模型名称到每百万 tokens 美元价格的映射，未配置的模型沿用模型倍率
+	ModelPrices map[string]ExactModelPrice `json:"model_prices"`
+}
+
+// 默认配置
+var exactBillingSetting = ExactBillingSetting{
+	Enabled:     false,
+	ModelPrices: map[string]ExactModelPrice{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("exact_billing_setting", &exactBillingSetting)
+}
+
+func GetExactBillingSetting() *ExactBillingSetting {
+	return &exactBillingSetting
+}
+
+// GetExactModelPrice 获取模型的精确价格，未开启或未配置时返回 false
+func GetExactModelPrice(modelName string) (*ExactModelPrice, bool) {
+	if !exactBillingSetting.Enabled {
+		return nil, false
+	}
+	price, ok := exactBillingSetting.ModelPrices[modelName]
+	if !ok {
+		return nil, false
+	}
+	return &price, true
+}
+
+// ValidateExactModelPrices 校验通过选项接口提交的精确价格
+func ValidateExactModelPrices(pricesStr string) error {
+	var prices map[string]ExactModelPrice
+	if err := json.Unmarshal([]byte(pricesStr), &prices); err != nil {
+		return fmt.Errorf("精确价格格式错误: %s", err.Error())
+	}
+	for model, price := range prices {
+		if price.Input.IsNegative() || price.Output.IsNegative() ||
+			(price.CacheRead != nil && price.CacheRead.IsNegative()) ||
+			(price.CacheWrite != nil && price.CacheWrite.IsNegative()) {
+			return fmt.Errorf("模型 %s 的价格不能为负数", model)
+		}
+	}
+	return nil
+}
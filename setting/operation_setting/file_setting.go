@@ -0,0 +1,49 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type FileSetting struct {
+	// 存储后端，目前支持 local
+	StorageType string `json:"storage_type"`
+	LocalPath   string `json:"local_path"`
+	// 单文件大小上限（MB）
+	MaxFileSizeMB int `json:"max_file_size_mb"`
+	// 每个用户可存储的总字节数，0 表示不限制
+	UserQuotaBytes int64 `json:"user_quota_bytes"`
+	// 透传到上游渠道，文件与渠道绑定，后续引用该 file_id 的请求会路由到同一渠道
+	PassThroughEnabled bool     `json:"pass_through_enabled"`
+	PassThroughModel   string   `json:"pass_through_model"`
+	PassThroughPurpose []string `json:"pass_through_purpose"`
+}
+
+// 默认配置
+var fileSetting = FileSetting{
+	StorageType:        "local",
+	LocalPath:          "./data/files",
+	MaxFileSizeMB:      512,
+	UserQuotaBytes:     0,
+	PassThroughEnabled: false,
+	PassThroughModel:   "gpt-4o-mini",
+	PassThroughPurpose: []string{"batch", "fine-tune"},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("file_setting", &fileSetting)
+}
+
+func GetFileSetting() *FileSetting {
+	return &fileSetting
+}
+
+func (s *FileSetting) ShouldPassThrough(purpose string) bool {
+	if !s.PassThroughEnabled {
+		return false
+	}
+	for _, p := range s.PassThroughPurpose {
+		if p == purpose {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type FineTuneSetting struct {
+	Enabled bool `json:"enabled"`
+	// 创建微调任务时默认选择渠道所用的模型，请求未指定可用渠道的模型时使用
+	DefaultModel string `json:"default_model"`
+	// 微调模型计费倍率，在基础模型倍率/价格上再乘以该值
+	ModelRatio float64 `json:"model_ratio"`
+	// 轮询间隔（秒）
+	PollIntervalSeconds int `json:"poll_interval_seconds"`
+}
+
+// 默认配置
+var fineTuneSetting = FineTuneSetting{
+	Enabled:             true,
+	DefaultModel:        "gpt-4o-mini",
+	ModelRatio:          2,
+	PollIntervalSeconds: 60,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("fine_tune_setting", &fineTuneSetting)
+}
+
+func GetFineTuneSetting() *FineTuneSetting {
+	return &fineTuneSetting
+}
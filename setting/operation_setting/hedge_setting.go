@@ -0,0 +1,44 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type HedgeSetting struct {
+	// 非流式请求在首个渠道超过 DelayMs 仍未返回首字节时，向第二个渠道发起相同请求，采用先返回的结果
+	Enabled bool `json:"enabled"`
+	// 发起对冲请求前的等待时间（毫秒）
+	DelayMs int `json:"delay_ms"`
+	// 启用对冲请求的分组，为空表示所有分组
+	Groups []string `json:"groups"`
+	// 未采用的请求继续执行并计费，关闭时取消未采用的请求，只对采用的请求计费
+	ChargeAllRaced bool `json:"charge_all_raced"`
+}
+
+// 默认配置
+var hedgeSetting = HedgeSetting{
+	Enabled:        false,
+	DelayMs:        3000,
+	Groups:         []string{},
+	ChargeAllRaced: false,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("hedge_setting", &hedgeSetting)
+}
+
+func GetHedgeSetting() *HedgeSetting {
+	return &hedgeSetting
+}
+
+// IsHedgeGroupEnabled 分组是否启用对冲请求
+func IsHedgeGroupEnabled(group string) bool {
+	if len(hedgeSetting.Groups) == 0 {
+		return true
+	}
+	for _, g := range hedgeSetting.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
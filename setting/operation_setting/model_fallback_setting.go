@@ -0,0 +1,39 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// ModelFallbackAllGroups 备用链中对所有分组生效的配置
+const ModelFallbackAllGroups = "*"
+
+type ModelFallbackSetting struct {
+	// 开启后某个模型的所有渠道都失败、限流或无可用渠道时，按备用链依次改用其他模型
+	Enabled bool `json:"enabled"`
+	// 备用链，key 为分组（"*" 表示所有分组），值为模型到备用模型列表的映射，如 {"*": {"gpt-4o": ["claude-3-5-sonnet", "gemini-1.5-pro"]}}
+	Chains map[string]map[string][]string `json:"chains"`
+}
+
+// 默认配置
+var modelFallbackSetting = ModelFallbackSetting{
+	Enabled: false,
+	Chains:  map[string]map[string][]string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("model_fallback_setting", &modelFallbackSetting)
+}
+
+func GetModelFallbackSetting() *ModelFallbackSetting {
+	return &modelFallbackSetting
+}
+
+// GetModelFallbackChain 获取分组下模型的备用模型列表，分组未配置时使用所有分组的配置
+func GetModelFallbackChain(group string, modelName string) []string {
+	if !modelFallbackSetting.Enabled {
+		return nil
+	}
+	if chain, ok := modelFallbackSetting.Chains[group][modelName]; ok {
+		return chain
+	}
+	return modelFallbackSetting.Chains[ModelFallbackAllGroups][modelName]
+}
@@ -0,0 +1,167 @@
+package operation_setting
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-api/setting/config"
+	"time"
+)
+
+// PromptPriceTier 提示 tokens 达到 MinPromptTokens 时使用的价格，未设置的字段沿用模型原有的倍率或价格
+type PromptPriceTier struct {
+	MinPromptTokens int      `json:"min_prompt_tokens"`
+	ModelRatio      *float64 `json:"model_ratio,omitempty"`
+	CompletionRatio *float64 `json:"completion_ratio,omitempty"`
+	ModelPrice      *float64 `json:"model_price,omitempty"`
+}
+
+// SchedulePriceWindow 按时段调整价格，如闲时折扣
+type SchedulePriceWindow struct {
+	Name string `json:"name"`
+	// 格式 HH:MM-HH:MM，结束时间早于开始时间表示跨天
+	TimeRange string `json:"time_range"`
+	// 生效的星期，0 为周日，为空表示每天
+	Weekdays []int `json:"weekdays,omitempty"`
+	// 模型倍率或按次价格的乘数
+	Multiplier float64 `json:"multiplier"`
+}
+
+type ModelPricingTiers struct {
+	PromptTiers []PromptPriceTier     `json:"prompt_tiers,omitempty"`
+	Schedules   []SchedulePriceWindow `json:"schedules,omitempty"`
+}
+
+type PricingTierSetting struct {
+	// 开启后按提示长度阶梯和时段调整模型价格
+	Enabled bool `json:"enabled"`
+	// 时段使用的时区，如 Asia/Shanghai，为空使用服务器时区
+	Timezone string `json:"timezone"`
+	// 模型名称到价格规则的映射，* 对所有未单独配置的模型生效
+	Models map[string]ModelPricingTiers `json:"models"`
+}
+
+// 默认配置
+var pricingTierSetting = PricingTierSetting{
+	Enabled:  false,
+	Timezone: "",
+	Models:   map[string]ModelPricingTiers{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("pricing_tier_setting", &pricingTierSetting)
+}
+
+func GetPricingTierSetting() *PricingTierSetting {
+	return &pricingTierSetting
+}
+
+// GetModelPricingTiers 获取模型的价格规则，未开启或未配置时返回 nil
+func GetModelPricingTiers(modelName string) *ModelPricingTiers {
+	if !pricingTierSetting.Enabled {
+		return nil
+	}
+	if tiers, ok := pricingTierSetting.Models[modelName]; ok {
+		return &tiers
+	}
+	if tiers, ok := pricingTierSetting.Models["*"]; ok {
+		return &tiers
+	}
+	return nil
+}
+
+// MatchPromptTier 返回提示 tokens 满足的最高一档
+func (t *ModelPricingTiers) MatchPromptTier(promptTokens int) *PromptPriceTier {
+	var matched *PromptPriceTier
+	for i := range t.PromptTiers {
+		tier := &t.PromptTiers[i]
+		if promptTokens >= tier.MinPromptTokens && (matched == nil || tier.MinPromptTokens > matched.MinPromptTokens) {
+			matched = tier
+		}
+	}
+	return matched
+}
+
+// MatchSchedule 返回当前时间所在的第一个时段
+func (t *ModelPricingTiers) MatchSchedule(now time.Time) *SchedulePriceWindow {
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if pricingTierSetting.Timezone != "" {
+		if location, err := time.LoadLocation(pricingTierSetting.Timezone); err == nil {
+			now = now.In(location)
+		}
+	}
+	minute := now.Hour()*60 + now.Minute()
+	for i := range t.Schedules {
+		window := &t.Schedules[i]
+		start, end, err := ParseTimeRange(window.TimeRange)
+		if err != nil {
+			continue
+		}
+		weekday := int(now.Weekday())
+		// 跨天时段的凌晨部分属于前一天开始的时段
+		if start > end && minute < end {
+			weekday = (weekday + 6) % 7
+		}
+		if len(window.Weekdays) > 0 && !containsWeekday(window.Weekdays, weekday) {
+			continue
+		}
+		if start <= end && minute >= start && minute < end {
+			return window
+		}
+		if start > end && (minute >= start || minute < end) {
+			return window
+		}
+	}
+	return nil
+}
+
+func containsWeekday(weekdays []int, weekday int) bool {
+	for _, w := range weekdays {
+		if w == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidatePricingTiers 校验通过选项接口提交的价格规则
+func ValidatePricingTiers(modelsStr string) error {
+	var models map[string]ModelPricingTiers
+	if err := json.Unmarshal([]byte(modelsStr), &models); err != nil {
+		return fmt.Errorf("价格规则格式错误: %s", err.Error())
+	}
+	for model, tiers := range models {
+		for _, tier := range tiers.PromptTiers {
+			if tier.MinPromptTokens < 0 {
+				return fmt.Errorf("模型 %s 的提示阶梯起点不能为负数", model)
+			}
+		}
+		for _, window := range tiers.Schedules {
+			if _, _, err := ParseTimeRange(window.TimeRange); err != nil {
+				return fmt.Errorf("模型 %s: %s", model, err.Error())
+			}
+			if window.Multiplier <= 0 {
+				return fmt.Errorf("模型 %s 的时段 %s 乘数必须大于 0", model, window.Name)
+			}
+			for _, weekday := range window.Weekdays {
+				if weekday < 0 || weekday > 6 {
+					return fmt.Errorf("模型 %s 的时段 %s 星期必须在 0-6 之间", model, window.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ValidatePricingTimezone 校验时区名称
+func ValidatePricingTimezone(timezone string) error {
+	if timezone == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return fmt.Errorf("无效的时区: %s", timezone)
+	}
+	return nil
+}
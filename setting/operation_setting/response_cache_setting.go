@@ -0,0 +1,65 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+const (
+	ResponseCacheBackendAuto   = "auto"
+	ResponseCacheBackendRedis  = "redis"
+	ResponseCacheBackendMemory = "memory"
+)
+
+type ResponseCacheSetting struct {
+	// 总开关，开启后还需令牌开启响应缓存才会生效
+	Enabled bool `json:"enabled"`
+	// 缓存后端：auto（启用 Redis 时使用 Redis，否则使用内存）、redis、memory
+	Backend string `json:"backend"`
+	// 缓存有效期（秒）
+	TTLSeconds int `json:"ttl_seconds"`
+	// 允许使用缓存的分组，为空表示所有分组
+	Groups []string `json:"groups"`
+	// 命中缓存时的计费倍率，最终扣费 = 正常扣费 * HitPriceRatio
+	HitPriceRatio float64 `json:"hit_price_ratio"`
+	// 仅缓存 temperature 为 0 的请求
+	RequireZeroTemperature bool `json:"require_zero_temperature"`
+	// 不同用户之间共享缓存，关闭时缓存按用户隔离
+	ShareAcrossUsers bool `json:"share_across_users"`
+	// 单条缓存最大字节数，超出时不缓存
+	MaxEntryBytes int `json:"max_entry_bytes"`
+	// 内存后端最多保存的条目数
+	MaxMemoryEntries int `json:"max_memory_entries"`
+}
+
+// 默认配置
+var responseCacheSetting = ResponseCacheSetting{
+	Enabled:                false,
+	Backend:                ResponseCacheBackendAuto,
+	TTLSeconds:             3600,
+	Groups:                 []string{},
+	HitPriceRatio:          0.1,
+	RequireZeroTemperature: true,
+	ShareAcrossUsers:       false,
+	MaxEntryBytes:          1024 * 1024,
+	MaxMemoryEntries:       10000,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("response_cache_setting", &responseCacheSetting)
+}
+
+func GetResponseCacheSetting() *ResponseCacheSetting {
+	return &responseCacheSetting
+}
+
+// IsResponseCacheGroupEnabled 分组是否允许使用响应缓存
+func IsResponseCacheGroupEnabled(group string) bool {
+	if len(responseCacheSetting.Groups) == 0 {
+		return true
+	}
+	for _, g := range responseCacheSetting.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type ResponsesSetting struct {
+	// 在本地保存 /v1/responses 会话状态，previous_response_id 在本地展开后再转发
+	StoreEnabled bool `json:"store_enabled"`
+	// 保存时长（秒）
+	StoreTTLSeconds int64 `json:"store_ttl_seconds"`
+	// 单条记录（展开后的输入、输出及响应）最大字节数，超出时丢弃最早的输入项
+	MaxStoreBytes int `json:"max_store_bytes"`
+	// 每个用户最多保存的记录数，超出时删除最早的记录
+	MaxStoredPerUser int `json:"max_stored_per_user"`
+}
+
+// 默认配置
+var responsesSetting = ResponsesSetting{
+	StoreEnabled:     true,
+	StoreTTLSeconds:  30 * 24 * 3600,
+	MaxStoreBytes:    4 * 1024 * 1024,
+	MaxStoredPerUser: 1000,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("responses_setting", &responsesSetting)
+}
+
+func GetResponsesSetting() *ResponsesSetting {
+	return &responsesSetting
+}
@@ -0,0 +1,108 @@
+package operation_setting
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-api/setting/config"
+	"strings"
+	"time"
+)
+
+// RoutingMatch 规则的匹配条件，未设置的条件不参与匹配，设置的条件需全部满足
+type RoutingMatch struct {
+	TokenIds []int    `json:"token_ids,omitempty"`
+	UserIds  []int64  `json:"user_ids,omitempty"`
+	Groups   []string `json:"groups,omitempty"`
+	// 模型通配符，支持 * 和 ?，如 gpt-4*
+	Models []string `json:"models,omitempty"`
+	// 请求头名称到取值通配符的映射
+	Headers map[string]string `json:"headers,omitempty"`
+	// 提示内容的字符数范围，0 表示不限制
+	MinPromptLength int   `json:"min_prompt_length,omitempty"`
+	MaxPromptLength int   `json:"max_prompt_length,omitempty"`
+	HasTools        *bool `json:"has_tools,omitempty"`
+	HasImages       *bool `json:"has_images,omitempty"`
+	Stream          *bool `json:"stream,omitempty"`
+	// 服务器本地时间段，格式 HH:MM-HH:MM，结束时间早于开始时间表示跨天
+	TimeRanges []string `json:"time_ranges,omitempty"`
+}
+
+// RoutingAction 命中规则后的路由方式，可以同时改写模型并限定渠道范围
+type RoutingAction struct {
+	// 只在带有该标签的渠道中选择
+	ChannelTag string `json:"channel_tag,omitempty"`
+	// 只在这些渠道中选择
+	ChannelIds []int `json:"channel_ids,omitempty"`
+	// 改用该模型
+	Model string `json:"model,omitempty"`
+}
+
+type RoutingRule struct {
+	Name     string        `json:"name"`
+	Disabled bool          `json:"disabled,omitempty"`
+	Match    RoutingMatch  `json:"match"`
+	Action   RoutingAction `json:"action"`
+}
+
+type RoutingSetting struct {
+	// 开启后在选择渠道前按顺序匹配路由规则，使用第一条命中的规则
+	Enabled bool          `json:"enabled"`
+	Rules   []RoutingRule `json:"rules"`
+}
+
+// 默认配置
+var routingSetting = RoutingSetting{
+	Enabled: false,
+	Rules:   []RoutingRule{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("routing_setting", &routingSetting)
+}
+
+func GetRoutingSetting() *RoutingSetting {
+	return &routingSetting
+}
+
+// ParseTimeRange 解析 HH:MM-HH:MM 格式的时间段，返回当天的起止分钟数
+func ParseTimeRange(timeRange string) (int, int, error) {
+	parts := strings.Split(timeRange, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid time range %q, expected HH:MM-HH:MM", timeRange)
+	}
+	minutes := make([]int, 2)
+	for i, part := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid time range %q, expected HH:MM-HH:MM", timeRange)
+		}
+		minutes[i] = t.Hour()*60 + t.Minute()
+	}
+	return minutes[0], minutes[1], nil
+}
+
+// ValidateRoutingRules 校验通过选项接口提交的路由规则
+func ValidateRoutingRules(rulesStr string) error {
+	var rules []RoutingRule
+	if err := json.Unmarshal([]byte(rulesStr), &rules); err != nil {
+		return fmt.Errorf("路由规则格式错误: %s", err.Error())
+	}
+	for i, rule := range rules {
+		if rule.Name == "" {
+			return fmt.Errorf("第 %d 条路由规则缺少名称", i+1)
+		}
+		if rule.Action.ChannelTag == "" && len(rule.Action.ChannelIds) == 0 && rule.Action.Model == "" {
+			return fmt.Errorf("路由规则 %s 未设置路由方式", rule.Name)
+		}
+		if rule.Match.MaxPromptLength > 0 && rule.Match.MaxPromptLength < rule.Match.MinPromptLength {
+			return fmt.Errorf("路由规则 %s 的提示长度范围无效", rule.Name)
+		}
+		for _, timeRange := range rule.Match.TimeRanges {
+			if _, _, err := ParseTimeRange(timeRange); err != nil {
+				return fmt.Errorf("路由规则 %s: %s", rule.Name, err.Error())
+			}
+		}
+	}
+	return nil
+}
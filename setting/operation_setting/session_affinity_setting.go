@@ -0,0 +1,47 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type SessionAffinitySetting struct {
+	// 同一会话的请求固定到同一渠道和 Key，提高上游提示缓存命中率，绑定的渠道不可用时回退到正常选择
+	Enabled bool `json:"enabled"`
+	// 客户端传入会话标识的请求头
+	Header string `json:"header"`
+	// 没有会话请求头时，按系统提示和前 N 条消息计算会话标识，0 表示只使用请求头
+	HashMessages int `json:"hash_messages"`
+	// 绑定的有效期，每次成功请求后刷新
+	TTLSeconds int `json:"ttl_seconds"`
+	// 启用会话粘性的分组，为空表示所有分组
+	Groups []string `json:"groups"`
+}
+
+// 默认配置
+var sessionAffinitySetting = SessionAffinitySetting{
+	Enabled:      false,
+	Header:       "X-Session-Id",
+	HashMessages: 2,
+	TTLSeconds:   3600,
+	Groups:       []string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("session_affinity_setting", &sessionAffinitySetting)
+}
+
+func GetSessionAffinitySetting() *SessionAffinitySetting {
+	return &sessionAffinitySetting
+}
+
+// IsSessionAffinityGroupEnabled 分组是否启用会话粘性
+func IsSessionAffinityGroupEnabled(group string) bool {
+	if len(sessionAffinitySetting.Groups) == 0 {
+		return true
+	}
+	for _, g := range sessionAffinitySetting.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
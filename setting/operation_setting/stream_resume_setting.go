@@ -0,0 +1,44 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type StreamResumeSetting struct {
+	// 流式对话在上游中途断开或超时后，换渠道并以已输出内容作为前缀续写，拼接后返回给客户端
+	Enabled bool `json:"enabled"`
+	// 单个请求最多续写次数
+	MaxResumes int `json:"max_resumes"`
+	// 启用续写的分组，为空表示所有分组
+	Groups []string `json:"groups"`
+	// 续写时在已输出内容后追加的用户提示，为空时只追加助手前缀
+	ContinuePrompt string `json:"continue_prompt"`
+}
+
+// 默认配置
+var streamResumeSetting = StreamResumeSetting{
+	Enabled:        false,
+	MaxResumes:     2,
+	Groups:         []string{},
+	ContinuePrompt: "",
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("stream_resume_setting", &streamResumeSetting)
+}
+
+func GetStreamResumeSetting() *StreamResumeSetting {
+	return &streamResumeSetting
+}
+
+// IsStreamResumeGroupEnabled 分组是否启用流式续写
+func IsStreamResumeGroupEnabled(group string) bool {
+	if len(streamResumeSetting.Groups) == 0 {
+		return true
+	}
+	for _, g := range streamResumeSetting.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
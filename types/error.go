@@ -37,12 +37,14 @@ const (
 	ErrorCodeSensitiveWordsDetected ErrorCode = "sensitive_words_detected"
 
 	// new api error
-	ErrorCodeCountTokenFailed  ErrorCode = "count_token_failed"
-	ErrorCodeModelPriceError   ErrorCode = "model_price_error"
-	ErrorCodeInvalidApiType    ErrorCode = "invalid_api_type"
-	ErrorCodeJsonMarshalFailed ErrorCode = "json_marshal_failed"
-	ErrorCodeDoRequestFailed   ErrorCode = "do_request_failed"
-	ErrorCodeGetChannelFailed  ErrorCode = "get_channel_failed"
+	ErrorCodeCountTokenFailed        ErrorCode = "count_token_failed"
+	ErrorCodeModelPriceError         ErrorCode = "model_price_error"
+	ErrorCodeInvalidApiType          ErrorCode = "invalid_api_type"
+	ErrorCodeJsonMarshalFailed       ErrorCode = "json_marshal_failed"
+	ErrorCodeDoRequestFailed         ErrorCode = "do_request_failed"
+	ErrorCodeGetChannelFailed        ErrorCode = "get_channel_failed"
+	ErrorCodeChannelSaturated        ErrorCode = "channel_concurrency_saturated"
+	ErrorCodeAdmissionQueueExhausted ErrorCode = "admission_queue_exhausted"
 
 	// channel error
 	ErrorCodeChannelNoAvailableKey       ErrorCode = "channel:no_available_key"
@@ -56,6 +58,7 @@ const (
 	ErrorCodeReadRequestBodyFailed ErrorCode = "read_request_body_failed"
 	ErrorCodeConvertRequestFailed  ErrorCode = "convert_request_failed"
 	ErrorCodeAccessDenied          ErrorCode = "access_denied"
+	ErrorCodeContextWindowExceeded ErrorCode = "context_window_exceeded"
 
 	// response error
 	ErrorCodeReadResponseBodyFailed ErrorCode = "read_response_body_failed"
@@ -70,6 +73,7 @@ const (
 	// quota error
 	ErrorCodeInsufficientUserQuota      ErrorCode = "insufficient_user_quota"
 	ErrorCodePreConsumeTokenQuotaFailed ErrorCode = "pre_consume_token_quota_failed"
+	ErrorCodeSpendingBudgetExceeded     ErrorCode = "spending_budget_exceeded"
 )
 
 type NewAPIError struct {